@@ -4,15 +4,21 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/sethvargo/go-envconfig"
 )
 
 type Config struct {
-	DB         DBConfig `env:",prefix=DB_,required"`
-	BcryptSalt int      `env:"BCRYPT_SALT"`
-	JWTSecret  string   `env:"JWT_SECRET"`
+	DB          DBConfig          `env:",prefix=DB_,required"`
+	BcryptSalt  int               `env:"BCRYPT_SALT"`
+	JWT         JWTConfig         `env:",prefix=JWT_"`
+	Loyalty     LoyaltyConfig     `env:",prefix=LOYALTY_"`
+	Reservation ReservationConfig `env:",prefix=RESERVATION_"`
+	Store       StoreConfig       `env:",prefix=STORE_"`
+	Segment     SegmentConfig     `env:",prefix=SEGMENT_"`
+	Staff       StaffConfig       `env:",prefix=STAFF_"`
 }
 
 type DBConfig struct {
@@ -24,6 +30,114 @@ type DBConfig struct {
 	Params   string `env:"PARAMS"`
 }
 
+// LoyaltyConfig controls how customers earn and spend loyalty points.
+// EarnRate is the number of points earned per unit of currency spent and
+// PointValue is the currency value of a single point when redeemed.
+type LoyaltyConfig struct {
+	EarnRate   float64 `env:"EARN_RATE, default=0.01"`
+	PointValue int     `env:"POINT_VALUE, default=1"`
+}
+
+// ReservationConfig controls how long held stock stays reserved and how
+// often expired reservations are swept.
+type ReservationConfig struct {
+	TTL           time.Duration `env:"TTL, default=30m"`
+	SweepInterval time.Duration `env:"SWEEP_INTERVAL, default=1m"`
+}
+
+// StoreConfig is printed in the receipt header. Code prefixes the receipt
+// numbers and TaxRate is the percentage of tax already included in the
+// product prices.
+type StoreConfig struct {
+	Code    string  `env:"CODE, default=ENQ"`
+	Name    string  `env:"NAME, default=Eniqilo Store"`
+	Address string  `env:"ADDRESS"`
+	Phone   string  `env:"PHONE"`
+	TaxRate float64 `env:"TAX_RATE, default=0"`
+}
+
+// SegmentConfig controls how often customer tiers and segment membership
+// are recomputed. A customer's tier is decided by what they spent within
+// TierWindow, SilverSpend, GoldSpend and PlatinumSpend are the thresholds.
+type SegmentConfig struct {
+	RefreshInterval time.Duration `env:"REFRESH_INTERVAL, default=1h"`
+	TierWindow      time.Duration `env:"TIER_WINDOW, default=8760h"`
+	SilverSpend     int           `env:"SILVER_SPEND, default=1000000"`
+	GoldSpend       int           `env:"GOLD_SPEND, default=5000000"`
+	PlatinumSpend   int           `env:"PLATINUM_SPEND, default=20000000"`
+}
+
+// StaffConfig controls staff onboarding. InviteTTL is how long an invite
+// code issued by an admin can be used to register and ResetTokenTTL the
+// same for a password reset token. PinTokenTTL is the lifetime of the
+// tokens issued by a pin login on a terminal.
+type StaffConfig struct {
+	InviteTTL     time.Duration   `env:"INVITE_TTL, default=72h"`
+	ResetTokenTTL time.Duration   `env:"RESET_TOKEN_TTL, default=1h"`
+	Password      PasswordPolicy  `env:",prefix=PASSWORD_"`
+	Login         LoginPolicy     `env:",prefix=LOGIN_"`
+	PinTokenTTL   time.Duration   `env:"PIN_TOKEN_TTL, default=5m"`
+	TwoFactor     TwoFactorConfig `env:",prefix=TWO_FACTOR_"`
+}
+
+// JWTConfig holds the keys access tokens are signed with. KeyFiles are
+// kid=path pairs of PEM files, RSA keys sign with RS256 and Ed25519 keys
+// with EdDSA. New tokens are signed with SigningKey, the other keys only
+// verify tokens issued before a rotation and may be public keys. Secret is
+// the old shared HS256 key, used to sign when SigningKey is empty and to
+// verify tokens without a kid while it is set.
+type JWTConfig struct {
+	Secret     string   `env:"SECRET"`
+	KeyFiles   []string `env:"KEY_FILES"`
+	SigningKey string   `env:"SIGNING_KEY"`
+}
+
+// TwoFactorConfig lists the roles that must use an authenticator app.
+// Staff with such a role can only reach the enrolment endpoints until
+// they have set it up. ChallengeTTL is how long the second login step may
+// take. SecretKey is the base64 of the 32 byte key the authenticator
+// secrets are encrypted with, it must not be stored with the database.
+type TwoFactorConfig struct {
+	Roles        []string      `env:"ROLES"`
+	ChallengeTTL time.Duration `env:"CHALLENGE_TTL, default=5m"`
+	SecretKey    string        `env:"SECRET_KEY"`
+}
+
+// Requires tells whether role has to use two-factor authentication
+func (c TwoFactorConfig) Requires(role string) bool {
+	for _, r := range c.Roles {
+		if strings.TrimSpace(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// LoginPolicy slows down password guessing. After FreeAttempts failures
+// for a phone number every further attempt has to wait BaseDelay, doubled
+// each time up to MaxDelay, and LockoutThreshold failures lock the phone
+// number for LockoutDuration. A client ip is locked the same way after
+// IPLockoutThreshold failures. Failures older than Window are forgotten.
+type LoginPolicy struct {
+	FreeAttempts       int           `env:"FREE_ATTEMPTS, default=3"`
+	BaseDelay          time.Duration `env:"BASE_DELAY, default=1s"`
+	MaxDelay           time.Duration `env:"MAX_DELAY, default=5m"`
+	LockoutThreshold   int           `env:"LOCKOUT_THRESHOLD, default=10"`
+	LockoutDuration    time.Duration `env:"LOCKOUT_DURATION, default=15m"`
+	IPLockoutThreshold int           `env:"IP_LOCKOUT_THRESHOLD, default=50"`
+	Window             time.Duration `env:"WINDOW, default=15m"`
+}
+
+// PasswordPolicy is checked whenever a staff password is set. MaxLength
+// can't go above 72, bcrypt ignores anything longer.
+type PasswordPolicy struct {
+	MinLength     int  `env:"MIN_LENGTH, default=8"`
+	MaxLength     int  `env:"MAX_LENGTH, default=72"`
+	RequireUpper  bool `env:"REQUIRE_UPPER, default=false"`
+	RequireDigit  bool `env:"REQUIRE_DIGIT, default=true"`
+	RequireSymbol bool `env:"REQUIRE_SYMBOL, default=false"`
+}
+
 func LoadConfig(ctx context.Context) (*Config, error) {
 	err := godotenv.Load(".env")
 	if err != nil {
@@ -35,9 +149,28 @@ func LoadConfig(ctx context.Context) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// validate rejects settings the app can't start with
+func (c *Config) validate() error {
+	if c.Reservation.SweepInterval <= 0 {
+		return fmt.Errorf("RESERVATION_SWEEP_INTERVAL must be positive, got %s", c.Reservation.SweepInterval)
+	}
+	if c.Segment.RefreshInterval <= 0 {
+		return fmt.Errorf("SEGMENT_REFRESH_INTERVAL must be positive, got %s", c.Segment.RefreshInterval)
+	}
+	if c.Staff.TwoFactor.SecretKey == "" {
+		return fmt.Errorf("STAFF_TWO_FACTOR_SECRET_KEY is required")
+	}
+
+	return nil
+}
+
 func (c DBConfig) ConnectionString() string {
 	params := strings.ReplaceAll(c.Params, `"`, "")
 	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?%s", c.Username, c.Password, c.Host, c.Port, c.Name, params)
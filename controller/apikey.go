@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type APIKeyController struct {
+	service  service.APIKeyService
+	validate *validator.Validate
+}
+
+func NewAPIKeyController(service service.APIKeyService, validate *validator.Validate) *APIKeyController {
+	return &APIKeyController{
+		service:  service,
+		validate: validate,
+	}
+}
+
+func (c *APIKeyController) PostAPIKey(ctx echo.Context) error {
+	var keyRequest model.CreateAPIKeyRequest
+	if err := ctx.Bind(&keyRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&keyRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	key, err := c.service.CreateAPIKey(ctx.Request().Context(), staffId, keyRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "API key created, it won't be shown again",
+		Data:    key,
+	})
+}
+
+func (c *APIKeyController) GetAPIKeys(ctx echo.Context) error {
+	keys, err := c.service.GetAPIKeys(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    keys,
+	})
+}
+
+func (c *APIKeyController) DeleteAPIKey(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "api key is not found"})
+	}
+
+	key, err := c.service.RevokeAPIKey(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "API key revoked",
+		Data:    key,
+	})
+}
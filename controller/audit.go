@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AuditController struct {
+	service service.AuditService
+}
+
+func NewAuditController(service service.AuditService) *AuditController {
+	return &AuditController{
+		service: service,
+	}
+}
+
+// GetAudit lists the audit log newest first, see parseGetAuditParams for
+// the filters.
+func (c *AuditController) GetAudit(ctx echo.Context) error {
+	entries, meta, err := c.service.GetEntries(ctx.Request().Context(), parseGetAuditParams(ctx.QueryParams()))
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    entries,
+		Meta:    &meta,
+	})
+}
+
+func parseGetAuditParams(params url.Values) model.GetAuditParam {
+	var result model.GetAuditParam
+
+	for key, values := range params {
+		value := values[0]
+		switch key {
+		case "actorType":
+			result.ActorType = &value
+		case "actorId":
+			result.ActorId = &value
+		case "action":
+			result.Action = &value
+		case "entityType":
+			result.EntityType = &value
+		case "entityId":
+			result.EntityId = &value
+		case "requestId":
+			result.RequestId = &value
+		case "from":
+			from, err := parseHistoryTime(value, false)
+			if err == nil {
+				result.From = &from
+			}
+		case "to":
+			to, err := parseHistoryTime(value, true)
+			if err == nil {
+				result.To = &to
+			}
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err == nil {
+				result.Limit = limit
+			}
+		case "offset":
+			offset, err := strconv.Atoi(value)
+			if err == nil {
+				result.Offset = offset
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type CartController struct {
+	service  service.CartService
+	validate *validator.Validate
+}
+
+func NewCartController(service service.CartService, validate *validator.Validate) *CartController {
+	return &CartController{
+		service:  service,
+		validate: validate,
+	}
+}
+
+func (c *CartController) PostCart(ctx echo.Context) error {
+	var cartRequest model.CreateCartRequest
+	if err := ctx.Bind(&cartRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&cartRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	cart, err := c.service.CreateCart(ctx.Request().Context(), staffId, cartRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "Cart created successfully",
+		Data:    cart,
+	})
+}
+
+func (c *CartController) GetCarts(ctx echo.Context) error {
+	status := model.CartStatus(ctx.QueryParam("status"))
+	if status == "" {
+		status = model.CartHeld
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	carts, err := c.service.GetCarts(ctx.Request().Context(), status, staffId, role)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    carts,
+	})
+}
+
+func (c *CartController) GetCart(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cart is not found"})
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	cart, err := c.service.GetCart(ctx.Request().Context(), id, staffId, role)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    cart,
+	})
+}
+
+func (c *CartController) PostCartItem(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cart is not found"})
+	}
+
+	var itemRequest model.CartItemRequest
+	if err := ctx.Bind(&itemRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&itemRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	cart, err := c.service.AddItem(ctx.Request().Context(), id, staffId, role, itemRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    cart,
+	})
+}
+
+func (c *CartController) DeleteCartItem(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cart is not found"})
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	cart, err := c.service.RemoveItem(ctx.Request().Context(), id, staffId, role, ctx.Param("productId"))
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    cart,
+	})
+}
+
+func (c *CartController) PutCartCustomer(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cart is not found"})
+	}
+
+	var customerRequest model.CartCustomerRequest
+	if err := ctx.Bind(&customerRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&customerRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	cart, err := c.service.AttachCustomer(ctx.Request().Context(), id, staffId, role, *customerRequest.CustomerId)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    cart,
+	})
+}
+
+func (c *CartController) HoldCart(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cart is not found"})
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	cart, err := c.service.Hold(ctx.Request().Context(), id, staffId, role)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Cart is on hold",
+		Data:    cart,
+	})
+}
+
+func (c *CartController) ResumeCart(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cart is not found"})
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	cart, err := c.service.Resume(ctx.Request().Context(), id, staffId, role)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Cart resumed",
+		Data:    cart,
+	})
+}
+
+func (c *CartController) CheckoutCart(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cart is not found"})
+	}
+
+	var checkoutRequest model.CartCheckoutRequest
+	if err := ctx.Bind(&checkoutRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&checkoutRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	result, err := c.service.Checkout(ctx.Request().Context(), id, staffId, role, checkoutRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.ErrorMessageOrder{
+			Message:    err.Error(),
+			StatusCode: cerr.GetCode(err),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Successfully Checkout",
+		Data: model.CheckoutResponseData{
+			TransactionId: result.TransactionId,
+			ReceiptNumber: result.ReceiptNumber,
+			GiftCards:     result.GiftCards,
+		},
+	})
+}
+
+// staffIdFromContext returns the id of the staff member authenticated by
+// middleware.Authentication.
+func staffIdFromContext(ctx echo.Context) (uuid.UUID, error) {
+	payload, ok := ctx.Get("userData").(*model.JWTPayload)
+	if !ok {
+		return uuid.Nil, echo.ErrUnauthorized
+	}
+
+	return uuid.Parse(payload.Id)
+}
+
+// staffFromContext also returns the role, for services that let managers
+// act on what other staff members own.
+func staffFromContext(ctx echo.Context) (uuid.UUID, model.StaffRole, error) {
+	payload, ok := ctx.Get("userData").(*model.JWTPayload)
+	if !ok {
+		return uuid.Nil, "", echo.ErrUnauthorized
+	}
+
+	id, err := uuid.Parse(payload.Id)
+	return id, payload.Role, err
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -73,7 +74,7 @@ func (c *CheckoutController) PostCheckout(ctx echo.Context) error {
 	}
 
 	//validate product
-	totalPrice, err := c.service.ValidateProduct(ctx.Request().Context(), orderRequest.ProductDetails)
+	totalPrice, err := c.service.ValidateProduct(ctx.Request().Context(), orderRequest.ProductDetails, nil)
 	if err != nil {
 		return ctx.JSON(cerr.GetCode(err), model.ErrorMessageOrder{
 			Message:    err.Error(),
@@ -81,26 +82,19 @@ func (c *CheckoutController) PostCheckout(ctx echo.Context) error {
 		})
 	}
 
-	if totalPrice > float32(*orderRequest.Paid) {
-		resErr := customErr.NewBadRequestError("Paid amount is not enough based on all bought products")
+	transaction, err := c.service.PrepareTransaction(orderRequest, totalPrice)
+	if err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
 		return ctx.JSON(resErr.StatusCode, resErr)
 	}
 
-	change := float32(*orderRequest.Paid) - float32(totalPrice)
-	if float32(*orderRequest.Change) != change {
-		resErr := customErr.NewBadRequestError("Change is not correct based on all bought products and what is paid")
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
 		return ctx.JSON(resErr.StatusCode, resErr)
 	}
 
-	transaction := model.Transaction{
-		TransactionId:  uuid.New(),
-		CustomerId:     uuid.MustParse(*orderRequest.CustomerId),
-		ProductDetails: orderRequest.ProductDetails,
-		Paid:           *orderRequest.Paid,
-		Change:         *orderRequest.Change,
-	}
-
-	err = c.service.CheckoutProduct(ctx.Request().Context(), transaction)
+	result, err := c.service.CheckoutProduct(ctx.Request().Context(), staffId, nil, transaction)
 	if err != nil {
 		return ctx.JSON(cerr.GetCode(err), model.ErrorMessageOrder{
 			Message:    err.Error(),
@@ -110,6 +104,45 @@ func (c *CheckoutController) PostCheckout(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, model.GenericResponse{
 		Message: "Successfully Checkout",
+		Data: model.CheckoutResponseData{
+			TransactionId: result.TransactionId,
+			ReceiptNumber: result.ReceiptNumber,
+			GiftCards:     result.GiftCards,
+		},
+	})
+}
+
+func (c *CheckoutController) PostVoidTransaction(ctx echo.Context) error {
+	transactionId, err := uuid.Parse(ctx.Param("transactionId"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "transactionId is not found"})
+	}
+
+	var voidRequest model.VoidTransactionRequest
+	if err := ctx.Bind(&voidRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&voidRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	managerId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	transaction, err := c.service.VoidTransaction(ctx.Request().Context(), transactionId, managerId, *voidRequest.Reason)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Transaction voided",
+		Data:    transaction,
 	})
 }
 
@@ -132,7 +165,14 @@ func (c *CheckoutController) GetCustomer(ctx echo.Context) error {
 	}
 
 	// get all customer
-	listCustomer, err := c.service.GetAllCustomer(ctx.Request().Context(), name, phoneNumber, limit, offset)
+	listCustomer, err := c.service.GetAllCustomer(ctx.Request().Context(), model.GetCustomerParam{
+		Name:        name,
+		PhoneNumber: phoneNumber,
+		Segment:     ctx.QueryParam("segment"),
+		Tier:        ctx.QueryParam("tier"),
+		Limit:       limit,
+		Offset:      offset,
+	})
 	if err != nil {
 		resErr := customErr.NewInternalServerError(err.Error())
 		return ctx.JSON(resErr.StatusCode, resErr)
@@ -151,7 +191,7 @@ func (c *CheckoutController) GetHistoryTransaction(ctx echo.Context) error {
 	}
 
 	// query to service
-	data, err := c.service.GetAllTransaction(ctx.Request().Context(), parseGetHistoryParams(value))
+	data, meta, err := c.service.GetAllTransaction(ctx.Request().Context(), parseGetHistoryParams(value))
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
@@ -159,6 +199,7 @@ func (c *CheckoutController) GetHistoryTransaction(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, model.GenericResponse{
 		Message: "success",
 		Data:    data,
+		Meta:    &meta,
 	})
 }
 
@@ -172,6 +213,15 @@ func parseGetHistoryParams(params url.Values) model.GetHistoryParam {
 			if err == nil {
 				result.CustomerId = &customerId
 			}
+		case "staffId":
+			staffId, err := uuid.Parse(values[0])
+			if err == nil {
+				result.StaffId = &staffId
+			}
+		case "receiptNumber":
+			result.ReceiptNumber = &values[0]
+		case "status":
+			result.Status = model.TransactionStatus(values[0])
 		case "limit":
 			limit, err := strconv.Atoi(values[0])
 			if err == nil {
@@ -184,8 +234,50 @@ func parseGetHistoryParams(params url.Values) model.GetHistoryParam {
 			}
 		case "createdAt":
 			result.CreatedAt = &values[0]
+		case "from":
+			from, err := parseHistoryTime(values[0], false)
+			if err == nil {
+				result.From = &from
+			}
+		case "to":
+			to, err := parseHistoryTime(values[0], true)
+			if err == nil {
+				result.To = &to
+			}
+		case "minTotal":
+			minTotal, err := strconv.Atoi(values[0])
+			if err == nil {
+				result.MinTotal = &minTotal
+			}
+		case "maxTotal":
+			maxTotal, err := strconv.Atoi(values[0])
+			if err == nil {
+				result.MaxTotal = &maxTotal
+			}
+		case "productId":
+			result.ProductId = &values[0]
+		case "paymentMethod":
+			method := model.PaymentMethod(values[0])
+			result.PaymentMethod = &method
 		}
 	}
 
 	return result
 }
+
+// parseHistoryTime accepts RFC 3339 timestamps or plain dates, a plain date
+// used as the end of a range covers the whole day.
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Microsecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type CustomerController struct {
+	service  service.CustomerService
+	validate *validator.Validate
+}
+
+func NewCustomerController(service service.CustomerService, validate *validator.Validate) *CustomerController {
+	_ = validate.RegisterValidation("phone_number", validatePhoneNumber)
+
+	return &CustomerController{
+		service:  service,
+		validate: validate,
+	}
+}
+
+func (c *CustomerController) GetCustomerById(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "customerId is not found"})
+	}
+
+	customer, err := c.service.GetCustomer(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    customer,
+	})
+}
+
+func (c *CustomerController) PatchCustomer(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "customerId is not found"})
+	}
+
+	var updateRequest model.UpdateCustomerRequest
+	if err := ctx.Bind(&updateRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&updateRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	customer, err := c.service.UpdateCustomer(ctx.Request().Context(), id, updateRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Customer updated successfully",
+		Data:    customer,
+	})
+}
+
+func (c *CustomerController) DeleteCustomer(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "customerId is not found"})
+	}
+
+	result, err := c.service.DeleteCustomer(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	message := "Customer deleted successfully"
+	if result.Anonymised {
+		message = "Customer has transactions and was anonymised"
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: message,
+		Data:    result,
+	})
+}
+
+func (c *CustomerController) GetCustomerSummary(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "customerId is not found"})
+	}
+
+	summary, err := c.service.GetCustomerSummary(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    summary,
+	})
+}
+
+func (c *CustomerController) GetDuplicateCandidates(ctx echo.Context) error {
+	groups, err := c.service.GetDuplicateCandidates(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    groups,
+	})
+}
+
+func (c *CustomerController) PostMergeCustomer(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "customerId is not found"})
+	}
+
+	var mergeRequest model.MergeCustomerRequest
+	if err := ctx.Bind(&mergeRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&mergeRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	customer, err := c.service.MergeCustomer(ctx.Request().Context(), id, uuid.MustParse(*mergeRequest.SourceId))
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Customers merged successfully",
+		Data:    customer,
+	})
+}
+
+func (c *CustomerController) GetCustomerExport(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "customerId is not found"})
+	}
+
+	export, err := c.service.ExportCustomer(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="customer-`+id.String()+`.json"`)
+	return ctx.JSON(http.StatusOK, export)
+}
+
+func (c *CustomerController) PostEraseCustomer(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "customerId is not found"})
+	}
+
+	result, err := c.service.EraseCustomer(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Customer personal data erased",
+		Data:    result,
+	})
+}
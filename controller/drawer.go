@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type DrawerController struct {
+	service  service.DrawerService
+	validate *validator.Validate
+}
+
+func NewDrawerController(service service.DrawerService, validate *validator.Validate) *DrawerController {
+	return &DrawerController{
+		service:  service,
+		validate: validate,
+	}
+}
+
+func (c *DrawerController) OpenSession(ctx echo.Context) error {
+	var openRequest model.OpenDrawerRequest
+	if err := ctx.Bind(&openRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&openRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	session, err := c.service.OpenSession(ctx.Request().Context(), staffId, openRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "Cash drawer session opened",
+		Data:    session,
+	})
+}
+
+func (c *DrawerController) GetCurrentSession(ctx echo.Context) error {
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	session, err := c.service.GetCurrentSession(ctx.Request().Context(), staffId)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    session,
+	})
+}
+
+func (c *DrawerController) PostMovement(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cash drawer session is not found"})
+	}
+
+	var movementRequest model.DrawerMovementRequest
+	if err := ctx.Bind(&movementRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&movementRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	movement, err := c.service.AddMovement(ctx.Request().Context(), id, staffId, role, movementRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "success",
+		Data:    movement,
+	})
+}
+
+func (c *DrawerController) GetXReport(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cash drawer session is not found"})
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	report, err := c.service.GetXReport(ctx.Request().Context(), id, staffId, role)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    report,
+	})
+}
+
+func (c *DrawerController) CloseSession(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cash drawer session is not found"})
+	}
+
+	var closeRequest model.CloseDrawerRequest
+	if err := ctx.Bind(&closeRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&closeRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	report, err := c.service.CloseSession(ctx.Request().Context(), id, staffId, role, closeRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Cash drawer session closed",
+		Data:    report,
+	})
+}
+
+func (c *DrawerController) GetZReport(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "cash drawer session is not found"})
+	}
+
+	staffId, role, err := staffFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	report, err := c.service.GetZReport(ctx.Request().Context(), id, staffId, role)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    report,
+	})
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type GiftCardController struct {
+	service  service.GiftCardService
+	validate *validator.Validate
+}
+
+func NewGiftCardController(service service.GiftCardService, validate *validator.Validate) *GiftCardController {
+	return &GiftCardController{
+		service:  service,
+		validate: validate,
+	}
+}
+
+func (c *GiftCardController) PostGiftCard(ctx echo.Context) error {
+	var giftCardRequest model.IssueGiftCardRequest
+	if err := ctx.Bind(&giftCardRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&giftCardRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	giftCard, err := c.service.IssueGiftCard(ctx.Request().Context(), giftCardRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "Gift card issued successfully",
+		Data:    giftCard,
+	})
+}
+
+func (c *GiftCardController) GetGiftCard(ctx echo.Context) error {
+	giftCard, err := c.service.GetGiftCard(ctx.Request().Context(), ctx.Param("code"))
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    giftCard,
+	})
+}
@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"eniqilo-store/pkg/crypto"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type JWKSController struct {
+	keys *crypto.KeySet
+}
+
+func NewJWKSController(keys *crypto.KeySet) *JWKSController {
+	return &JWKSController{keys: keys}
+}
+
+// GetJWKS lets other services verify our access tokens. Verifiers may
+// cache it for a while, a new key has to be listed for longer than that
+// before it starts signing.
+func (c *JWKSController) GetJWKS(ctx echo.Context) error {
+	ctx.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return ctx.JSON(http.StatusOK, c.keys.JWKS())
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type LoyaltyController struct {
+	service service.LoyaltyService
+}
+
+func NewLoyaltyController(service service.LoyaltyService) *LoyaltyController {
+	return &LoyaltyController{
+		service: service,
+	}
+}
+
+func (c *LoyaltyController) GetCustomerPoints(ctx echo.Context) error {
+	customerId, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "customerId is not found"})
+	}
+
+	limit, err := strconv.Atoi(ctx.QueryParam("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10 // Default limit
+	}
+
+	offset, err := strconv.Atoi(ctx.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		offset = 0 // Default offset
+	}
+
+	points, err := c.service.GetCustomerPoints(ctx.Request().Context(), customerId, model.GetPointHistoryParam{
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    points,
+	})
+}
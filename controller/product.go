@@ -51,9 +51,8 @@ func (ctr *ProductController) GetProductCustomer(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, echo.Map{"error": "params not valid"})
 	}
 
-	value.Add("isAvailable", "true")
 	// query to service
-	data, err := ctr.ProductService.GetProduct(c.Request().Context(), parseGetProductParams(value))
+	data, err := ctr.ProductService.GetProductCustomer(c.Request().Context(), parseGetProductParams(value))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
 	}
@@ -122,10 +121,7 @@ func (ctr *ProductController) DeleteProduct(c echo.Context) error {
 
 	err = ctr.ProductService.DeleteProduct(c.Request().Context(), id)
 	if err != nil {
-		if err.Error() == "no product found with the given ID" {
-			return c.JSON(http.StatusNotFound, echo.Map{"error": "Product not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return c.JSON(cerr.GetCode(err), echo.Map{"error": err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{"message": "Product successfully deleted"})
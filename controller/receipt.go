@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ReceiptController struct {
+	service service.ReceiptService
+}
+
+func NewReceiptController(service service.ReceiptService) *ReceiptController {
+	return &ReceiptController{
+		service: service,
+	}
+}
+
+var receiptContentTypes = map[model.ReceiptFormat]string{
+	model.ReceiptText:   "text/plain; charset=utf-8",
+	model.ReceiptESCPOS: "application/octet-stream",
+	model.ReceiptPDF:    "application/pdf",
+}
+
+func (c *ReceiptController) GetReceipt(ctx echo.Context) error {
+	format := model.ReceiptFormat(ctx.QueryParam("format"))
+	if format == "" {
+		format = model.ReceiptText
+	}
+
+	// the transaction can be given by id or by receipt number
+	content, err := c.service.RenderReceipt(ctx.Request().Context(), ctx.Param("transactionId"), format)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.Blob(http.StatusOK, receiptContentTypes[format], content)
+}
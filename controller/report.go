@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type ReportController struct {
+	service service.ReportService
+}
+
+func NewReportController(service service.ReportService) *ReportController {
+	return &ReportController{
+		service: service,
+	}
+}
+
+// GetStaffSales needs a from and to date, staffId narrows the report down
+// to one staff member and format=csv downloads it as a spreadsheet.
+func (c *ReportController) GetStaffSales(ctx echo.Context) error {
+	format := model.ReportFormat(ctx.QueryParam("format"))
+	if format == "" {
+		format = model.ReportJSON
+	}
+	if format != model.ReportJSON && format != model.ReportCSV {
+		resErr := customErr.NewBadRequestError("format must be json or csv")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	var params model.GetStaffSalesParam
+
+	from, err := parseHistoryTime(ctx.QueryParam("from"), false)
+	if err != nil {
+		resErr := customErr.NewBadRequestError("from must be a date or an RFC3339 time")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+	to, err := parseHistoryTime(ctx.QueryParam("to"), true)
+	if err != nil {
+		resErr := customErr.NewBadRequestError("to must be a date or an RFC3339 time")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+	params.From, params.To = from, to
+
+	if value := ctx.QueryParam("staffId"); value != "" {
+		staffId, err := uuid.Parse(value)
+		if err != nil {
+			resErr := customErr.NewBadRequestError("staffId must be a uuid")
+			return ctx.JSON(resErr.StatusCode, resErr)
+		}
+		params.StaffId = &staffId
+	}
+
+	sales, err := c.service.GetStaffSales(ctx.Request().Context(), params)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	if format == model.ReportCSV {
+		content, err := c.service.RenderStaffSalesCSV(sales)
+		if err != nil {
+			return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+		}
+
+		filename := "staff-sales-" + from.Format("2006-01-02") + "-" + to.Format("2006-01-02") + ".csv"
+		ctx.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+		return ctx.Blob(http.StatusOK, "text/csv; charset=utf-8", content)
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    sales,
+	})
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type SegmentController struct {
+	service  service.SegmentService
+	validate *validator.Validate
+}
+
+func NewSegmentController(service service.SegmentService, validate *validator.Validate) *SegmentController {
+	return &SegmentController{
+		service:  service,
+		validate: validate,
+	}
+}
+
+func (c *SegmentController) PostSegment(ctx echo.Context) error {
+	var segmentRequest model.CreateSegmentRequest
+	if err := ctx.Bind(&segmentRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&segmentRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	segment, err := c.service.CreateSegment(ctx.Request().Context(), segmentRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "Segment created successfully",
+		Data:    segment,
+	})
+}
+
+func (c *SegmentController) GetSegments(ctx echo.Context) error {
+	segments, err := c.service.GetSegments(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    segments,
+	})
+}
+
+func (c *SegmentController) GetSegment(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "segment is not found"})
+	}
+
+	segment, err := c.service.GetSegment(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    segment,
+	})
+}
+
+func (c *SegmentController) DeleteSegment(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "segment is not found"})
+	}
+
+	if err := c.service.DeleteSegment(ctx.Request().Context(), id); err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{Message: "Segment deleted successfully"})
+}
+
+func (c *SegmentController) PostRefresh(ctx echo.Context) error {
+	if err := c.service.Refresh(ctx.Request().Context()); err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{Message: "Tiers and segments refreshed"})
+}
@@ -4,10 +4,13 @@ import (
 	"eniqilo-store/model"
 	"eniqilo-store/pkg/customErr"
 	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
 	"net/http"
 	"regexp"
+	"strconv"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
@@ -40,14 +43,12 @@ func (c *StaffController) Register(ctx echo.Context) error {
 		Password:    newStaffReq.Password,
 	}
 
-	serviceRes, err := c.svc.Register(newStaff)
+	serviceRes, err := c.svc.Register(ctx.Request().Context(), newStaff, newStaffReq.InviteCode)
 	if err != nil {
-		switch err.Error() {
-		case "User already exist":
-			return ctx.JSON(http.StatusConflict, err.Error())
-		default:
-			return ctx.JSON(http.StatusInternalServerError, err.Error())
+		if resErr, ok := err.(customErr.CustomError); ok {
+			return ctx.JSON(resErr.StatusCode, err.Error())
 		}
+		return ctx.JSON(http.StatusInternalServerError, err.Error())
 	}
 
 	registerStaffResponse := model.RegisterStaffResponse{
@@ -56,6 +57,7 @@ func (c *StaffController) Register(ctx echo.Context) error {
 			UserId:      serviceRes.UserId,
 			Name:        newStaff.Name,
 			PhoneNumber: newStaff.PhoneNumber,
+			Role:        serviceRes.Role,
 			AccessToken: serviceRes.AccessToken,
 		},
 	}
@@ -75,9 +77,12 @@ func (c *StaffController) Login(ctx echo.Context) error {
 		return ctx.JSON(resErr.StatusCode, resErr)
 	}
 
-	serviceRes, err := c.svc.Login(loginReq)
+	serviceRes, err := c.svc.Login(ctx.Request().Context(), loginReq, ctx.RealIP())
 	if err != nil {
-		resErr := customErr.NewBadRequestError(err.Error())
+		resErr, ok := err.(customErr.CustomError)
+		if !ok {
+			resErr = customErr.NewBadRequestError(err.Error())
+		}
 		return ctx.JSON(resErr.StatusCode, resErr)
 	}
 
@@ -87,13 +92,393 @@ func (c *StaffController) Login(ctx echo.Context) error {
 			UserId:      serviceRes.UserId,
 			Name:        serviceRes.Name,
 			PhoneNumber: serviceRes.PhoneNumber,
+			Role:        serviceRes.Role,
 			AccessToken: serviceRes.AccessToken,
+
+			ChallengeToken:    serviceRes.ChallengeToken,
+			TwoFactorRequired: serviceRes.TwoFactorRequired,
 		},
 	}
 
 	return ctx.JSON(http.StatusOK, registerStaffResponse)
 }
 
+func (c *StaffController) LoginTwoFactor(ctx echo.Context) error {
+	var loginReq model.TwoFactorLoginRequest
+	if err := ctx.Bind(&loginReq); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&loginReq); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	serviceRes, err := c.svc.LoginTwoFactor(ctx.Request().Context(), loginReq, ctx.RealIP())
+	if err != nil {
+		resErr, ok := err.(customErr.CustomError)
+		if !ok {
+			resErr = customErr.NewBadRequestError(err.Error())
+		}
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	return ctx.JSON(http.StatusOK, model.RegisterStaffResponse{
+		Message: "User logged in successfully",
+		Data:    serviceRes,
+	})
+}
+
+func (c *StaffController) PostEnrolTwoFactor(ctx echo.Context) error {
+	var enrolRequest model.EnrolTwoFactorRequest
+	if err := ctx.Bind(&enrolRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&enrolRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	enrolment, err := c.svc.EnrolTwoFactor(ctx.Request().Context(), staffId, enrolRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Scan the code with an authenticator app and confirm it",
+		Data:    enrolment,
+	})
+}
+
+func (c *StaffController) PostConfirmTwoFactor(ctx echo.Context) error {
+	var confirmRequest model.ConfirmTwoFactorRequest
+	if err := ctx.Bind(&confirmRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&confirmRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	codes, err := c.svc.ConfirmTwoFactor(ctx.Request().Context(), staffId, confirmRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Two-factor authentication enabled, keep the recovery codes somewhere safe",
+		Data:    codes,
+	})
+}
+
+func (c *StaffController) PostDisableTwoFactor(ctx echo.Context) error {
+	var disableRequest model.DisableTwoFactorRequest
+	if err := ctx.Bind(&disableRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&disableRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.svc.DisableTwoFactor(ctx.Request().Context(), staffId, disableRequest); err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{Message: "Two-factor authentication disabled"})
+}
+
+func (c *StaffController) PinLogin(ctx echo.Context) error {
+	var loginReq model.PinLoginRequest
+	if err := ctx.Bind(&loginReq); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&loginReq); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	serviceRes, err := c.svc.PinLogin(ctx.Request().Context(), loginReq, ctx.Request().Header.Get("X-Device-Id"), ctx.RealIP())
+	if err != nil {
+		resErr, ok := err.(customErr.CustomError)
+		if !ok {
+			resErr = customErr.NewBadRequestError(err.Error())
+		}
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	return ctx.JSON(http.StatusOK, model.RegisterStaffResponse{
+		Message: "User logged in successfully",
+		Data:    serviceRes,
+	})
+}
+
+func (c *StaffController) PostPin(ctx echo.Context) error {
+	var pinRequest model.SetPinRequest
+	if err := ctx.Bind(&pinRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&pinRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.svc.SetPin(ctx.Request().Context(), staffId, pinRequest); err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{Message: "Pin updated"})
+}
+
+func (c *StaffController) GetStaffList(ctx echo.Context) error {
+	limit, err := strconv.Atoi(ctx.QueryParam("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	offset, err := strconv.Atoi(ctx.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	staff, err := c.svc.GetStaffList(ctx.Request().Context(), model.GetStaffParam{
+		Role:   ctx.QueryParam("role"),
+		Status: ctx.QueryParam("status"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    staff,
+	})
+}
+
+func (c *StaffController) GetStaff(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "staff is not found"})
+	}
+
+	staff, err := c.svc.GetStaff(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    staff,
+	})
+}
+
+func (c *StaffController) PatchStaffRole(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "staff is not found"})
+	}
+
+	var roleRequest model.UpdateStaffRoleRequest
+	if err := ctx.Bind(&roleRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&roleRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	adminId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staff, err := c.svc.UpdateRole(ctx.Request().Context(), adminId, id, model.StaffRole(*roleRequest.Role))
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Staff role updated",
+		Data:    staff,
+	})
+}
+
+func (c *StaffController) DeactivateStaff(ctx echo.Context) error {
+	return c.setDeactivated(ctx, true)
+}
+
+func (c *StaffController) ReactivateStaff(ctx echo.Context) error {
+	return c.setDeactivated(ctx, false)
+}
+
+func (c *StaffController) setDeactivated(ctx echo.Context, deactivated bool) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "staff is not found"})
+	}
+
+	adminId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staff, err := c.svc.SetDeactivated(ctx.Request().Context(), adminId, id, deactivated)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	message := "Staff reactivated"
+	if deactivated {
+		message = "Staff deactivated"
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: message,
+		Data:    staff,
+	})
+}
+
+func (c *StaffController) PostInvite(ctx echo.Context) error {
+	var inviteRequest model.CreateStaffInviteRequest
+	if err := ctx.Bind(&inviteRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&inviteRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	adminId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	invite, err := c.svc.CreateInvite(ctx.Request().Context(), adminId, model.StaffRole(*inviteRequest.Role))
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "Invite created",
+		Data:    invite,
+	})
+}
+
+func (c *StaffController) PostChangePassword(ctx echo.Context) error {
+	var passwordRequest model.ChangePasswordRequest
+	if err := ctx.Bind(&passwordRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&passwordRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staff, err := c.svc.ChangePassword(ctx.Request().Context(), staffId, passwordRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Password changed, other sessions have been signed out",
+		Data:    staff,
+	})
+}
+
+func (c *StaffController) PostPasswordReset(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "staff is not found"})
+	}
+
+	adminId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	reset, err := c.svc.IssuePasswordReset(ctx.Request().Context(), adminId, id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "Password reset token created",
+		Data:    reset,
+	})
+}
+
+func (c *StaffController) PostResetPassword(ctx echo.Context) error {
+	var resetRequest model.ResetPasswordRequest
+	if err := ctx.Bind(&resetRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&resetRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.svc.ResetPassword(ctx.Request().Context(), resetRequest); err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{Message: "Password has been reset"})
+}
+
 func validatePhoneNumber(fl validator.FieldLevel) bool {
 	phoneNumber := fl.Field().String()
 	// Regular expression to match the phone number pattern
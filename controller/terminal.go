@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type TerminalController struct {
+	service  service.TerminalService
+	validate *validator.Validate
+}
+
+func NewTerminalController(service service.TerminalService, validate *validator.Validate) *TerminalController {
+	return &TerminalController{
+		service:  service,
+		validate: validate,
+	}
+}
+
+func (c *TerminalController) PostTerminal(ctx echo.Context) error {
+	var terminalRequest model.RegisterTerminalRequest
+	if err := ctx.Bind(&terminalRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&terminalRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	terminal, err := c.service.RegisterTerminal(ctx.Request().Context(), staffId, terminalRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "Terminal registered",
+		Data:    terminal,
+	})
+}
+
+func (c *TerminalController) GetTerminals(ctx echo.Context) error {
+	terminals, err := c.service.GetTerminals(ctx.Request().Context())
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    terminals,
+	})
+}
+
+func (c *TerminalController) DeleteTerminal(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "terminal is not found"})
+	}
+
+	terminal, err := c.service.RevokeTerminal(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "Terminal revoked",
+		Data:    terminal,
+	})
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"context"
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/service"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type TimeClockController struct {
+	service  service.TimeClockService
+	validate *validator.Validate
+}
+
+func NewTimeClockController(service service.TimeClockService, validate *validator.Validate) *TimeClockController {
+	return &TimeClockController{
+		service:  service,
+		validate: validate,
+	}
+}
+
+func (c *TimeClockController) ClockIn(ctx echo.Context) error {
+	return c.clockAction(ctx, http.StatusCreated, "Clocked in", c.service.ClockIn)
+}
+
+func (c *TimeClockController) ClockOut(ctx echo.Context) error {
+	return c.clockAction(ctx, http.StatusOK, "Clocked out", c.service.ClockOut)
+}
+
+func (c *TimeClockController) StartBreak(ctx echo.Context) error {
+	return c.clockAction(ctx, http.StatusOK, "Break started", c.service.StartBreak)
+}
+
+func (c *TimeClockController) EndBreak(ctx echo.Context) error {
+	return c.clockAction(ctx, http.StatusOK, "Break ended", c.service.EndBreak)
+}
+
+func (c *TimeClockController) GetCurrentEntry(ctx echo.Context) error {
+	return c.clockAction(ctx, http.StatusOK, "success", c.service.GetCurrentEntry)
+}
+
+// clockAction runs action for the staff member of the token, the time clock
+// only ever acts on the caller's own shift.
+func (c *TimeClockController) clockAction(ctx echo.Context, status int, message string, action func(ctx context.Context, staffId uuid.UUID) (model.TimeEntry, error)) error {
+	staffId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	entry, err := action(ctx.Request().Context(), staffId)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(status, model.GenericResponse{
+		Message: message,
+		Data:    entry,
+	})
+}
+
+func (c *TimeClockController) GetEntries(ctx echo.Context) error {
+	entries, meta, err := c.service.GetEntries(ctx.Request().Context(), parseGetTimeEntryParams(ctx.QueryParams()))
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    entries,
+		Meta:    &meta,
+	})
+}
+
+func (c *TimeClockController) PostEntry(ctx echo.Context) error {
+	var entryRequest model.TimeEntryRequest
+	if err := ctx.Bind(&entryRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&entryRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	managerId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	entry, err := c.service.CreateEntry(ctx.Request().Context(), managerId, entryRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusCreated, model.GenericResponse{
+		Message: "success",
+		Data:    entry,
+	})
+}
+
+func (c *TimeClockController) PatchEntry(ctx echo.Context) error {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, model.GenericResponse{Message: "time entry is not found"})
+	}
+
+	var entryRequest model.TimeEntryRequest
+	if err := ctx.Bind(&entryRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	if err := c.validate.Struct(&entryRequest); err != nil {
+		resErr := customErr.NewBadRequestError(err.Error())
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	managerId, err := staffIdFromContext(ctx)
+	if err != nil {
+		resErr := customErr.NewUnauthorizedError("Unauthorized")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+
+	entry, err := c.service.CorrectEntry(ctx.Request().Context(), managerId, id, entryRequest)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    entry,
+	})
+}
+
+// GetAttendance needs a from and to date, staffId narrows the report down
+// to one staff member.
+func (c *TimeClockController) GetAttendance(ctx echo.Context) error {
+	var params model.GetAttendanceParam
+
+	from, err := parseHistoryTime(ctx.QueryParam("from"), false)
+	if err != nil {
+		resErr := customErr.NewBadRequestError("from must be a date or an RFC3339 time")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+	to, err := parseHistoryTime(ctx.QueryParam("to"), true)
+	if err != nil {
+		resErr := customErr.NewBadRequestError("to must be a date or an RFC3339 time")
+		return ctx.JSON(resErr.StatusCode, resErr)
+	}
+	params.From, params.To = from, to
+
+	if value := ctx.QueryParam("staffId"); value != "" {
+		staffId, err := uuid.Parse(value)
+		if err != nil {
+			resErr := customErr.NewBadRequestError("staffId must be a uuid")
+			return ctx.JSON(resErr.StatusCode, resErr)
+		}
+		params.StaffId = &staffId
+	}
+
+	attendance, err := c.service.GetAttendance(ctx.Request().Context(), params)
+	if err != nil {
+		return ctx.JSON(cerr.GetCode(err), model.GenericResponse{Message: err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, model.GenericResponse{
+		Message: "success",
+		Data:    attendance,
+	})
+}
+
+func parseGetTimeEntryParams(params url.Values) model.GetTimeEntryParam {
+	var result model.GetTimeEntryParam
+
+	for key, values := range params {
+		value := values[0]
+		switch key {
+		case "staffId":
+			staffId, err := uuid.Parse(value)
+			if err == nil {
+				result.StaffId = &staffId
+			}
+		case "from":
+			from, err := parseHistoryTime(value, false)
+			if err == nil {
+				result.From = &from
+			}
+		case "to":
+			to, err := parseHistoryTime(value, true)
+			if err == nil {
+				result.To = &to
+			}
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err == nil {
+				result.Limit = limit
+			}
+		case "offset":
+			offset, err := strconv.Atoi(value)
+			if err == nil {
+				result.Offset = offset
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "loyalty_point";
+
+ALTER TABLE "transaction"
+DROP COLUMN IF EXISTS "total",
+DROP COLUMN IF EXISTS "payments";
@@ -0,0 +1,15 @@
+ALTER TABLE "transaction"
+ADD COLUMN "total" int,
+ADD COLUMN "payments" JSONB NOT NULL DEFAULT '[]';
+
+CREATE TABLE "loyalty_point" (
+  "id" uuid PRIMARY KEY,
+  "customerId" uuid NOT NULL,
+  "transactionId" uuid,
+  "type" varchar NOT NULL,
+  "points" int NOT NULL,
+  "createdAt" timestamp
+);
+
+CREATE INDEX idx_loyalty_point_customerId ON loyalty_point ("customerId", "createdAt");
+CREATE INDEX idx_loyalty_point_transactionId ON loyalty_point ("transactionId");
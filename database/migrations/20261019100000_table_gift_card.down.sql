@@ -0,0 +1,5 @@
+ALTER TABLE "transaction"
+DROP COLUMN IF EXISTS "giftCards";
+
+DROP TABLE IF EXISTS "gift_card_movement";
+DROP TABLE IF EXISTS "gift_card";
@@ -0,0 +1,25 @@
+CREATE TABLE "gift_card" (
+  "id" uuid PRIMARY KEY,
+  "code" varchar(32) NOT NULL UNIQUE,
+  "type" varchar NOT NULL,
+  "customerId" uuid,
+  "initialValue" int NOT NULL,
+  "balance" int NOT NULL CHECK ("balance" >= 0),
+  "createdAt" timestamp
+);
+
+CREATE TABLE "gift_card_movement" (
+  "id" uuid PRIMARY KEY,
+  "giftCardId" uuid NOT NULL REFERENCES "gift_card" ("id"),
+  "transactionId" uuid,
+  "type" varchar NOT NULL,
+  "amount" int NOT NULL,
+  "balance" int NOT NULL,
+  "createdAt" timestamp
+);
+
+CREATE INDEX idx_gift_card_movement_giftCardId ON gift_card_movement ("giftCardId", "createdAt");
+CREATE INDEX idx_gift_card_movement_transactionId ON gift_card_movement ("transactionId");
+
+ALTER TABLE "transaction"
+ADD COLUMN "giftCards" JSONB NOT NULL DEFAULT '[]';
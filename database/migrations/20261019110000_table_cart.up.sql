@@ -0,0 +1,12 @@
+CREATE TABLE "cart" (
+  "id" uuid PRIMARY KEY,
+  "customerId" uuid,
+  "staffId" uuid NOT NULL,
+  "status" varchar NOT NULL,
+  "productDetails" JSONB NOT NULL DEFAULT '[]',
+  "transactionId" uuid,
+  "createdAt" timestamp,
+  "updatedAt" timestamp
+);
+
+CREATE INDEX idx_cart_status ON cart ("status", "updatedAt");
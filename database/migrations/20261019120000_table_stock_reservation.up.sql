@@ -0,0 +1,11 @@
+CREATE TABLE "stock_reservation" (
+  "id" uuid PRIMARY KEY,
+  "productId" uuid NOT NULL,
+  "holderId" uuid NOT NULL,
+  "quantity" int NOT NULL CHECK ("quantity" > 0),
+  "expiresAt" timestamp NOT NULL,
+  "createdAt" timestamp
+);
+
+CREATE INDEX idx_stock_reservation_productId ON stock_reservation ("productId", "expiresAt");
+CREATE INDEX idx_stock_reservation_holderId ON stock_reservation ("holderId");
@@ -0,0 +1,4 @@
+ALTER TABLE "transaction"
+DROP COLUMN IF EXISTS "receiptNumber";
+
+DROP TABLE IF EXISTS "receipt_sequence";
@@ -0,0 +1,9 @@
+CREATE TABLE "receipt_sequence" (
+  "storeCode" varchar NOT NULL,
+  "day" date NOT NULL,
+  "lastNumber" int NOT NULL,
+  PRIMARY KEY ("storeCode", "day")
+);
+
+ALTER TABLE "transaction"
+ADD COLUMN "receiptNumber" varchar UNIQUE;
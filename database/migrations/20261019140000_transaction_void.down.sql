@@ -0,0 +1,4 @@
+ALTER TABLE "transaction"
+DROP COLUMN IF EXISTS "voidedAt",
+DROP COLUMN IF EXISTS "voidReason",
+DROP COLUMN IF EXISTS "voidedBy";
@@ -0,0 +1,4 @@
+ALTER TABLE "transaction"
+ADD COLUMN "voidedAt" timestamp,
+ADD COLUMN "voidReason" varchar,
+ADD COLUMN "voidedBy" uuid;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "transaction_session_idx";
+
+ALTER TABLE "transaction"
+DROP COLUMN IF EXISTS "sessionId";
+
+DROP TABLE IF EXISTS "drawer_movement";
+DROP TABLE IF EXISTS "drawer_session";
@@ -0,0 +1,29 @@
+CREATE TABLE "drawer_session" (
+  "id" uuid PRIMARY KEY,
+  "staffId" uuid NOT NULL,
+  "status" varchar NOT NULL,
+  "openingFloat" int NOT NULL,
+  "countedCash" int,
+  "openedAt" timestamp NOT NULL,
+  "closedAt" timestamp
+);
+
+-- a staff member can only have one drawer open at a time
+CREATE UNIQUE INDEX "drawer_session_open_staff_idx" ON "drawer_session" ("staffId") WHERE "status" = 'open';
+
+CREATE TABLE "drawer_movement" (
+  "id" uuid PRIMARY KEY,
+  "sessionId" uuid NOT NULL REFERENCES "drawer_session" ("id"),
+  "staffId" uuid NOT NULL,
+  "type" varchar NOT NULL,
+  "amount" int NOT NULL,
+  "reason" varchar NOT NULL,
+  "createdAt" timestamp NOT NULL
+);
+
+CREATE INDEX "drawer_movement_session_idx" ON "drawer_movement" ("sessionId");
+
+ALTER TABLE "transaction"
+ADD COLUMN "sessionId" uuid;
+
+CREATE INDEX "transaction_session_idx" ON "transaction" ("sessionId");
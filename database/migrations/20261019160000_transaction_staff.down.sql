@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "transaction_staff_idx";
+
+ALTER TABLE "transaction"
+DROP COLUMN IF EXISTS "staffId";
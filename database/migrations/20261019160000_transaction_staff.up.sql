@@ -0,0 +1,4 @@
+ALTER TABLE "transaction"
+ADD COLUMN "staffId" uuid;
+
+CREATE INDEX "transaction_staff_idx" ON "transaction" ("staffId");
@@ -0,0 +1,5 @@
+ALTER TABLE "customer"
+DROP COLUMN IF EXISTS "email",
+DROP COLUMN IF EXISTS "birthday",
+DROP COLUMN IF EXISTS "notes",
+DROP COLUMN IF EXISTS "deletedAt";
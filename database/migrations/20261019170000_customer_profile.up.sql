@@ -0,0 +1,5 @@
+ALTER TABLE "customer"
+ADD COLUMN "email" varchar,
+ADD COLUMN "birthday" date,
+ADD COLUMN "notes" text,
+ADD COLUMN "deletedAt" timestamp;
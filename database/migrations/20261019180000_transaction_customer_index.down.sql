@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "transaction_customer_idx";
@@ -0,0 +1,2 @@
+-- customer summaries only look at sales that weren't voided
+CREATE INDEX "transaction_customer_idx" ON "transaction" ("customerId", "createdAt" DESC) INCLUDE ("total") WHERE "voidedAt" IS NULL;
@@ -0,0 +1,3 @@
+-- the original formatting of phone numbers is not kept, only the index is
+-- removed
+DROP INDEX IF EXISTS "customer_phone_number_idx";
@@ -0,0 +1,9 @@
+-- keep only the leading plus and the digits, e.g. +62-812 becomes +62812
+UPDATE "customer"
+SET "phoneNumber" = CASE
+  WHEN "phoneNumber" LIKE '+%' THEN '+' || regexp_replace("phoneNumber", '[^0-9]', '', 'g')
+  ELSE regexp_replace("phoneNumber", '[^0-9]', '', 'g')
+END
+WHERE "phoneNumber" ~ '[^0-9+]';
+
+CREATE INDEX "customer_phone_number_idx" ON "customer" ("phoneNumber");
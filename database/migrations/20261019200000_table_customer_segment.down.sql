@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "customer_segment_member";
+DROP TABLE IF EXISTS "customer_segment";
+
+ALTER TABLE "customer"
+DROP COLUMN IF EXISTS "tier";
@@ -0,0 +1,20 @@
+ALTER TABLE "customer"
+ADD COLUMN "tier" varchar NOT NULL DEFAULT 'standard';
+
+CREATE TABLE "customer_segment" (
+  "id" uuid PRIMARY KEY,
+  "name" varchar NOT NULL UNIQUE,
+  "description" varchar,
+  "rules" JSONB NOT NULL,
+  "refreshedAt" timestamp,
+  "createdAt" timestamp NOT NULL
+);
+
+CREATE TABLE "customer_segment_member" (
+  "segmentId" uuid NOT NULL REFERENCES "customer_segment" ("id") ON DELETE CASCADE,
+  "customerId" uuid NOT NULL,
+  "addedAt" timestamp NOT NULL,
+  PRIMARY KEY ("segmentId", "customerId")
+);
+
+CREATE INDEX "customer_segment_member_customer_idx" ON "customer_segment_member" ("customerId");
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "staff_invite";
+
+ALTER TABLE "staff"
+DROP COLUMN IF EXISTS "role",
+DROP COLUMN IF EXISTS "deactivatedAt";
@@ -0,0 +1,18 @@
+ALTER TABLE "staff"
+ADD COLUMN "role" varchar NOT NULL DEFAULT 'cashier',
+ADD COLUMN "deactivatedAt" timestamp;
+
+-- the first registered staff keeps running the store as its admin
+UPDATE "staff" SET "role" = 'admin'
+WHERE "userId" = (SELECT "userId" FROM "staff" ORDER BY "createdAt" LIMIT 1);
+
+CREATE TABLE "staff_invite" (
+  "id" uuid PRIMARY KEY,
+  "codeHash" varchar NOT NULL UNIQUE,
+  "role" varchar NOT NULL,
+  "createdBy" uuid NOT NULL REFERENCES "staff" ("userId"),
+  "expiresAt" timestamp NOT NULL,
+  "usedAt" timestamp,
+  "usedBy" uuid,
+  "createdAt" timestamp NOT NULL
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "staff_password_reset";
+
+ALTER TABLE "staff"
+DROP COLUMN IF EXISTS "tokenVersion";
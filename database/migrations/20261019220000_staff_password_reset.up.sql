@@ -0,0 +1,16 @@
+-- bumped whenever the password changes, tokens carrying an older version
+-- are rejected
+ALTER TABLE "staff"
+ADD COLUMN "tokenVersion" int NOT NULL DEFAULT 0;
+
+CREATE TABLE "staff_password_reset" (
+  "id" uuid PRIMARY KEY,
+  "staffId" uuid NOT NULL REFERENCES "staff" ("userId"),
+  "tokenHash" varchar NOT NULL UNIQUE,
+  "createdBy" uuid NOT NULL,
+  "expiresAt" timestamp NOT NULL,
+  "usedAt" timestamp,
+  "createdAt" timestamp NOT NULL
+);
+
+CREATE INDEX "staff_password_reset_staff_idx" ON "staff_password_reset" ("staffId");
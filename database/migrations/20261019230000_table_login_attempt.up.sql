@@ -0,0 +1,7 @@
+-- failed logins per phone number ("phone:...") and per client ip ("ip:...")
+CREATE TABLE "login_attempt" (
+  "key" varchar PRIMARY KEY,
+  "failures" int NOT NULL,
+  "lastFailedAt" timestamp NOT NULL,
+  "lockedUntil" timestamp
+);
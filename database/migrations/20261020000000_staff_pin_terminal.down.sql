@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "terminal";
+
+ALTER TABLE "staff"
+DROP COLUMN IF EXISTS "pinHash";
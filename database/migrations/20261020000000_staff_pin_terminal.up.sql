@@ -0,0 +1,14 @@
+ALTER TABLE "staff"
+ADD COLUMN "pinHash" varchar;
+
+-- shared tills allowed to use pin login, only a hash of the device id is
+-- stored
+CREATE TABLE "terminal" (
+  "id" uuid PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "deviceHash" varchar NOT NULL UNIQUE,
+  "createdBy" uuid NOT NULL,
+  "lastUsedAt" timestamp,
+  "revokedAt" timestamp,
+  "createdAt" timestamp NOT NULL
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "staff_recovery_code";
+
+ALTER TABLE "staff"
+DROP COLUMN IF EXISTS "totpSecret",
+DROP COLUMN IF EXISTS "totpEnabledAt",
+DROP COLUMN IF EXISTS "totpLastCounter";
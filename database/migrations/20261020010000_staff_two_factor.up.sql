@@ -0,0 +1,16 @@
+-- totpLastCounter is the time step of the last accepted code, a code can
+-- only be used once
+ALTER TABLE "staff"
+ADD COLUMN "totpSecret" varchar,
+ADD COLUMN "totpEnabledAt" timestamp,
+ADD COLUMN "totpLastCounter" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE "staff_recovery_code" (
+  "id" uuid PRIMARY KEY,
+  "staffId" uuid NOT NULL REFERENCES "staff" ("userId"),
+  "codeHash" varchar NOT NULL,
+  "usedAt" timestamp,
+  "createdAt" timestamp NOT NULL
+);
+
+CREATE INDEX "staff_recovery_code_staff_idx" ON "staff_recovery_code" ("staffId");
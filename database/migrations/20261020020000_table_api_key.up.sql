@@ -0,0 +1,12 @@
+CREATE TABLE "api_key" (
+  "id" uuid PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "prefix" varchar NOT NULL,
+  "keyHash" varchar NOT NULL UNIQUE,
+  "scopes" JSONB NOT NULL,
+  "createdBy" uuid NOT NULL,
+  "expiresAt" timestamp,
+  "lastUsedAt" timestamp,
+  "revokedAt" timestamp,
+  "createdAt" timestamp NOT NULL
+);
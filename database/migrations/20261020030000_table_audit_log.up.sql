@@ -0,0 +1,17 @@
+CREATE TABLE "audit_log" (
+  "id" uuid PRIMARY KEY,
+  "actorType" varchar NOT NULL,
+  "actorId" varchar,
+  "actorName" varchar NOT NULL DEFAULT '',
+  "action" varchar NOT NULL,
+  "entityType" varchar NOT NULL,
+  "entityId" varchar NOT NULL,
+  "before" JSONB,
+  "after" JSONB,
+  "requestId" varchar NOT NULL DEFAULT '',
+  "createdAt" timestamp NOT NULL
+);
+
+CREATE INDEX "audit_log_entity_idx" ON "audit_log" ("entityType", "entityId", "createdAt");
+CREATE INDEX "audit_log_actor_idx" ON "audit_log" ("actorId", "createdAt");
+CREATE INDEX "audit_log_created_at_idx" ON "audit_log" ("createdAt");
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "time_break";
+DROP TABLE IF EXISTS "time_entry";
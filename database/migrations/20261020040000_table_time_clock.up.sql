@@ -0,0 +1,25 @@
+CREATE TABLE "time_entry" (
+  "id" uuid PRIMARY KEY,
+  "staffId" uuid NOT NULL REFERENCES "staff" ("userId"),
+  "clockInAt" timestamp NOT NULL,
+  "clockOutAt" timestamp,
+  "correctedBy" uuid,
+  "correctedAt" timestamp,
+  "correctionReason" varchar,
+  "createdAt" timestamp NOT NULL
+);
+
+-- a staff member can only be clocked in once at a time
+CREATE UNIQUE INDEX "time_entry_open_staff_idx" ON "time_entry" ("staffId") WHERE "clockOutAt" IS NULL;
+CREATE INDEX "time_entry_staff_clock_in_idx" ON "time_entry" ("staffId", "clockInAt");
+CREATE INDEX "time_entry_clock_in_idx" ON "time_entry" ("clockInAt");
+
+CREATE TABLE "time_break" (
+  "id" uuid PRIMARY KEY,
+  "entryId" uuid NOT NULL REFERENCES "time_entry" ("id") ON DELETE CASCADE,
+  "startedAt" timestamp NOT NULL,
+  "endedAt" timestamp
+);
+
+CREATE UNIQUE INDEX "time_break_open_entry_idx" ON "time_break" ("entryId") WHERE "endedAt" IS NULL;
+CREATE INDEX "time_break_entry_idx" ON "time_break" ("entryId");
@@ -0,0 +1,2 @@
+-- the numbers fixed up are not restored
+SELECT 1;
@@ -0,0 +1,17 @@
+-- numbers saved without a country code were left as bare digits. The store
+-- is in Indonesia: a leading 0 is the national trunk prefix of +62 and a
+-- leading 62 is the country code typed without the plus. Other bare numbers
+-- can't be told apart and are left for staff to correct, they are listed by
+-- SELECT * FROM "customer" WHERE "phoneNumber" ~ '^[0-9]'
+UPDATE "customer"
+SET "phoneNumber" = CASE
+  WHEN "phoneNumber" LIKE '0%' THEN '+62' || substr("phoneNumber", 2)
+  ELSE '+' || "phoneNumber"
+END
+WHERE "phoneNumber" LIKE '0%' OR "phoneNumber" LIKE '62%';
+
+-- Indonesian numbers never start with 0 after the country code, a +620 is a
+-- trunk prefix kept from numbers typed as +62 (0)812
+UPDATE "customer"
+SET "phoneNumber" = '+62' || substr("phoneNumber", 5)
+WHERE "phoneNumber" LIKE '+620%';
@@ -0,0 +1,18 @@
+CREATE TABLE "customer_merge" (
+  "sourceId" uuid PRIMARY KEY,
+  "targetId" uuid NOT NULL,
+  "mergedAt" timestamp NOT NULL
+);
+
+CREATE INDEX "customer_merge_target_idx" ON "customer_merge" ("targetId");
+
+-- merges made so far only left their two audit entries, written in the same
+-- transaction: the source side has no after state, the target side has one
+INSERT INTO "customer_merge" ("sourceId", "targetId", "mergedAt")
+SELECT s."entityId"::uuid, t."entityId"::uuid, s."createdAt"
+FROM "audit_log" s
+JOIN "audit_log" t ON t."action" = s."action" AND t."entityType" = s."entityType"
+  AND t."requestId" = s."requestId" AND t."createdAt" = s."createdAt"
+  AND t."after" IS NOT NULL AND t."entityId" <> s."entityId"
+WHERE s."action" = 'customer.merge' AND s."entityType" = 'customer' AND s."after" IS NULL
+ON CONFLICT ("sourceId") DO NOTHING;
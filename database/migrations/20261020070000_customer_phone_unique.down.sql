@@ -0,0 +1,3 @@
+-- merged customers are not split again, only the index stops being unique
+DROP INDEX IF EXISTS "customer_phone_number_idx";
+CREATE INDEX "customer_phone_number_idx" ON "customer" ("phoneNumber");
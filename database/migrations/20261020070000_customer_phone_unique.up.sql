@@ -0,0 +1,48 @@
+-- active customers sharing a number are merged into the oldest of them the
+-- way /customer/:id/merge does it, so the number can be made unique
+CREATE TEMPORARY TABLE "phone_duplicate" AS
+SELECT c."userId" AS "sourceId", k."userId" AS "targetId"
+FROM "customer" c
+JOIN LATERAL (
+  SELECT o."userId" FROM "customer" o
+  WHERE o."phoneNumber" = c."phoneNumber" AND o."deletedAt" IS NULL
+  ORDER BY o."createdAt", o."userId"
+  LIMIT 1
+) k ON k."userId" <> c."userId"
+WHERE c."deletedAt" IS NULL AND c."phoneNumber" <> '';
+
+UPDATE "transaction" t SET "customerId" = d."targetId" FROM "phone_duplicate" d WHERE t."customerId" = d."sourceId";
+UPDATE "loyalty_point" p SET "customerId" = d."targetId" FROM "phone_duplicate" d WHERE p."customerId" = d."sourceId";
+UPDATE "gift_card" g SET "customerId" = d."targetId" FROM "phone_duplicate" d WHERE g."customerId" = d."sourceId";
+UPDATE "cart" c SET "customerId" = d."targetId" FROM "phone_duplicate" d WHERE c."customerId" = d."sourceId";
+-- memberships of the target are recomputed by the next segment refresh
+DELETE FROM "customer_segment_member" m USING "phone_duplicate" d WHERE m."customerId" = d."sourceId";
+
+UPDATE "customer" t SET
+  "email" = COALESCE(t."email", s."email"),
+  "birthday" = COALESCE(t."birthday", s."birthday"),
+  "notes" = CASE
+    WHEN s."notes" IS NULL THEN t."notes"
+    WHEN t."notes" IS NULL THEN s."notes"
+    ELSE t."notes" || E'\n' || s."notes"
+  END
+FROM (
+  SELECT d."targetId",
+    (ARRAY_AGG(c."email" ORDER BY c."createdAt") FILTER (WHERE c."email" IS NOT NULL))[1] AS "email",
+    (ARRAY_AGG(c."birthday" ORDER BY c."createdAt") FILTER (WHERE c."birthday" IS NOT NULL))[1] AS "birthday",
+    STRING_AGG(c."notes", E'\n' ORDER BY c."createdAt") AS "notes"
+  FROM "phone_duplicate" d
+  JOIN "customer" c ON c."userId" = d."sourceId"
+  GROUP BY d."targetId"
+) s
+WHERE t."userId" = s."targetId";
+
+INSERT INTO "customer_merge" ("sourceId", "targetId", "mergedAt")
+SELECT "sourceId", "targetId", NOW() FROM "phone_duplicate";
+
+DELETE FROM "customer" c USING "phone_duplicate" d WHERE c."userId" = d."sourceId";
+
+DROP TABLE "phone_duplicate";
+
+DROP INDEX IF EXISTS "customer_phone_number_idx";
+CREATE UNIQUE INDEX "customer_phone_number_idx" ON "customer" ("phoneNumber") WHERE "deletedAt" IS NULL;
@@ -0,0 +1,9 @@
+ALTER TABLE "time_break"
+ALTER COLUMN "startedAt" TYPE timestamp,
+ALTER COLUMN "endedAt" TYPE timestamp;
+
+ALTER TABLE "time_entry"
+ALTER COLUMN "clockInAt" TYPE timestamp,
+ALTER COLUMN "clockOutAt" TYPE timestamp,
+ALTER COLUMN "correctedAt" TYPE timestamp,
+ALTER COLUMN "createdAt" TYPE timestamp;
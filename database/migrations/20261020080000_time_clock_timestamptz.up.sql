@@ -0,0 +1,12 @@
+-- shifts entered by a manager carry their own offset, with timestamptz they
+-- land on the same clock as the NOW() of clock-ins. Existing values were
+-- written in the database time zone and are read in it here.
+ALTER TABLE "time_entry"
+ALTER COLUMN "clockInAt" TYPE timestamptz,
+ALTER COLUMN "clockOutAt" TYPE timestamptz,
+ALTER COLUMN "correctedAt" TYPE timestamptz,
+ALTER COLUMN "createdAt" TYPE timestamptz;
+
+ALTER TABLE "time_break"
+ALTER COLUMN "startedAt" TYPE timestamptz,
+ALTER COLUMN "endedAt" TYPE timestamptz;
@@ -4,6 +4,7 @@ import (
 	"context"
 	"eniqilo-store/config"
 	"eniqilo-store/database"
+	"eniqilo-store/pkg/crypto"
 	"eniqilo-store/pkg/log"
 	"eniqilo-store/server"
 
@@ -28,6 +29,18 @@ func main() {
 		panic(err)
 	}
 
+	keys, err := crypto.LoadKeySet(cfg.JWT)
+	if err != nil {
+		logger.Error("error loading jwt keys", zap.Error(err))
+		panic(err)
+	}
+
+	secrets, err := crypto.NewSecretBox(cfg.Staff.TwoFactor.SecretKey)
+	if err != nil {
+		logger.Error("error loading two-factor secret key", zap.Error(err))
+		panic(err)
+	}
+
 	db, err := database.NewDatabase(cfg)
 	if err != nil {
 		logger.Error("error opening database", zap.Error(err))
@@ -36,7 +49,8 @@ func main() {
 	defer db.Close()
 
 	s := server.NewServer(db, logger)
-	s.RegisterRoute(cfg)
+	s.RegisterRoute(cfg, keys, secrets)
+	s.StartWorkers(ctx, cfg)
 
 	logger.Fatal("failed run app", zap.Error(s.Run()))
 }
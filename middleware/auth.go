@@ -1,16 +1,27 @@
 package middleware
 
 import (
+	"eniqilo-store/config"
+	"eniqilo-store/model"
 	"eniqilo-store/pkg/crypto"
 	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/repo"
 	"errors"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-func Authentication(secret string) echo.MiddlewareFunc {
+// Authentication checks the bearer token and that the staff it was issued
+// to still exists and is active, deactivating an account locks it out
+// right away instead of when its tokens expire.
+// Tokens of a pin login are only accepted from their terminal, which sends
+// its device id in the X-Device-Id header. Staff whose role is listed in
+// twoFactor but who haven't set it up yet are turned away, the enrolment
+// endpoints use a second instance with an empty config.
+func Authentication(keys *crypto.KeySet, staffRepo repo.StaffRepo, terminalRepo repo.TerminalRepo, twoFactor config.TwoFactorConfig) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			token := strings.Replace(c.Request().Header.Get("Authorization"), "Bearer ", "", -1)
@@ -20,7 +31,7 @@ func Authentication(secret string) echo.MiddlewareFunc {
 				return c.JSON(resErr.StatusCode, resErr)
 			}
 
-			payload, err := crypto.VerifyToken(token, secret)
+			payload, err := crypto.VerifyToken(token, keys)
 			if err != nil {
 				resErr := customErr.NewUnauthorizedError("Unauthorized")
 				if errors.Is(err, jwt.ErrTokenExpired) {
@@ -29,10 +40,158 @@ func Authentication(secret string) echo.MiddlewareFunc {
 				return c.JSON(resErr.StatusCode, resErr)
 			}
 
+			if payload.Purpose != "" {
+				resErr := customErr.NewUnauthorizedError("Unauthorized")
+				return c.JSON(resErr.StatusCode, resErr)
+			}
+
+			staffId, err := uuid.Parse(payload.Id)
+			if err != nil {
+				resErr := customErr.NewUnauthorizedError("Unauthorized")
+				return c.JSON(resErr.StatusCode, resErr)
+			}
+
+			staff, err := staffRepo.GetStaffById(c.Request().Context(), staffId)
+			if err != nil {
+				resErr := customErr.NewUnauthorizedError("Unauthorized")
+				return c.JSON(resErr.StatusCode, resErr)
+			}
+
+			if staff.DeactivatedAt != nil {
+				resErr := customErr.NewUnauthorizedError("Account is deactivated")
+				return c.JSON(resErr.StatusCode, resErr)
+			}
+
+			// tokens issued before the last password change are revoked
+			if payload.Version != staff.TokenVersion {
+				resErr := customErr.NewUnauthorizedError("Token revoked")
+				return c.JSON(resErr.StatusCode, resErr)
+			}
+			payload.Role = staff.Role
+
+			if staff.TotpEnabledAt == nil && twoFactor.Requires(string(staff.Role)) {
+				resErr := customErr.NewForbiddenError("Two-factor authentication has to be set up first")
+				return c.JSON(resErr.StatusCode, resErr)
+			}
+
+			if payload.TerminalId != "" {
+				// a pin is a single factor, pin tokens issued before two-factor
+				// was set up stop working with it
+				if staff.TotpEnabledAt != nil || twoFactor.Requires(string(staff.Role)) {
+					resErr := customErr.NewUnauthorizedError("Token revoked")
+					return c.JSON(resErr.StatusCode, resErr)
+				}
+
+				terminalId, err := uuid.Parse(payload.TerminalId)
+				if err != nil {
+					resErr := customErr.NewUnauthorizedError("Unauthorized")
+					return c.JSON(resErr.StatusCode, resErr)
+				}
+
+				terminal, err := terminalRepo.GetTerminalById(c.Request().Context(), terminalId)
+				deviceHash := crypto.HashToken(c.Request().Header.Get("X-Device-Id"))
+				if err != nil || terminal.RevokedAt != nil || terminal.DeviceHash != deviceHash {
+					resErr := customErr.NewUnauthorizedError("Token is bound to another terminal")
+					return c.JSON(resErr.StatusCode, resErr)
+				}
+			}
+
 			// Add user data to the request context
 			c.Set("userData", payload)
+			setAuditActor(c, model.AuditActor{Type: model.AuditActorStaff, Id: payload.Id, Name: payload.Name})
 
 			return next(c)
 		}
 	}
 }
+
+// RequireRole only lets staff with one of roles through, it has to run
+// after Authentication.
+func RequireRole(roles ...model.StaffRole) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			payload, ok := c.Get("userData").(*model.JWTPayload)
+			if !ok {
+				resErr := customErr.NewUnauthorizedError("Unauthorized")
+				return c.JSON(resErr.StatusCode, resErr)
+			}
+
+			for _, role := range roles {
+				if payload.Role == role {
+					return next(c)
+				}
+			}
+
+			resErr := customErr.NewForbiddenError("Forbidden")
+			return c.JSON(resErr.StatusCode, resErr)
+		}
+	}
+}
+
+// PasswordLogin keeps tokens of a pin login away from manager operations,
+// it has to run after Authentication.
+func PasswordLogin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		payload, ok := c.Get("userData").(*model.JWTPayload)
+		if !ok {
+			resErr := customErr.NewUnauthorizedError("Unauthorized")
+			return c.JSON(resErr.StatusCode, resErr)
+		}
+
+		if payload.TerminalId != "" {
+			resErr := customErr.NewForbiddenError("This action requires a password login")
+			return c.JSON(resErr.StatusCode, resErr)
+		}
+
+		return next(c)
+	}
+}
+
+// ScopedAuth builds the authentication of a route that integrations may
+// call with an API key carrying scope.
+type ScopedAuth func(scope model.APIKeyScope) echo.MiddlewareFunc
+
+// APIKeyOr accepts an API key with the route's scope in place of the
+// access token checked by auth. Routes that don't use it never accept API
+// keys.
+func APIKeyOr(auth echo.MiddlewareFunc, apiKeyRepo repo.APIKeyRepo) ScopedAuth {
+	return func(scope model.APIKeyScope) echo.MiddlewareFunc {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			withToken := auth(next)
+
+			return func(c echo.Context) error {
+				token := strings.Replace(c.Request().Header.Get("Authorization"), "Bearer ", "", -1)
+				if !strings.HasPrefix(token, model.APIKeyPrefix) {
+					return withToken(c)
+				}
+
+				key, err := apiKeyRepo.GetActiveAPIKeyByHash(c.Request().Context(), crypto.HashToken(token))
+				if err != nil {
+					resErr := customErr.NewUnauthorizedError("Invalid API key")
+					return c.JSON(resErr.StatusCode, resErr)
+				}
+
+				if !key.HasScope(scope) {
+					resErr := customErr.NewForbiddenError("API key lacks the " + string(scope) + " scope")
+					return c.JSON(resErr.StatusCode, resErr)
+				}
+
+				_ = apiKeyRepo.TouchAPIKey(c.Request().Context(), key.ID)
+
+				c.Set("userData", &model.JWTPayload{
+					Name:     key.Name,
+					APIKeyId: key.ID.String(),
+				})
+				setAuditActor(c, model.AuditActor{Type: model.AuditActorAPIKey, Id: key.ID.String(), Name: key.Name})
+
+				return next(c)
+			}
+		}
+	}
+}
+
+// setAuditActor hands the caller to the services, which only see the
+// request context
+func setAuditActor(c echo.Context, actor model.AuditActor) {
+	c.SetRequest(c.Request().WithContext(model.ContextWithAuditActor(c.Request().Context(), actor)))
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, it tells them apart from access
+// tokens in the Authorization header.
+const APIKeyPrefix = "eqk_"
+
+// APIKeyScope is what an integration is allowed to do with its key
+type APIKeyScope string
+
+const (
+	ScopeProductsRead     APIKeyScope = "products:read"
+	ScopeProductsWrite    APIKeyScope = "products:write"
+	ScopeTransactionsRead APIKeyScope = "transactions:read"
+	ScopeCustomersRead    APIKeyScope = "customers:read"
+)
+
+// APIKey lets another system call the API without a staff login. Only a
+// hash of the key is stored, Key is filled once when it is created and
+// Prefix is kept to recognise it in the list.
+type APIKey struct {
+	ID         uuid.UUID     `json:"id"`
+	Name       string        `json:"name"`
+	Key        string        `json:"key,omitempty"`
+	Prefix     string        `json:"prefix"`
+	KeyHash    string        `json:"-"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	CreatedBy  uuid.UUID     `json:"createdBy"`
+	ExpiresAt  *time.Time    `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time    `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time    `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt"`
+}
+
+func (k APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateAPIKeyRequest struct {
+	Name      *string    `json:"name" validate:"required,min=1,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=products:read products:write transactions:read customers:read"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditActorType tells who made a change. Anonymous is used by the public
+// endpoints, e.g. a staff member registering with an invite.
+type AuditActorType string
+
+const (
+	AuditActorStaff     AuditActorType = "staff"
+	AuditActorAPIKey    AuditActorType = "apiKey"
+	AuditActorAnonymous AuditActorType = "anonymous"
+)
+
+type AuditEntityType string
+
+const (
+	AuditProduct     AuditEntityType = "product"
+	AuditCustomer    AuditEntityType = "customer"
+	AuditStaff       AuditEntityType = "staff"
+	AuditTransaction AuditEntityType = "transaction"
+	AuditTimeEntry   AuditEntityType = "timeEntry"
+	AuditAPIKey      AuditEntityType = "apiKey"
+	AuditTerminal    AuditEntityType = "terminal"
+)
+
+// AuditAction is named <entity>.<verb>
+type AuditAction string
+
+const (
+	AuditProductCreate AuditAction = "product.create"
+	AuditProductUpdate AuditAction = "product.update"
+	AuditProductDelete AuditAction = "product.delete"
+
+	AuditCustomerCreate    AuditAction = "customer.create"
+	AuditCustomerUpdate    AuditAction = "customer.update"
+	AuditCustomerDelete    AuditAction = "customer.delete"
+	AuditCustomerAnonymise AuditAction = "customer.anonymise"
+	AuditCustomerMerge     AuditAction = "customer.merge"
+	AuditCustomerErase     AuditAction = "customer.erase"
+
+	AuditStaffRegister       AuditAction = "staff.register"
+	AuditStaffInvite         AuditAction = "staff.invite"
+	AuditStaffRoleChange     AuditAction = "staff.roleChange"
+	AuditStaffDeactivate     AuditAction = "staff.deactivate"
+	AuditStaffReactivate     AuditAction = "staff.reactivate"
+	AuditStaffPasswordChange AuditAction = "staff.passwordChange"
+	AuditStaffPasswordReset  AuditAction = "staff.passwordReset"
+	AuditStaffResetIssue     AuditAction = "staff.passwordResetIssue"
+	AuditStaffPinSet         AuditAction = "staff.pinSet"
+	AuditStaffTwoFactorOn    AuditAction = "staff.twoFactorEnable"
+	AuditStaffTwoFactorOff   AuditAction = "staff.twoFactorDisable"
+
+	AuditTransactionCreate AuditAction = "transaction.create"
+	AuditTransactionVoid   AuditAction = "transaction.void"
+
+	AuditTimeEntryCreate  AuditAction = "timeEntry.create"
+	AuditTimeEntryCorrect AuditAction = "timeEntry.correct"
+
+	AuditAPIKeyCreate AuditAction = "apiKey.create"
+	AuditAPIKeyRevoke AuditAction = "apiKey.revoke"
+
+	AuditTerminalRegister AuditAction = "terminal.register"
+	AuditTerminalRevoke   AuditAction = "terminal.revoke"
+)
+
+// AuditEntry records one change. Before is empty for creations and After
+// for deletions, RequestId matches the X-Request-Id response header.
+type AuditEntry struct {
+	ID         uuid.UUID       `json:"id" db:"id"`
+	ActorType  AuditActorType  `json:"actorType" db:"actorType"`
+	ActorId    *string         `json:"actorId,omitempty" db:"actorId"`
+	ActorName  string          `json:"actorName,omitempty" db:"actorName"`
+	Action     AuditAction     `json:"action" db:"action"`
+	EntityType AuditEntityType `json:"entityType" db:"entityType"`
+	EntityId   string          `json:"entityId" db:"entityId"`
+	Before     json.RawMessage `json:"before,omitempty" db:"before"`
+	After      json.RawMessage `json:"after,omitempty" db:"after"`
+	RequestId  string          `json:"requestId,omitempty" db:"requestId"`
+	CreatedAt  time.Time       `json:"createdAt" db:"createdAt"`
+}
+
+type GetAuditParam struct {
+	ActorType  *string
+	ActorId    *string
+	Action     *string
+	EntityType *string
+	EntityId   *string
+	RequestId  *string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditActor is put in the request context by the authentication
+// middleware so services can tell who made a change.
+type AuditActor struct {
+	Type AuditActorType
+	Id   string
+	Name string
+}
+
+type auditContextKey int
+
+const (
+	auditActorKey auditContextKey = iota
+	requestIdKey
+)
+
+func ContextWithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey, actor)
+}
+
+// AuditActorFromContext falls back to an anonymous actor on the public
+// endpoints.
+func AuditActorFromContext(ctx context.Context) AuditActor {
+	actor, ok := ctx.Value(auditActorKey).(AuditActor)
+	if !ok {
+		return AuditActor{Type: AuditActorAnonymous}
+	}
+	return actor
+}
+
+func ContextWithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey, requestId)
+}
+
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey).(string)
+	return requestId
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CartStatus is the lifecycle state of a server-side cart. Only open carts
+// can be changed, held carts are parked until they are resumed.
+type CartStatus string
+
+const (
+	CartOpen        CartStatus = "open"
+	CartHeld        CartStatus = "held"
+	CartCheckingOut CartStatus = "checkingOut"
+	CartCheckedOut  CartStatus = "checkedOut"
+)
+
+type Cart struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	CustomerId     *uuid.UUID      `json:"customerId" db:"customerId"`
+	StaffId        uuid.UUID       `json:"staffId" db:"staffId"`
+	Status         CartStatus      `json:"status" db:"status"`
+	ProductDetails []ProductDetail `json:"productDetails" db:"productDetails"`
+	TransactionId  *uuid.UUID      `json:"transactionId" db:"transactionId"`
+	CreatedAt      time.Time       `json:"createdAt" db:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt" db:"updatedAt"`
+}
+
+// CartLine is a cart line priced with the current product data. Error is
+// set when the line can't be sold anymore, e.g. the stock ran out.
+type CartLine struct {
+	ProductId string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+	LineTotal int    `json:"lineTotal"`
+	Error     string `json:"error,omitempty"`
+}
+
+type CartView struct {
+	Cart
+	Lines []CartLine `json:"lines"`
+	Total int        `json:"total"`
+}
+
+type CreateCartRequest struct {
+	CustomerId *string `json:"customerId" validate:"omitempty,uuid"`
+}
+
+type CartItemRequest struct {
+	ProductId string `json:"productId" validate:"required,uuid"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+}
+
+type CartCustomerRequest struct {
+	CustomerId *string `json:"customerId" validate:"required,uuid"`
+}
+
+type CartCheckoutRequest struct {
+	GiftCards []GiftCardSale `json:"giftCards" validate:"dive"`
+	Payments  []Payment      `json:"payments" validate:"dive"`
+	Paid      *int           `json:"paid" validate:"required"`
+	Change    *int           `json:"change" validate:"required"`
+}
@@ -7,10 +7,32 @@ import (
 )
 
 type Customer struct {
-	UserId      string    `json:"userId" db:"userId"`
-	PhoneNumber string    `json:"phoneNumber" db:"phoneNumber"`
-	Name        string    `json:"name" db:"name"`
-	CreatedAt   time.Time `json:"-" db:"createdAt"`
+	UserId      string       `json:"userId" db:"userId"`
+	PhoneNumber string       `json:"phoneNumber" db:"phoneNumber"`
+	Name        string       `json:"name" db:"name"`
+	Email       *string      `json:"email,omitempty" db:"email"`
+	Birthday    *string      `json:"birthday,omitempty" db:"birthday"`
+	Notes       *string      `json:"notes,omitempty" db:"notes"`
+	Tier        CustomerTier `json:"tier" db:"tier"`
+	CreatedAt   time.Time    `json:"-" db:"createdAt"`
+	DeletedAt   *time.Time   `json:"-" db:"deletedAt"`
+}
+
+// UpdateCustomerRequest changes only the fields that are sent. An empty
+// email, birthday or notes clears it.
+type UpdateCustomerRequest struct {
+	PhoneNumber *string `json:"phoneNumber" validate:"omitempty,phone_number"`
+	Name        *string `json:"name" validate:"omitempty,min=5,max=50"`
+	Email       *string `json:"email" validate:"omitempty,email,max=255"`
+	Birthday    *string `json:"birthday" validate:"omitempty,datetime=2006-01-02"`
+	Notes       *string `json:"notes" validate:"omitempty,max=1000"`
+}
+
+// DeleteCustomerResult tells whether the customer was removed or, having
+// history that has to be kept, anonymised.
+type DeleteCustomerResult struct {
+	UserId     string `json:"userId"`
+	Anonymised bool   `json:"anonymised"`
 }
 
 type CustomerRequest struct {
@@ -28,22 +50,60 @@ type ErrorMessageOrder struct {
 	StatusCode int    `json:"status"`
 }
 
+// ProductDetail is a line of an order. Name and Price are snapshotted at
+// checkout so receipts keep showing what was actually charged.
 type ProductDetail struct {
 	ProductId string `json:"productId"`
 	Quantity  int    `json:"quantity"`
+	Name      string `json:"name,omitempty"`
+	Price     int    `json:"price,omitempty"`
+}
+
+// PaymentMethod is a non-cash tender accepted at checkout. Cash is still
+// represented by the paid and change fields of the order.
+type PaymentMethod string
+
+const (
+	// PaymentCash is only used to filter the history, cash tenders are not
+	// part of the payments list
+	PaymentCash     PaymentMethod = "cash"
+	PaymentPoints   PaymentMethod = "points"
+	PaymentGiftCard PaymentMethod = "giftCard"
+)
+
+// Payment is a non-cash tender. Reference holds the gift card code when
+// paying with a gift card.
+type Payment struct {
+	Method    PaymentMethod `json:"method" validate:"required,oneof=points giftCard"`
+	Amount    int           `json:"amount" validate:"required,min=1"`
+	Reference string        `json:"reference,omitempty" validate:"required_if=Method giftCard"`
 }
 
 type OrderRequest struct {
 	CustomerId     *string         `json:"customerId" validate:"required"`
 	ProductDetails []ProductDetail `json:"productDetails"`
+	GiftCards      []GiftCardSale  `json:"giftCards" validate:"dive"`
+	Payments       []Payment       `json:"payments" validate:"dive"`
 	Paid           *int            `json:"paid" validate:"required"`
 	Change         *int            `json:"change" validate:"required"`
 }
 
 type CustomerResponseData struct {
-	UserId      string `json:"userId" db:"userId"`
-	PhoneNumber string `json:"phoneNumber" db:"phoneNumber"`
-	Name        string `json:"name" db:"name"`
+	UserId      string       `json:"userId" db:"userId"`
+	PhoneNumber string       `json:"phoneNumber" db:"phoneNumber"`
+	Name        string       `json:"name" db:"name"`
+	Tier        CustomerTier `json:"tier,omitempty" db:"tier"`
+}
+
+// GetCustomerParam filters the customer list. Segment is the id or the name
+// of a customer segment.
+type GetCustomerParam struct {
+	Name        string
+	PhoneNumber string
+	Segment     string
+	Tier        string
+	Limit       int
+	Offset      int
 }
 type ResponseCustomerList struct {
 	Message string                 `json:"message"`
@@ -52,21 +112,131 @@ type ResponseCustomerList struct {
 
 type Transaction struct {
 	TransactionId  uuid.UUID       `json:"transactionId" db:"transactionId"`
+	ReceiptNumber  string          `json:"receiptNumber" db:"receiptNumber"`
+	SessionId      *uuid.UUID      `json:"sessionId" db:"sessionId"`
+	StaffId        *uuid.UUID      `json:"staffId" db:"staffId"`
+	CashierName    string          `json:"cashierName" db:"cashierName"`
 	CustomerId     uuid.UUID       `json:"customerId" db:"customerId"`
 	ProductDetails []ProductDetail `json:"productDetails" db:"productDetails"`
+	GiftCards      []GiftCardSale  `json:"giftCards" db:"giftCards"`
+	Total          int             `json:"total" db:"total"`
+	Payments       []Payment       `json:"payments" db:"payments"`
 	Paid           int             `json:"paid" db:"paid"`
 	Change         int             `json:"change" db:"change"`
 	CreatedAt      time.Time       `json:"createdAt" db:"createdAt"`
+	VoidedAt       *time.Time      `json:"voidedAt,omitempty" db:"voidedAt"`
+	VoidReason     string          `json:"voidReason,omitempty" db:"voidReason"`
+	VoidedBy       *uuid.UUID      `json:"voidedBy,omitempty" db:"voidedBy"`
+}
+
+// VoidTransactionRequest cancels a mistaken sale, the manager approving
+// the void is taken from the token.
+type VoidTransactionRequest struct {
+	Reason *string `json:"reason" validate:"required,min=1,max=255"`
+}
+
+// TransactionStatus filters the history, voided sales are left out unless
+// asked for.
+type TransactionStatus string
+
+const (
+	TransactionCompleted TransactionStatus = "completed"
+	TransactionVoided    TransactionStatus = "voided"
+	TransactionAll       TransactionStatus = "all"
+)
+
+type CheckoutResponseData struct {
+	TransactionId uuid.UUID      `json:"transactionId"`
+	ReceiptNumber string         `json:"receiptNumber"`
+	GiftCards     []GiftCardSale `json:"giftCards,omitempty"`
 }
 
 type GenericResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	Meta    *PageMeta   `json:"meta,omitempty"`
+}
+
+// PageMeta tells paginated lists how many rows match in total
+type PageMeta struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
 }
 
 type GetHistoryParam struct {
-	CustomerId *uuid.UUID
-	Limit      int
-	Offset     int
-	CreatedAt  *string
+	CustomerId    *uuid.UUID
+	ReceiptNumber *string
+	StaffId       *uuid.UUID
+	Status        TransactionStatus
+	From          *time.Time
+	To            *time.Time
+	MinTotal      *int
+	MaxTotal      *int
+	ProductId     *string
+	PaymentMethod *PaymentMethod
+	Limit         int
+	Offset        int
+	CreatedAt     *string
+}
+
+// CustomerSummary is the purchase history of a customer at a glance,
+// voided sales are not counted.
+type CustomerSummary struct {
+	CustomerId          string            `json:"customerId"`
+	TotalSpend          int               `json:"totalSpend"`
+	Visits              int               `json:"visits"`
+	AverageBasket       int               `json:"averageBasket"`
+	LastVisit           *time.Time        `json:"lastVisit"`
+	FavouriteCategories []CategorySummary `json:"favouriteCategories"`
+	TopProducts         []ProductSummary  `json:"topProducts"`
+}
+
+type CategorySummary struct {
+	Category Category `json:"category"`
+	Quantity int      `json:"quantity"`
+}
+
+type ProductSummary struct {
+	ProductId string `json:"productId"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	Spend     int    `json:"spend"`
+}
+
+// DuplicateGroup is a set of customers that are likely the same person,
+// Reason tells what they share.
+type DuplicateGroup struct {
+	Reason    string                 `json:"reason"`
+	Key       string                 `json:"key"`
+	Customers []CustomerResponseData `json:"customers"`
+}
+
+// MergeCustomerRequest names the customer merged into the one in the path,
+// the source customer is removed afterwards.
+type MergeCustomerRequest struct {
+	SourceId *string `json:"sourceId" validate:"required,uuid"`
+}
+
+// CustomerExport is everything stored about a customer, handed out on a
+// data subject access request.
+type CustomerExport struct {
+	ExportedAt   time.Time      `json:"exportedAt"`
+	Profile      CustomerData   `json:"profile"`
+	Transactions []Transaction  `json:"transactions"`
+	Loyalty      CustomerPoints `json:"loyalty"`
+	GiftCards    []GiftCard     `json:"giftCards"`
+}
+
+// CustomerData is the full profile of a customer, Customer hides the
+// registration date from the regular responses.
+type CustomerData struct {
+	Customer
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// EraseCustomerResult holds the pseudonym that replaced the customer's name
+type EraseCustomerResult struct {
+	UserId    string `json:"userId"`
+	Pseudonym string `json:"pseudonym"`
 }
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DrawerSessionStatus is the state of a till shift. Checkouts are booked on
+// the open session of the staff member at the register.
+type DrawerSessionStatus string
+
+const (
+	DrawerOpen   DrawerSessionStatus = "open"
+	DrawerClosed DrawerSessionStatus = "closed"
+)
+
+type DrawerSession struct {
+	ID           uuid.UUID           `json:"id" db:"id"`
+	StaffId      uuid.UUID           `json:"staffId" db:"staffId"`
+	Status       DrawerSessionStatus `json:"status" db:"status"`
+	OpeningFloat int                 `json:"openingFloat" db:"openingFloat"`
+	CountedCash  *int                `json:"countedCash" db:"countedCash"`
+	OpenedAt     time.Time           `json:"openedAt" db:"openedAt"`
+	ClosedAt     *time.Time          `json:"closedAt" db:"closedAt"`
+}
+
+// DrawerMovementType is cash put into or taken out of the drawer outside of
+// a sale, e.g. extra change from the safe or paying a supplier.
+type DrawerMovementType string
+
+const (
+	DrawerPayIn  DrawerMovementType = "payIn"
+	DrawerPayOut DrawerMovementType = "payOut"
+)
+
+type DrawerMovement struct {
+	ID        uuid.UUID          `json:"id" db:"id"`
+	SessionId uuid.UUID          `json:"sessionId" db:"sessionId"`
+	StaffId   uuid.UUID          `json:"staffId" db:"staffId"`
+	Type      DrawerMovementType `json:"type" db:"type"`
+	Amount    int                `json:"amount" db:"amount"`
+	Reason    string             `json:"reason" db:"reason"`
+	CreatedAt time.Time          `json:"createdAt" db:"createdAt"`
+}
+
+type OpenDrawerRequest struct {
+	OpeningFloat *int `json:"openingFloat" validate:"required,min=0"`
+}
+
+type DrawerMovementRequest struct {
+	Type   DrawerMovementType `json:"type" validate:"required,oneof=payIn payOut"`
+	Amount *int               `json:"amount" validate:"required,min=1"`
+	Reason *string            `json:"reason" validate:"required,min=1,max=255"`
+}
+
+type CloseDrawerRequest struct {
+	CountedCash *int `json:"countedCash" validate:"required,min=0"`
+}
+
+// DrawerSales sums up the transactions booked on a session
+type DrawerSales struct {
+	Transactions int `json:"transactions"`
+	Total        int `json:"total"`
+	Cash         int `json:"cash"`
+	Voided       int `json:"voided"`
+	VoidedTotal  int `json:"voidedTotal"`
+}
+
+// DrawerReportType tells a mid-shift X report from the closing Z report
+type DrawerReportType string
+
+const (
+	DrawerReportX DrawerReportType = "X"
+	DrawerReportZ DrawerReportType = "Z"
+)
+
+// DrawerReport compares the cash that should be in the drawer with what
+// was counted. Payments has the takings per method, cash included.
+type DrawerReport struct {
+	Type         DrawerReportType `json:"type"`
+	Session      DrawerSession    `json:"session"`
+	Sales        DrawerSales      `json:"sales"`
+	Payments     map[string]int   `json:"payments"`
+	PayIns       int              `json:"payIns"`
+	PayOuts      int              `json:"payOuts"`
+	ExpectedCash int              `json:"expectedCash"`
+	CountedCash  *int             `json:"countedCash"`
+	Difference   *int             `json:"difference"`
+	GeneratedAt  time.Time        `json:"generatedAt"`
+}
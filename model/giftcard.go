@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GiftCardType distinguishes cards sold at the till from store credit
+// issued to a customer, both are redeemed the same way.
+type GiftCardType string
+
+const (
+	GiftCardSold GiftCardType = "giftCard"
+	StoreCredit  GiftCardType = "storeCredit"
+)
+
+// GiftCardMovementType describes why the balance of a gift card changed
+type GiftCardMovementType string
+
+const (
+	GiftCardIssue    GiftCardMovementType = "issue"
+	GiftCardRedeem   GiftCardMovementType = "redeem"
+	GiftCardReversal GiftCardMovementType = "reversal"
+)
+
+type GiftCard struct {
+	ID           uuid.UUID    `json:"id" db:"id"`
+	Code         string       `json:"code" db:"code"`
+	Type         GiftCardType `json:"type" db:"type"`
+	CustomerId   *uuid.UUID   `json:"customerId" db:"customerId"`
+	InitialValue int          `json:"initialValue" db:"initialValue"`
+	Balance      int          `json:"balance" db:"balance"`
+	CreatedAt    time.Time    `json:"createdAt" db:"createdAt"`
+}
+
+// GiftCardMovement is a single entry of the gift card ledger. Amount is
+// signed and Balance is the card balance right after the movement.
+type GiftCardMovement struct {
+	ID            uuid.UUID            `json:"id" db:"id"`
+	GiftCardId    uuid.UUID            `json:"giftCardId" db:"giftCardId"`
+	TransactionId *uuid.UUID           `json:"transactionId" db:"transactionId"`
+	Type          GiftCardMovementType `json:"type" db:"type"`
+	Amount        int                  `json:"amount" db:"amount"`
+	Balance       int                  `json:"balance" db:"balance"`
+	CreatedAt     time.Time            `json:"createdAt" db:"createdAt"`
+}
+
+// GiftCardSale is a gift card sold as a checkout line. Code is optional,
+// a new one is generated when the card has no pre-printed code.
+type GiftCardSale struct {
+	Code   string `json:"code" validate:"omitempty,min=8,max=32"`
+	Amount int    `json:"amount" validate:"required,min=1"`
+}
+
+type IssueGiftCardRequest struct {
+	Type       GiftCardType `json:"type" validate:"required,oneof=giftCard storeCredit"`
+	CustomerId *string      `json:"customerId" validate:"omitempty,uuid"`
+	Amount     *int         `json:"amount" validate:"required,min=1"`
+}
+
+type GiftCardDetail struct {
+	GiftCard
+	Movements []GiftCardMovement `json:"movements"`
+}
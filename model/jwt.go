@@ -6,11 +6,41 @@ type JWTClaims struct {
 	Id          string `json:"id"`
 	Name        string `json:"name"`
 	PhoneNumber string `json:"phone_number"`
+	Version     int    `json:"ver"`
+	TerminalId  string `json:"terminal_id,omitempty"`
+	Purpose     string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// JWTPayload is stored as "userData" in the request context. Role is
+// read from the staff record on every request, not from the token.
+// TerminalId is only set for tokens issued by a pin login. Tokens with a
+// Purpose aren't access tokens, e.g. the two-factor login challenge.
+// Requests made with an API key only carry APIKeyId and Name.
 type JWTPayload struct {
 	Id          string
 	Name        string
 	PhoneNumber string
+	Version     int
+	TerminalId  string
+	Purpose     string
+	Role        StaffRole
+	APIKeyId    string
+}
+
+// JWKS is the public half of the signing keys, served at
+// /.well-known/jwks.json. RSA keys fill N and E, Ed25519 keys Crv and X.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
 }
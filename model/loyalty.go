@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PointType describes why a loyalty ledger entry was written
+type PointType string
+
+const (
+	PointEarn     PointType = "earn"
+	PointRedeem   PointType = "redeem"
+	PointReversal PointType = "reversal"
+)
+
+// LoyaltyPoint represents a single movement in the loyalty_point ledger.
+// Earned points are positive, redeemed points are negative.
+type LoyaltyPoint struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	CustomerId    uuid.UUID  `json:"customerId" db:"customerId"`
+	TransactionId *uuid.UUID `json:"transactionId" db:"transactionId"`
+	Type          PointType  `json:"type" db:"type"`
+	Points        int        `json:"points" db:"points"`
+	CreatedAt     time.Time  `json:"createdAt" db:"createdAt"`
+}
+
+type CustomerPoints struct {
+	CustomerId string         `json:"customerId"`
+	Balance    int            `json:"balance"`
+	History    []LoyaltyPoint `json:"history"`
+}
+
+type GetPointHistoryParam struct {
+	Limit  int
+	Offset int
+}
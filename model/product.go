@@ -26,6 +26,8 @@ type GetProductParam struct {
 	SKU         *string
 	InStock     *bool
 	Sort        ProductSorting
+	// AvailableStock reports stock net of active reservations
+	AvailableStock bool
 }
 
 type ProductSorting struct {
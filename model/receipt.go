@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReceiptFormat is the output format of a printed or emailed receipt
+type ReceiptFormat string
+
+const (
+	ReceiptText   ReceiptFormat = "text"
+	ReceiptESCPOS ReceiptFormat = "escpos"
+	ReceiptPDF    ReceiptFormat = "pdf"
+)
+
+// Receipt holds everything printed on a receipt, all renderers share it
+type Receipt struct {
+	StoreName     string
+	StoreAddress  string
+	StorePhone    string
+	TransactionId uuid.UUID
+	ReceiptNumber string
+	Voided        bool
+	CreatedAt     time.Time
+	Cashier       string
+	Lines         []ReceiptLine
+	Total         int
+	TaxRate       float64
+	Tax           int
+	Payments      []ReceiptPayment
+	Paid          int
+	Change        int
+}
+
+type ReceiptLine struct {
+	Name      string
+	Quantity  int
+	UnitPrice int
+	Total     int
+}
+
+type ReceiptPayment struct {
+	Method string
+	Amount int
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportFormat is the output format of a report
+type ReportFormat string
+
+const (
+	ReportJSON ReportFormat = "json"
+	ReportCSV  ReportFormat = "csv"
+)
+
+// GetStaffSalesParam picks the sales rung up and the voids made within
+// From and To
+type GetStaffSalesParam struct {
+	StaffId *uuid.UUID
+	From    time.Time
+	To      time.Time
+}
+
+// StaffSales sums up the sales booked by a staff member as cashier. The
+// sales figures are gross, every sale counts on the day it was rung up
+// even when it was voided later. Voids count as refunds of the cashier on
+// the day they were made, so the figures of a closed period never change.
+type StaffSales struct {
+	StaffId       uuid.UUID `json:"staffId" db:"staffId"`
+	Name          string    `json:"name" db:"name"`
+	Transactions  int       `json:"transactions" db:"transactions"`
+	Revenue       int       `json:"revenue" db:"revenue"`
+	ItemsSold     int       `json:"itemsSold" db:"itemsSold"`
+	AverageBasket int       `json:"averageBasket" db:"-"`
+	Refunds       int       `json:"refunds" db:"refunds"`
+	RefundAmount  int       `json:"refundAmount" db:"refundAmount"`
+	NetRevenue    int       `json:"netRevenue" db:"-"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockReservation keeps quantity of a product aside for a holder, e.g. a
+// held cart, until it expires or is released.
+type StockReservation struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	ProductId string    `json:"productId" db:"productId"`
+	HolderId  uuid.UUID `json:"holderId" db:"holderId"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt" db:"createdAt"`
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CustomerTier is recomputed from recent spend, promotions can be limited
+// to the higher tiers.
+type CustomerTier string
+
+const (
+	TierStandard CustomerTier = "standard"
+	TierSilver   CustomerTier = "silver"
+	TierGold     CustomerTier = "gold"
+	TierPlatinum CustomerTier = "platinum"
+)
+
+// SegmentRuleType is a condition a customer has to meet to be part of a
+// segment.
+type SegmentRuleType string
+
+const (
+	// RuleBoughtCategory matches customers who bought from Category
+	RuleBoughtCategory SegmentRuleType = "boughtCategory"
+	// RuleBoughtProduct matches customers who bought ProductId
+	RuleBoughtProduct SegmentRuleType = "boughtProduct"
+	// RuleTotalSpend matches customers whose spend is between Min and Max
+	RuleTotalSpend SegmentRuleType = "totalSpend"
+	// RuleVisits matches customers whose number of visits is between Min
+	// and Max
+	RuleVisits SegmentRuleType = "visits"
+	// RuleTier matches customers in one of Tiers
+	RuleTier SegmentRuleType = "tier"
+	// RuleBirthdayMonth matches customers born in Month
+	RuleBirthdayMonth SegmentRuleType = "birthdayMonth"
+)
+
+// SegmentRule is a single condition of a segment, which fields are used
+// depends on the type. WithinDays limits the purchase based rules to the
+// last days, all of history is used when it's empty.
+type SegmentRule struct {
+	Type       SegmentRuleType `json:"type" validate:"required,oneof=boughtCategory boughtProduct totalSpend visits tier birthdayMonth"`
+	Category   *Category       `json:"category,omitempty" validate:"required_if=Type boughtCategory,omitempty,oneof=Clothing Accessories Footwear Beverages"`
+	ProductId  *string         `json:"productId,omitempty" validate:"required_if=Type boughtProduct"`
+	Min        *int            `json:"min,omitempty" validate:"omitempty,min=0"`
+	Max        *int            `json:"max,omitempty" validate:"omitempty,min=0"`
+	Tiers      []CustomerTier  `json:"tiers,omitempty" validate:"required_if=Type tier,dive,oneof=standard silver gold platinum"`
+	Month      *int            `json:"month,omitempty" validate:"required_if=Type birthdayMonth,omitempty,min=1,max=12"`
+	WithinDays *int            `json:"withinDays,omitempty" validate:"omitempty,min=1"`
+}
+
+// Segment groups customers meeting all of its rules. Membership is
+// recomputed periodically, Members is the count at RefreshedAt.
+type Segment struct {
+	ID          uuid.UUID     `json:"id" db:"id"`
+	Name        string        `json:"name" db:"name"`
+	Description *string       `json:"description" db:"description"`
+	Rules       []SegmentRule `json:"rules" db:"rules"`
+	Members     int           `json:"members" db:"members"`
+	RefreshedAt *time.Time    `json:"refreshedAt" db:"refreshedAt"`
+	CreatedAt   time.Time     `json:"createdAt" db:"createdAt"`
+}
+
+type CreateSegmentRequest struct {
+	Name        *string       `json:"name" validate:"required,min=1,max=50"`
+	Description *string       `json:"description" validate:"omitempty,max=255"`
+	Rules       []SegmentRule `json:"rules" validate:"required,min=1,dive"`
+}
@@ -6,32 +6,97 @@ import (
 	"github.com/google/uuid"
 )
 
+// StaffRole decides what a staff member is allowed to do. Admins manage
+// the staff accounts, managers and cashiers run the store.
+type StaffRole string
+
+const (
+	RoleAdmin   StaffRole = "admin"
+	RoleManager StaffRole = "manager"
+	RoleCashier StaffRole = "cashier"
+)
+
+// ManagerRoles may run manager operations and act on what other staff
+// members started, e.g. close another cashier's drawer.
+var ManagerRoles = []StaffRole{RoleAdmin, RoleManager}
+
+// IsManager reports whether r is one of ManagerRoles
+func (r StaffRole) IsManager() bool {
+	for _, role := range ManagerRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 type Staff struct {
-	UserId      uuid.UUID `json:"userId" db:"userId"`
-	Name        string    `json:"name" db:"name"`
-	PhoneNumber string    `json:"phoneNumber" db:"phoneNumber"`
-	Password    string    `json:"-" db:"password"`
-	CreatedAt   string    `json:"createdAt" db:"createdAt"`
+	UserId        uuid.UUID  `json:"userId" db:"userId"`
+	Name          string     `json:"name" db:"name"`
+	PhoneNumber   string     `json:"phoneNumber" db:"phoneNumber"`
+	Password      string     `json:"-" db:"password"`
+	Role          StaffRole  `json:"role" db:"role"`
+	TokenVersion  int        `json:"-" db:"tokenVersion"`
+	PinHash       *string    `json:"-" db:"pinHash"`
+	TotpSecret    *string    `json:"-" db:"totpSecret"`
+	TotpEnabledAt *time.Time `json:"twoFactorEnabledAt,omitempty" db:"totpEnabledAt"`
+	TotpCounter   int64      `json:"-" db:"totpLastCounter"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty" db:"deactivatedAt"`
+	CreatedAt     string     `json:"createdAt" db:"createdAt"`
 }
 
+// RegisterStaffRequest needs an invite code issued by an admin, only the
+// very first staff member can register without one.
 type RegisterStaffRequest struct {
 	PhoneNumber string `json:"phoneNumber" validate:"required,phone_number"`
 	Name        string `json:"name" validate:"required,min=5,max=50"`
-	Password    string `json:"password" validate:"required,min=5,max=15"`
+	Password    string `json:"password" validate:"required"`
+	InviteCode  string `json:"inviteCode"`
 }
 
 type StaffWithToken struct {
 	UserId      string    `json:"userId"`
 	Name        string    `json:"name"`
 	PhoneNumber string    `json:"phoneNumber"`
+	Role        StaffRole `json:"role,omitempty"`
 	Password    string    `json:"-"`
 	CreatedAt   time.Time `json:"-"`
 	AccessToken string    `json:"accessToken"`
+	// set instead of AccessToken when the login needs a second step
+	ChallengeToken    string `json:"challengeToken,omitempty"`
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
 }
 
 type LoginStaffRequest struct {
 	PhoneNumber string `json:"phoneNumber" validate:"required,phone_number"`
-	Password    string `json:"password" validate:"required,min=5,max=15"`
+	Password    string `json:"password" validate:"required,max=72"`
+}
+
+// ChangePasswordRequest is checked against the password policy, all other
+// sessions of the staff are signed out afterwards.
+type ChangePasswordRequest struct {
+	CurrentPassword *string `json:"currentPassword" validate:"required"`
+	NewPassword     *string `json:"newPassword" validate:"required"`
+}
+
+// ResetPasswordRequest sets a new password with a one-time token issued
+// by an admin, for staff who forgot theirs.
+type ResetPasswordRequest struct {
+	Token       *string `json:"token" validate:"required"`
+	NewPassword *string `json:"newPassword" validate:"required"`
+}
+
+// PasswordReset is handed to the admin once, only a hash of the token is
+// stored.
+type PasswordReset struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	StaffId   uuid.UUID  `json:"staffId" db:"staffId"`
+	Token     string     `json:"token,omitempty" db:"-"`
+	TokenHash string     `json:"-" db:"tokenHash"`
+	CreatedBy uuid.UUID  `json:"createdBy" db:"createdBy"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" db:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt" db:"createdAt"`
 }
 
 type RegisterStaffResponse struct {
@@ -39,6 +104,100 @@ type RegisterStaffResponse struct {
 	Data    StaffWithToken `json:"data"`
 }
 
+// GetStaffParam filters the staff list, Status is active, inactive or all.
+type GetStaffParam struct {
+	Role   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+type UpdateStaffRoleRequest struct {
+	Role *string `json:"role" validate:"required,oneof=admin manager cashier"`
+}
+
+type CreateStaffInviteRequest struct {
+	Role *string `json:"role" validate:"required,oneof=admin manager cashier"`
+}
+
+// StaffInvite lets one person register with the given role. Only a hash of
+// the code is stored, Code is filled once when the invite is created.
+type StaffInvite struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Code      string     `json:"code,omitempty" db:"-"`
+	CodeHash  string     `json:"-" db:"codeHash"`
+	Role      StaffRole  `json:"role" db:"role"`
+	CreatedBy uuid.UUID  `json:"createdBy" db:"createdBy"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" db:"usedAt"`
+	UsedBy    *uuid.UUID `json:"usedBy,omitempty" db:"usedBy"`
+	CreatedAt time.Time  `json:"createdAt" db:"createdAt"`
+}
+
+// SetPinRequest asks for the password again, a pin is easier to shoulder
+// surf than a password.
+type SetPinRequest struct {
+	Password *string `json:"password" validate:"required"`
+	Pin      *string `json:"pin" validate:"required,numeric,min=4,max=6"`
+}
+
+// PinLoginRequest is sent from a registered terminal, its device id goes
+// in the X-Device-Id header.
+type PinLoginRequest struct {
+	PhoneNumber string `json:"phoneNumber" validate:"required,phone_number"`
+	Pin         string `json:"pin" validate:"required,numeric,min=4,max=6"`
+}
+
+// Terminal is a shared till allowed to use pin login. DeviceId is only
+// returned once, when the terminal is registered.
+type Terminal struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	DeviceId   string     `json:"deviceId,omitempty" db:"-"`
+	DeviceHash string     `json:"-" db:"deviceHash"`
+	CreatedBy  uuid.UUID  `json:"createdBy" db:"createdBy"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" db:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" db:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt" db:"createdAt"`
+}
+
+type RegisterTerminalRequest struct {
+	Name *string `json:"name" validate:"required,min=1,max=100"`
+}
+
+// TwoFactorEnrolment is shown once, the secret goes into the
+// authenticator app, usually by scanning the URI as a QR code.
+type TwoFactorEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type EnrolTwoFactorRequest struct {
+	Password *string `json:"password" validate:"required"`
+}
+
+type ConfirmTwoFactorRequest struct {
+	Code *string `json:"code" validate:"required,numeric,len=6"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password *string `json:"password" validate:"required"`
+	Code     *string `json:"code" validate:"required,numeric,len=6"`
+}
+
+// TwoFactorLoginRequest completes a login with a code from the
+// authenticator app or, when the phone is lost, a recovery code.
+type TwoFactorLoginRequest struct {
+	ChallengeToken *string `json:"challengeToken" validate:"required"`
+	Code           string  `json:"code" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode   string  `json:"recoveryCode" validate:"required_without=Code"`
+}
+
+// RecoveryCodes are single use and shown only once
+type RecoveryCodes struct {
+	Codes []string `json:"recoveryCodes"`
+}
+
 func NewUser(phoneNumber, name, password string) *Staff {
 	staff := &Staff{
 		PhoneNumber: phoneNumber,
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TimeEntry is one shift of a staff member on the time clock. ClockOutAt
+// is empty while the shift is running. Entries changed or added by a
+// manager keep who did it and why.
+type TimeEntry struct {
+	ID               uuid.UUID   `json:"id" db:"id"`
+	StaffId          uuid.UUID   `json:"staffId" db:"staffId"`
+	ClockInAt        time.Time   `json:"clockInAt" db:"clockInAt"`
+	ClockOutAt       *time.Time  `json:"clockOutAt" db:"clockOutAt"`
+	Breaks           []TimeBreak `json:"breaks" db:"-"`
+	CorrectedBy      *uuid.UUID  `json:"correctedBy,omitempty" db:"correctedBy"`
+	CorrectedAt      *time.Time  `json:"correctedAt,omitempty" db:"correctedAt"`
+	CorrectionReason *string     `json:"correctionReason,omitempty" db:"correctionReason"`
+	CreatedAt        time.Time   `json:"createdAt" db:"createdAt"`
+}
+
+// TimeBreak is an unpaid break within a shift, EndedAt is empty while the
+// break is running.
+type TimeBreak struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	EntryId   uuid.UUID  `json:"-" db:"entryId"`
+	StartedAt time.Time  `json:"startedAt" db:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt" db:"endedAt"`
+}
+
+// TimeEntryRequest is used by managers to correct a shift or to add one
+// that was never clocked. The breaks replace the recorded ones.
+type TimeEntryRequest struct {
+	StaffId    *string            `json:"staffId" validate:"omitempty,uuid"`
+	ClockInAt  *time.Time         `json:"clockInAt" validate:"required"`
+	ClockOutAt *time.Time         `json:"clockOutAt" validate:"required"`
+	Breaks     []TimeBreakRequest `json:"breaks" validate:"dive"`
+	Reason     *string            `json:"reason" validate:"required,min=1,max=255"`
+}
+
+type TimeBreakRequest struct {
+	StartedAt *time.Time `json:"startedAt" validate:"required"`
+	EndedAt   *time.Time `json:"endedAt" validate:"required"`
+}
+
+type GetTimeEntryParam struct {
+	StaffId *uuid.UUID
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+	Offset  int
+}
+
+// GetAttendanceParam picks the shifts that started within From and To
+type GetAttendanceParam struct {
+	StaffId *uuid.UUID
+	From    time.Time
+	To      time.Time
+}
+
+// StaffAttendance sums up the shifts of a staff member over a period next
+// to the sales booked by them as cashier in the same period. Running
+// shifts and breaks count up to now.
+type StaffAttendance struct {
+	StaffId         uuid.UUID  `json:"staffId" db:"staffId"`
+	Name            string     `json:"name" db:"name"`
+	Shifts          int        `json:"shifts" db:"shifts"`
+	WorkedMinutes   int        `json:"workedMinutes" db:"workedMinutes"`
+	BreakMinutes    int        `json:"breakMinutes" db:"breakMinutes"`
+	FirstClockIn    *time.Time `json:"firstClockIn" db:"firstClockIn"`
+	LastClockOut    *time.Time `json:"lastClockOut" db:"lastClockOut"`
+	OpenShift       bool       `json:"openShift" db:"openShift"`
+	CorrectedShifts int        `json:"correctedShifts" db:"correctedShifts"`
+	Transactions    int        `json:"transactions" db:"transactions"`
+	Revenue         int        `json:"revenue" db:"revenue"`
+	RevenuePerHour  int        `json:"revenuePerHour" db:"-"`
+}
@@ -8,31 +8,61 @@ import (
 	"github.com/google/uuid"
 )
 
-func GenerateToken(id uuid.UUID, phoneNumber, name, secret string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, model.JWTClaims{
+// GenerateToken issues an access token, version is the token version of the
+// staff so the token stops working once the password changes.
+func GenerateToken(id uuid.UUID, phoneNumber, name string, version int, keys *KeySet) (string, error) {
+	return signToken(model.JWTClaims{
 		Id:          id.String(),
 		PhoneNumber: phoneNumber,
 		Name:        name,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Minute)),
-		},
-	})
+		Version:     version,
+	}, 10*time.Minute, keys)
+}
 
-	tokenString, err := token.SignedString([]byte(secret))
-	return tokenString, err
+// GenerateTerminalToken issues the token of a pin login, it is only
+// accepted together with the device id of terminalId.
+func GenerateTerminalToken(id uuid.UUID, phoneNumber, name string, version int, terminalId uuid.UUID, ttl time.Duration, keys *KeySet) (string, error) {
+	return signToken(model.JWTClaims{
+		Id:          id.String(),
+		PhoneNumber: phoneNumber,
+		Name:        name,
+		Version:     version,
+		TerminalId:  terminalId.String(),
+	}, ttl, keys)
 }
 
-func VerifyToken(token, secretKey string) (*model.JWTPayload, error) {
-	claims := &model.JWTClaims{}
+// ChallengePurpose marks the token handed out between the password and
+// the two-factor step of a login, it is not an access token.
+const ChallengePurpose = "2fa"
 
-	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
-	if err != nil {
-		return nil, err
+func GenerateChallengeToken(id uuid.UUID, version int, ttl time.Duration, keys *KeySet) (string, error) {
+	return signToken(model.JWTClaims{
+		Id:      id.String(),
+		Version: version,
+		Purpose: ChallengePurpose,
+	}, ttl, keys)
+}
+
+func signToken(claims model.JWTClaims, ttl time.Duration, keys *KeySet) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 	}
 
-	if claims.RegisteredClaims.ExpiresAt.Before(time.Now()) {
+	return keys.sign(claims)
+}
+
+// VerifyToken only accepts tokens signed by a key of keys with the
+// algorithm that key is pinned to, and always wants an expiry.
+func VerifyToken(token string, keys *KeySet) (*model.JWTPayload, error) {
+	claims := &model.JWTClaims{}
+
+	_, err := jwt.ParseWithClaims(token, claims, keys.keyFunc,
+		jwt.WithValidMethods(keys.methods()),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
 		return nil, err
 	}
 
@@ -40,6 +70,9 @@ func VerifyToken(token, secretKey string) (*model.JWTPayload, error) {
 		Id:          claims.Id,
 		PhoneNumber: claims.PhoneNumber,
 		Name:        claims.Name,
+		Version:     claims.Version,
+		TerminalId:  claims.TerminalId,
+		Purpose:     claims.Purpose,
 	}
 
 	return payload, nil
@@ -0,0 +1,209 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"eniqilo-store/config"
+	"eniqilo-store/model"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA key accepted for signing tokens
+const minRSABits = 2048
+
+// KeySet holds the keys tokens are signed and verified with. Every key is
+// pinned to the algorithm of its type, a token is only accepted when its
+// header names both the kid and the algorithm of a key in the set.
+type KeySet struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+	// legacy verifies HS256 tokens issued without a kid
+	legacy *jwtKey
+}
+
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// LoadKeySet reads the key files of cfg, it fails when no key can sign
+// new tokens.
+func LoadKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*jwtKey{}}
+
+	for _, entry := range cfg.KeyFiles {
+		kid, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("jwt key %q: expected kid=path", entry)
+		}
+		if _, ok := ks.keys[kid]; ok {
+			return nil, fmt.Errorf("jwt key %q: duplicate kid", kid)
+		}
+
+		key, err := readKeyFile(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[kid] = key
+	}
+
+	if cfg.Secret != "" {
+		ks.legacy = &jwtKey{
+			method:  jwt.SigningMethodHS256,
+			private: []byte(cfg.Secret),
+			public:  []byte(cfg.Secret),
+		}
+	}
+
+	switch {
+	case cfg.SigningKey != "":
+		key, ok := ks.keys[cfg.SigningKey]
+		if !ok {
+			return nil, fmt.Errorf("jwt signing key %q is not in the key files", cfg.SigningKey)
+		}
+		if key.private == nil {
+			return nil, fmt.Errorf("jwt signing key %q is a public key", cfg.SigningKey)
+		}
+		ks.signing = key
+	case ks.legacy != nil:
+		ks.signing = ks.legacy
+	default:
+		return nil, errors.New("no jwt signing key, set JWT_SIGNING_KEY or JWT_SECRET")
+	}
+
+	return ks, nil
+}
+
+func readKeyFile(kid, path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %q: %w", kid, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt key %q: no PEM block in %s", kid, path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("jwt key %q: unsupported PEM block %s", kid, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %q: %w", kid, err)
+	}
+
+	key := &jwtKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("jwt key %q: only RSA and Ed25519 keys are supported", kid)
+	}
+
+	if pub, ok := key.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("jwt key %q: RSA keys need at least %d bits", kid, minRSABits)
+	}
+
+	return key, nil
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	if ks.signing.kid != "" {
+		token.Header["kid"] = ks.signing.kid
+	}
+
+	return token.SignedString(ks.signing.private)
+}
+
+// keyFunc picks the key named by the token header and refuses any other
+// algorithm than the one the key is pinned to
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	key := ks.legacy
+	if kid, ok := t.Header["kid"]; ok {
+		s, _ := kid.(string)
+		key = ks.keys[s]
+	}
+	if key == nil {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+
+	return key.public, nil
+}
+
+// methods lists the algorithms of the set for the parser
+func (ks *KeySet) methods() []string {
+	seen := map[string]bool{}
+	if ks.legacy != nil {
+		seen[ks.legacy.method.Alg()] = true
+	}
+	for _, key := range ks.keys {
+		seen[key.method.Alg()] = true
+	}
+
+	methods := make([]string, 0, len(seen))
+	for alg := range seen {
+		methods = append(methods, alg)
+	}
+	return methods
+}
+
+// JWKS publishes the public keys of the set so other services can verify
+// our tokens. The shared HS256 secret is never part of it.
+func (ks *KeySet) JWKS() model.JWKS {
+	jwks := model.JWKS{Keys: []model.JWK{}}
+
+	for _, key := range ks.keys {
+		jwk := model.JWK{
+			Kid: key.kid,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		}
+
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks a value written by SecretBox.Seal, values without it
+// were stored before they were encrypted.
+const sealedPrefix = "v1:"
+
+var ErrNotSealed = errors.New("value is not sealed")
+
+// SecretBox encrypts secrets kept in the database with AES-256-GCM, so a
+// copy of the database alone doesn't reveal them.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox takes the base64 encoding of a 32 byte key
+func NewSecretBox(key string) (*SecretBox, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("secret key is not base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("secret key must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts plaintext for the row identified by owner. The value only
+// opens for the same owner, so it can't be copied onto another row.
+func (b *SecretBox) Seal(plaintext, owner string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(owner))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value of Seal. ErrNotSealed is returned for a value
+// stored before encryption.
+func (b *SecretBox) Open(sealed, owner string) (string, error) {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		return "", ErrNotSealed
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < b.aead.NonceSize() {
+		return "", errors.New("sealed value is too short")
+	}
+
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(owner))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
@@ -32,6 +32,10 @@ func NewConflictError(message string) CustomError {
 	return CustomError{Message: message, StatusCode: 409}
 }
 
+func NewTooManyRequestsError(message string) CustomError {
+	return CustomError{Message: message, StatusCode: 429}
+}
+
 func NewInternalServerError(message string) CustomError {
 	return CustomError{Message: message, StatusCode: 500}
 }
@@ -0,0 +1,60 @@
+// Package phone normalises phone numbers to E.164 so the same number typed
+// in different formats is stored once.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNotE164 is returned for numbers that can't be written in E.164, the
+// country code is required because it can't be guessed.
+var ErrNotE164 = errors.New("phone number must start with + and the country code")
+
+// maxDigits is the longest E.164 number, country code included
+const maxDigits = 15
+
+// Normalize keeps the leading plus and the digits of number and drops the
+// trunk prefix written as (0), e.g. "+62 (0)812-3456" becomes "+628123456".
+// Spaces, dots, hyphens and parentheses are accepted as separators.
+func Normalize(number string) (string, error) {
+	number = strings.TrimSpace(number)
+	if !strings.HasPrefix(number, "+") {
+		return "", ErrNotE164
+	}
+	number = strings.ReplaceAll(number[1:], "(0)", "")
+
+	var b strings.Builder
+	b.WriteByte('+')
+	for _, c := range number {
+		switch {
+		case c >= '0' && c <= '9':
+			b.WriteRune(c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return "", ErrNotE164
+		}
+	}
+
+	result := b.String()
+	if len(result) < 2 || len(result)-1 > maxDigits || result[1] == '0' {
+		return "", ErrNotE164
+	}
+	return result, nil
+}
+
+// Search prepares number for a partial match against normalised numbers.
+// Complete numbers are normalised, anything else keeps only its digits.
+func Search(number string) string {
+	if normalized, err := Normalize(number); err == nil {
+		return normalized
+	}
+
+	var b strings.Builder
+	for _, c := range number {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+		err    error
+	}{
+		{number: "+628123456789", want: "+628123456789"},
+		{number: "  +62 812-3456-789 ", want: "+628123456789"},
+		{number: "+62 (0)812 3456 789", want: "+628123456789"},
+		{number: "+44 (0)20 7946.0958", want: "+442079460958"},
+		{number: "+1 (415) 555-0100", want: "+14155550100"},
+		{number: "08123456789", err: ErrNotE164},
+		{number: "628123456789", err: ErrNotE164},
+		{number: "+", err: ErrNotE164},
+		{number: "+0812345678", err: ErrNotE164},
+		{number: "+62 812 CALL ME", err: ErrNotE164},
+		{number: "+62+8123456789", err: ErrNotE164},
+		{number: "+1234567890123456", err: ErrNotE164},
+		{number: "", err: ErrNotE164},
+	}
+
+	for _, test := range tests {
+		got, err := Normalize(test.number)
+		if !errors.Is(err, test.err) {
+			t.Errorf("Normalize(%q) err = %v, want %v", test.number, err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Normalize(%q) = %q, want %q", test.number, got, test.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{number: "+62 (0)812 3456", want: "+628123456"},
+		{number: "0812-3456", want: "08123456"},
+		{number: "3456", want: "3456"},
+		{number: "abc", want: ""},
+	}
+
+	for _, test := range tests {
+		if got := Search(test.number); got != test.want {
+			t.Errorf("Search(%q) = %q, want %q", test.number, got, test.want)
+		}
+	}
+}
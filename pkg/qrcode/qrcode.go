@@ -0,0 +1,207 @@
+// Package qrcode encodes short payloads such as transaction ids into QR
+// codes. Only byte mode with error correction level M and versions 1 to 10
+// are supported, which is plenty for ids and short URLs.
+package qrcode
+
+import "errors"
+
+// ErrTooLong is returned when the payload doesn't fit in a version 10 code
+var ErrTooLong = errors.New("qrcode: data too long")
+
+// Code is an encoded QR symbol, true modules are dark
+type Code struct {
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x and row y is dark
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+type versionInfo struct {
+	totalCodewords int
+	eccPerBlock    int
+	blocks         int
+	alignment      []int
+}
+
+// error correction level M, versions 1 to 10
+var versions = []versionInfo{
+	{},
+	{26, 10, 1, nil},
+	{44, 16, 1, []int{6, 18}},
+	{70, 26, 1, []int{6, 22}},
+	{100, 18, 2, []int{6, 26}},
+	{134, 24, 2, []int{6, 30}},
+	{172, 16, 4, []int{6, 34}},
+	{196, 18, 4, []int{6, 22, 38}},
+	{242, 22, 4, []int{6, 24, 42}},
+	{292, 22, 5, []int{6, 26, 46}},
+	{346, 26, 5, []int{6, 28, 50}},
+}
+
+// format bits of error correction level M
+const eccFormatBits = 0
+
+// Encode builds the smallest QR code that holds data
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for v := 1; v < len(versions); v++ {
+		if 4+countBits(v)+len(data)*8 <= dataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	codewords := addEccAndInterleave(version, encodeData(version, data))
+
+	q := newSymbol(version)
+	q.drawFunctionPatterns()
+	q.drawCodewords(codewords)
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		penalty := q.penalty()
+		if bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask) // masking is its own inverse
+	}
+	q.applyMask(bestMask)
+	q.drawFormatBits(bestMask)
+
+	return &Code{Size: q.size, modules: q.modules}, nil
+}
+
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+func dataCodewords(version int) int {
+	v := versions[version]
+	return v.totalCodewords - v.eccPerBlock*v.blocks
+}
+
+// encodeData lays out the byte mode segment and pads it to capacity
+func encodeData(version int, data []byte) []byte {
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	capacity := dataCodewords(version) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	result := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			result[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+	return result
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+// addEccAndInterleave splits the data into blocks, appends the Reed-Solomon
+// codewords of every block and interleaves them as the spec requires.
+func addEccAndInterleave(version int, data []byte) []byte {
+	v := versions[version]
+	numShortBlocks := v.blocks - v.totalCodewords%v.blocks
+	shortBlockLen := v.totalCodewords / v.blocks
+	divisor := reedSolomonDivisor(v.eccPerBlock)
+
+	blocks := make([][]byte, 0, v.blocks)
+	k := 0
+	for i := 0; i < v.blocks; i++ {
+		datLen := shortBlockLen - v.eccPerBlock
+		if i >= numShortBlocks {
+			datLen++
+		}
+		dat := append([]byte{}, data[k:k+datLen]...)
+		k += datLen
+		ecc := reedSolomonRemainder(dat, divisor)
+		if i < numShortBlocks {
+			dat = append(dat, 0)
+		}
+		blocks = append(blocks, append(dat, ecc...))
+	}
+
+	result := make([]byte, 0, v.totalCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			// skip the padding byte of short blocks
+			if i != shortBlockLen-v.eccPerBlock || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
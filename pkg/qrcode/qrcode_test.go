@@ -0,0 +1,465 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// The tests read the symbols back with a small decoder written from the
+// spec, sharing as little with the encoder as possible.
+
+// formatStrings are the 15 bit format strings of error correction level M
+// for masks 0 to 7, as listed in ISO/IEC 18004 table C.1.
+var formatStrings = []int{
+	0b101010000010010,
+	0b101000100100101,
+	0b101111001111100,
+	0b101101101001011,
+	0b100010111111001,
+	0b100000011001110,
+	0b100111110010111,
+	0b100101010100000,
+}
+
+// byteCapacity is the number of bytes a level M symbol holds in byte mode
+var byteCapacity = []int{0, 14, 26, 42, 62, 84, 106, 122, 152, 180, 213}
+
+// alignmentCenters of versions 1 to 10, ISO/IEC 18004 annex E
+var alignmentCenters = [][]int{
+	nil, nil,
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+func TestReedSolomonKnownAnswer(t *testing.T) {
+	// "HELLO WORLD" as 1-M, the worked example of the spec tutorials
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	if !bytes.Equal(got, want) {
+		t.Fatalf("ecc codewords = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeDecodes(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("a"),
+		[]byte("ENQ-20261018-0042"),
+		[]byte("https://eniqilo.store/r/5b1f7c1e-0d4e-4a47-9d0b-8c2f1f3a9e61"),
+		bytes.Repeat([]byte{0x00, 0xff, 0x5a}, 30),
+	}
+	for version := 1; version <= 10; version++ {
+		inputs = append(inputs, bytes.Repeat([]byte("x"), byteCapacity[version]))
+	}
+
+	for _, input := range inputs {
+		code, err := Encode(input)
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", len(input), err)
+		}
+
+		got, err := decode(code)
+		if err != nil {
+			t.Fatalf("decode(%d bytes): %v", len(input), err)
+		}
+		if !bytes.Equal(got, input) {
+			t.Fatalf("decoded %q, want %q", got, input)
+		}
+	}
+}
+
+func TestEncodePicksSmallestVersion(t *testing.T) {
+	for version := 1; version <= 10; version++ {
+		code, err := Encode(bytes.Repeat([]byte("x"), byteCapacity[version]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code.Size != 17+4*version {
+			t.Errorf("%d bytes gave size %d, want version %d", byteCapacity[version], code.Size, version)
+		}
+	}
+
+	_, err := Encode(bytes.Repeat([]byte("x"), byteCapacity[10]+1))
+	if !errors.Is(err, ErrTooLong) {
+		t.Errorf("err = %v, want ErrTooLong", err)
+	}
+}
+
+func TestVersionInformation(t *testing.T) {
+	// the version 7 string of ISO/IEC 18004 table D.1
+	const want = 0b000111110010010100
+
+	code, err := Encode(bytes.Repeat([]byte("x"), byteCapacity[7]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bit i sits at row i/3 and column size-11+i%3 in the top right block,
+	// transposed in the bottom left one
+	var topRight, bottomLeft int
+	for i := 0; i < 18; i++ {
+		if code.Dark(code.Size-11+i%3, i/3) {
+			topRight |= 1 << i
+		}
+		if code.Dark(i/3, code.Size-11+i%3) {
+			bottomLeft |= 1 << i
+		}
+	}
+	if topRight != want || bottomLeft != want {
+		t.Errorf("version bits = %018b and %018b, want %018b", topRight, bottomLeft, want)
+	}
+}
+
+// decode reads a level M byte mode symbol made by Encode
+func decode(code *Code) ([]byte, error) {
+	size := code.Size
+	version := (size - 17) / 4
+	if version < 1 || version > 10 || size != 17+4*version {
+		return nil, fmt.Errorf("unexpected size %d", size)
+	}
+
+	if err := checkFunctionPatterns(code, version); err != nil {
+		return nil, err
+	}
+
+	mask, err := readFormat(code)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := functionModules(size, version)
+	codewords := readCodewords(code, reserved, mask)
+
+	data, err := deinterleave(version, codewords)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseByteSegment(version, data)
+}
+
+func checkFunctionPatterns(code *Code, version int) error {
+	size := code.Size
+	for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				if code.Dark(corner[0]+dx, corner[1]+dy) != (ring != 2) {
+					return fmt.Errorf("finder at %v is broken", corner)
+				}
+			}
+		}
+	}
+
+	for i := 8; i < size-8; i++ {
+		if code.Dark(i, 6) != (i%2 == 0) || code.Dark(6, i) != (i%2 == 0) {
+			return fmt.Errorf("timing pattern is broken at %d", i)
+		}
+	}
+
+	if !code.Dark(8, size-8) {
+		return errors.New("dark module is missing")
+	}
+
+	centers := alignmentCenters[version]
+	for _, cy := range centers {
+		for _, cx := range centers {
+			if overlapsFinder(size, cx, cy) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					if code.Dark(cx+dx, cy+dy) != (max(abs(dx), abs(dy)) != 1) {
+						return fmt.Errorf("alignment pattern at %d,%d is broken", cx, cy)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func overlapsFinder(size, cx, cy int) bool {
+	return (cx < 9 && cy < 9) || (cx > size-10 && cy < 9) || (cx < 9 && cy > size-10)
+}
+
+// readFormat reads both copies of the format string and returns the mask
+func readFormat(code *Code) (int, error) {
+	size := code.Size
+
+	// first copy: row 8 from the left holds bits 14 to 9 (skipping the
+	// timing column), then the corner, then column 8 upwards bits 5 to 0
+	positions := [][2]int{
+		{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8},
+		{7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8},
+	}
+	var first int
+	for i, p := range positions {
+		if code.Dark(p[0], p[1]) {
+			first |= 1 << i
+		}
+	}
+
+	// second copy: row 8 from the right holds bits 0 to 7, column 8 from
+	// the bottom bits 14 to 8
+	var second int
+	for i := 0; i < 8; i++ {
+		if code.Dark(size-1-i, 8) {
+			second |= 1 << i
+		}
+	}
+	for i := 8; i < 15; i++ {
+		if code.Dark(8, size-15+i) {
+			second |= 1 << i
+		}
+	}
+
+	if first != second {
+		return 0, fmt.Errorf("format copies differ: %015b and %015b", first, second)
+	}
+	for mask, format := range formatStrings {
+		if format == first {
+			return mask, nil
+		}
+	}
+	return 0, fmt.Errorf("format %015b is not a level M format string", first)
+}
+
+// functionModules marks every module that doesn't carry data
+func functionModules(size, version int) [][]bool {
+	reserved := make([][]bool, size)
+	for i := range reserved {
+		reserved[i] = make([]bool, size)
+	}
+	mark := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				reserved[y][x] = true
+			}
+		}
+	}
+
+	// finders with separators and format areas
+	mark(0, 0, 9, 9)
+	mark(size-8, 0, 8, 9)
+	mark(0, size-8, 9, 8)
+	// timing
+	mark(0, 6, size, 1)
+	mark(6, 0, 1, size)
+
+	centers := alignmentCenters[version]
+	for _, cy := range centers {
+		for _, cx := range centers {
+			if !overlapsFinder(size, cx, cy) {
+				mark(cx-2, cy-2, 5, 5)
+			}
+		}
+	}
+
+	if version >= 7 {
+		mark(size-11, 0, 3, 6)
+		mark(0, size-11, 6, 3)
+	}
+
+	return reserved
+}
+
+// maskInverts is the mask condition of ISO/IEC 18004 table 10, with i the
+// row and j the column
+func maskInverts(mask, i, j int) bool {
+	switch mask {
+	case 0:
+		return (i+j)%2 == 0
+	case 1:
+		return i%2 == 0
+	case 2:
+		return j%3 == 0
+	case 3:
+		return (i+j)%3 == 0
+	case 4:
+		return (i/2+j/3)%2 == 0
+	case 5:
+		return (i*j)%2+(i*j)%3 == 0
+	case 6:
+		return ((i*j)%2+(i*j)%3)%2 == 0
+	default:
+		return ((i*j)%3+(i+j)%2)%2 == 0
+	}
+}
+
+// readCodewords walks the two module wide columns from the bottom right,
+// going up first and turning at every edge, and skips the vertical timing
+// pattern.
+func readCodewords(code *Code, reserved [][]bool, mask int) []byte {
+	size := code.Size
+	var result []byte
+	var current byte
+	bits := 0
+
+	upward := true
+	for right := size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for step := 0; step < size; step++ {
+			y := step
+			if upward {
+				y = size - 1 - step
+			}
+			for _, x := range []int{right, right - 1} {
+				if reserved[y][x] {
+					continue
+				}
+				dark := code.Dark(x, y) != maskInverts(mask, y, x)
+				current <<= 1
+				if dark {
+					current |= 1
+				}
+				bits++
+				if bits == 8 {
+					result = append(result, current)
+					current, bits = 0, 0
+				}
+			}
+		}
+		upward = !upward
+	}
+
+	return result
+}
+
+// deinterleave splits the codewords into blocks, checks the Reed-Solomon
+// syndromes of each block and returns the data codewords in order.
+func deinterleave(version int, codewords []byte) ([]byte, error) {
+	v := versions[version]
+	if len(codewords) < v.totalCodewords {
+		return nil, fmt.Errorf("read %d codewords, want %d", len(codewords), v.totalCodewords)
+	}
+
+	shortBlocks := v.blocks - v.totalCodewords%v.blocks
+	shortData := v.totalCodewords/v.blocks - v.eccPerBlock
+
+	dataLen := make([]int, v.blocks)
+	blocks := make([][]byte, v.blocks)
+	for i := range dataLen {
+		dataLen[i] = shortData
+		if i >= shortBlocks {
+			dataLen[i]++
+		}
+	}
+
+	k := 0
+	for i := 0; i <= shortData; i++ {
+		for j := range blocks {
+			if i < dataLen[j] {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < v.eccPerBlock; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+
+	var data []byte
+	for j, block := range blocks {
+		for i := 0; i < v.eccPerBlock; i++ {
+			if syndrome(block, i) != 0 {
+				return nil, fmt.Errorf("block %d has syndrome %d set", j, i)
+			}
+		}
+		data = append(data, block[:dataLen[j]]...)
+	}
+	return data, nil
+}
+
+// syndrome evaluates the block as a polynomial, first codeword highest,
+// at alpha^i. It is zero for every i below the ecc length of a valid block.
+func syndrome(block []byte, i int) byte {
+	var result byte
+	x := gfPow(i)
+	for _, c := range block {
+		result = gfMul(result, x) ^ c
+	}
+	return result
+}
+
+func gfPow(n int) byte {
+	result := byte(1)
+	for i := 0; i < n; i++ {
+		result = gfMul(result, 2)
+	}
+	return result
+}
+
+// gfMul is the shift and add multiplication in GF(256) with the QR code
+// polynomial 0x11D
+func gfMul(a, b byte) byte {
+	var result byte
+	for b > 0 {
+		if b&1 != 0 {
+			result ^= a
+		}
+		carry := a&0x80 != 0
+		a <<= 1
+		if carry {
+			a ^= 0x1D
+		}
+		b >>= 1
+	}
+	return result
+}
+
+func parseByteSegment(version int, data []byte) ([]byte, error) {
+	pos := 0
+	read := func(n int) int {
+		value := 0
+		for i := 0; i < n; i++ {
+			value <<= 1
+			if data[(pos+i)/8]&(0x80>>((pos+i)%8)) != 0 {
+				value |= 1
+			}
+		}
+		pos += n
+		return value
+	}
+
+	if mode := read(4); mode != 0b0100 {
+		return nil, fmt.Errorf("mode %04b is not byte mode", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	count := read(countBits)
+	if pos+count*8 > len(data)*8 {
+		return nil, fmt.Errorf("count %d exceeds the data", count)
+	}
+
+	result := make([]byte, count)
+	for i := range result {
+		result[i] = byte(read(8))
+	}
+
+	// what follows is the terminator and the 0xEC 0x11 padding
+	if rest := len(data)*8 - pos; rest > 0 {
+		if terminator := min(rest, 4); read(terminator) != 0 {
+			return nil, errors.New("terminator is not zero")
+		}
+		read((8 - pos%8) % 8)
+		for pad := 0xEC; pos < len(data)*8; pad ^= 0xEC ^ 0x11 {
+			if got := read(8); got != pad {
+				return nil, fmt.Errorf("pad codeword %#x, want %#x", got, pad)
+			}
+		}
+	}
+
+	return result, nil
+}
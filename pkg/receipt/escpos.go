@@ -0,0 +1,67 @@
+package receipt
+
+import (
+	"bytes"
+	"eniqilo-store/model"
+)
+
+var (
+	escInit        = []byte{0x1B, 0x40}
+	escAlignLeft   = []byte{0x1B, 0x61, 0x00}
+	escAlignCenter = []byte{0x1B, 0x61, 0x01}
+	escBoldOn      = []byte{0x1B, 0x45, 0x01}
+	escBoldOff     = []byte{0x1B, 0x45, 0x00}
+	escDoubleOn    = []byte{0x1D, 0x21, 0x11}
+	escDoubleOff   = []byte{0x1D, 0x21, 0x00}
+	escFeedAndCut  = []byte{0x1D, 0x56, 0x42, 0x03}
+)
+
+// ESCPOS renders the receipt as an ESC/POS command stream for thermal
+// printers. The QR code is generated by the printer itself.
+func ESCPOS(r model.Receipt) ([]byte, error) {
+	var b bytes.Buffer
+	b.Write(escInit)
+
+	for _, l := range layout(r) {
+		switch l.kind {
+		case lineTitle:
+			b.Write(escAlignCenter)
+			b.Write(escBoldOn)
+			b.Write(escDoubleOn)
+			b.WriteString(l.text)
+			b.Write(escDoubleOff)
+			b.Write(escBoldOff)
+		case lineCenter:
+			b.Write(escAlignCenter)
+			b.WriteString(l.text)
+		default:
+			b.Write(escAlignLeft)
+			b.WriteString(l.plain())
+		}
+		b.WriteByte('\n')
+	}
+
+	b.Write(escAlignCenter)
+	writeQR(&b, []byte(r.TransactionId.String()))
+	b.WriteString(r.TransactionId.String())
+	b.WriteByte('\n')
+	b.Write(escAlignLeft)
+	b.Write(escFeedAndCut)
+
+	return b.Bytes(), nil
+}
+
+// writeQR emits the GS ( k commands: model 2, module size 6, error
+// correction level M, store the data and print it.
+func writeQR(b *bytes.Buffer, data []byte) {
+	b.Write([]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00})
+	b.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, 0x06})
+	b.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, 0x31})
+
+	size := len(data) + 3
+	b.Write([]byte{0x1D, 0x28, 0x6B, byte(size % 256), byte(size / 256), 0x31, 0x50, 0x30})
+	b.Write(data)
+
+	b.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30})
+	b.WriteByte('\n')
+}
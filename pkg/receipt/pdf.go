@@ -0,0 +1,108 @@
+package receipt
+
+import (
+	"bytes"
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/qrcode"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	pdfFontSize   = 9.0
+	pdfTitleSize  = 12.0
+	pdfLineHeight = 11.0
+	pdfMargin     = 14.0
+	pdfModule     = 3.0
+	// Courier glyphs are 600 units wide
+	pdfCharWidth = pdfFontSize * 0.6
+)
+
+// PDF renders the receipt as a single page PDF sized like a paper receipt.
+// It only uses the standard Courier fonts so nothing has to be embedded.
+func PDF(r model.Receipt) ([]byte, error) {
+	code, err := qrcode.Encode([]byte(r.TransactionId.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := layout(r)
+	qrSize := float64(code.Size) * pdfModule
+	width := Width*pdfCharWidth + 2*pdfMargin
+	height := 2*pdfMargin + float64(len(lines)+3)*pdfLineHeight + qrSize
+
+	var content bytes.Buffer
+	y := height - pdfMargin - pdfLineHeight
+	for _, l := range lines {
+		switch l.kind {
+		case lineTitle:
+			titleWidth := float64(Width) * pdfFontSize / pdfTitleSize
+			text := truncate(l.text, int(titleWidth))
+			x := (width - float64(utf8.RuneCountInString(text))*pdfTitleSize*0.6) / 2
+			fmt.Fprintf(&content, "BT /F2 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", pdfTitleSize, x, y, pdfEscape(text))
+		default:
+			fmt.Fprintf(&content, "BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", pdfFontSize, pdfMargin, y, pdfEscape(l.plain()))
+		}
+		y -= pdfLineHeight
+	}
+
+	// QR code modules as filled squares, row 0 is at the top
+	y -= pdfLineHeight
+	left := (width - qrSize) / 2
+	content.WriteString("0 g\n")
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; col++ {
+			if code.Dark(col, row) {
+				fmt.Fprintf(&content, "%.2f %.2f %.2f %.2f re\n", left+float64(col)*pdfModule, y-float64(row+1)*pdfModule, pdfModule, pdfModule)
+			}
+		}
+	}
+	content.WriteString("f\n")
+	y -= qrSize + pdfLineHeight
+	fmt.Fprintf(&content, "BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", pdfFontSize, pdfMargin, y, pdfEscape(centered(r.TransactionId.String())))
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents 4 0 R /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>", width, height),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>",
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.Bytes(), nil
+}
+
+// pdfEscape escapes a PDF string literal, characters outside of ASCII
+// can't be shown by the standard fonts and are replaced.
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, c := range text {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		case c < 32 || c > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+// Package receipt renders a model.Receipt for thermal printers, email and
+// download. Every format is built from the same line layout so they never
+// disagree on what was sold.
+package receipt
+
+import (
+	"eniqilo-store/model"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Width is the number of characters per line, it fits 80mm paper in the
+// default printer font and keeps the PDF receipt narrow.
+const Width = 42
+
+type lineKind int
+
+const (
+	lineCenter lineKind = iota
+	lineTitle
+	lineLeft
+	lineSeparator
+)
+
+type line struct {
+	kind lineKind
+	text string
+}
+
+// layout turns the receipt into the lines every renderer prints
+func layout(r model.Receipt) []line {
+	var lines []line
+	add := func(kind lineKind, text string) {
+		lines = append(lines, line{kind: kind, text: text})
+	}
+
+	add(lineTitle, r.StoreName)
+	for _, text := range []string{r.StoreAddress, r.StorePhone} {
+		if text != "" {
+			add(lineCenter, text)
+		}
+	}
+	add(lineSeparator, "")
+
+	if r.Voided {
+		add(lineTitle, "*** VOID ***")
+	}
+	if r.ReceiptNumber != "" {
+		add(lineLeft, columns("Receipt", r.ReceiptNumber))
+	} else {
+		add(lineLeft, columns("Transaction", shortId(r.TransactionId.String())))
+	}
+	add(lineLeft, columns("Date", r.CreatedAt.Format("2006-01-02 15:04")))
+	if r.Cashier != "" {
+		add(lineLeft, columns("Cashier", r.Cashier))
+	}
+	add(lineSeparator, "")
+
+	for _, item := range r.Lines {
+		add(lineLeft, truncate(item.Name, Width))
+		add(lineLeft, columns(fmt.Sprintf("  %d x %s", item.Quantity, Amount(item.UnitPrice)), Amount(item.Total)))
+	}
+	add(lineSeparator, "")
+
+	add(lineLeft, columns("TOTAL", Amount(r.Total)))
+	if r.TaxRate > 0 {
+		add(lineLeft, columns(fmt.Sprintf("Incl. tax %s%%", strconv.FormatFloat(r.TaxRate, 'f', -1, 64)), Amount(r.Tax)))
+	}
+	for _, payment := range r.Payments {
+		add(lineLeft, columns(payment.Method, Amount(payment.Amount)))
+	}
+	add(lineLeft, columns("Cash", Amount(r.Paid)))
+	add(lineLeft, columns("Change", Amount(r.Change)))
+	add(lineSeparator, "")
+
+	add(lineCenter, "Thank you for shopping with us")
+
+	return lines
+}
+
+// plain renders a line as fixed width text
+func (l line) plain() string {
+	switch l.kind {
+	case lineSeparator:
+		return strings.Repeat("-", Width)
+	case lineCenter, lineTitle:
+		text := truncate(l.text, Width)
+		return strings.Repeat(" ", (Width-utf8.RuneCountInString(text))/2) + text
+	default:
+		return l.text
+	}
+}
+
+// columns puts left and right at both ends of a line
+func columns(left, right string) string {
+	rightWidth := utf8.RuneCountInString(right)
+	left = truncate(left, Width-rightWidth-1)
+	return left + strings.Repeat(" ", max(Width-utf8.RuneCountInString(left)-rightWidth, 1)) + right
+}
+
+// truncate cuts text to width characters, never in the middle of one
+func truncate(text string, width int) string {
+	for i := range text {
+		if width <= 0 {
+			return text[:i]
+		}
+		width--
+	}
+	return text
+}
+
+func shortId(id string) string {
+	return strings.ToUpper(strings.SplitN(id, "-", 2)[0])
+}
+
+// Amount formats an amount with thousands separators, e.g. 12,500
+func Amount(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.Itoa(amount)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+
+	return sign + b.String()
+}
@@ -0,0 +1,70 @@
+package receipt
+
+import (
+	"eniqilo-store/model"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{text: "Kopi Susu", width: 4, want: "Kopi"},
+		{text: "Kopi Susu", width: 20, want: "Kopi Susu"},
+		{text: "Crème brûlée", width: 8, want: "Crème br"},
+		{text: "Crème brûlée", width: 3, want: "Crè"},
+		{text: "抹茶ラテ", width: 2, want: "抹茶"},
+		{text: "Kopi", width: 0, want: ""},
+		{text: "Kopi", width: -1, want: ""},
+	}
+
+	for _, test := range tests {
+		if got := truncate(test.text, test.width); got != test.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", test.text, test.width, got, test.want)
+		}
+	}
+}
+
+func TestTextMultiByteNames(t *testing.T) {
+	r := model.Receipt{
+		StoreName:     "Toko Sejahtera",
+		TransactionId: uuid.MustParse("6f1c2b9e-4a3d-4e8f-9b2a-1c0d3e5f7a9b"),
+		ReceiptNumber: "ENQ-20261019-0001",
+		Cashier:       "Zoë Ångström-Müller",
+		Lines: []model.ReceiptLine{
+			{Name: strings.Repeat("Crème brûlée ", 5), Quantity: 2, UnitPrice: 25000, Total: 50000},
+			{Name: strings.Repeat("抹茶ラテ", 12), Quantity: 1, UnitPrice: 30000, Total: 30000},
+		},
+		Total:  80000,
+		Paid:   100000,
+		Change: 20000,
+	}
+
+	text, err := Text(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.Valid(text) {
+		t.Fatal("receipt text is not valid UTF-8")
+	}
+
+	// the QR code lines are checked by the qrcode tests
+	for _, l := range layout(r) {
+		plain := l.plain()
+		if !utf8.ValidString(plain) {
+			t.Errorf("line %q is not valid UTF-8", plain)
+		}
+		if n := utf8.RuneCountInString(plain); n > Width {
+			t.Errorf("line %q is %d characters wide, want at most %d", plain, n, Width)
+		}
+	}
+	if !strings.Contains(string(text), "Zoë Ångström-Müller") {
+		t.Error("cashier name is missing from the receipt")
+	}
+}
@@ -0,0 +1,50 @@
+package receipt
+
+import (
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/qrcode"
+	"strings"
+)
+
+// Text renders the receipt as UTF-8 plain text, the QR code of the
+// transaction id is drawn with block characters.
+func Text(r model.Receipt) ([]byte, error) {
+	var b strings.Builder
+	for _, l := range layout(r) {
+		b.WriteString(l.plain())
+		b.WriteByte('\n')
+	}
+
+	code, err := qrcode.Encode([]byte(r.TransactionId.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	// two rows of modules per line of text, with a quiet zone of one module
+	indent := strings.Repeat(" ", max((Width-code.Size-2)/2, 0))
+	for y := -1; y <= code.Size; y += 2 {
+		b.WriteString(indent)
+		for x := -1; x <= code.Size; x++ {
+			top, bottom := code.Dark(x, y), code.Dark(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString(centered(r.TransactionId.String()))
+	b.WriteByte('\n')
+
+	return []byte(b.String()), nil
+}
+
+func centered(text string) string {
+	return line{kind: lineCenter, text: text}.plain()
+}
@@ -0,0 +1,52 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed of RFC 6238 appendix B, the ASCII string
+// "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC lists 8 digit codes, ours are their last 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		at := time.Unix(v.unix, 0)
+
+		counter, ok := Validate(rfc6238Secret, v.code, at, 0)
+		if !ok {
+			t.Errorf("code %s at %d was rejected", v.code, v.unix)
+			continue
+		}
+		if counter != Counter(at) {
+			t.Errorf("code %s at %d matched counter %d, want %d", v.code, v.unix, counter, Counter(at))
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	at := time.Unix(59, 0)
+
+	if _, ok := Validate(rfc6238Secret, "287082", at.Add(period*time.Second), 0); ok {
+		t.Error("code of the previous step was accepted without skew")
+	}
+	if _, ok := Validate(rfc6238Secret, "287082", at.Add(period*time.Second), 1); !ok {
+		t.Error("code of the previous step was rejected with a skew of 1")
+	}
+	if _, ok := Validate(rfc6238Secret, "287083", at, 1); ok {
+		t.Error("wrong code was accepted")
+	}
+}
@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"eniqilo-store/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type APIKeyRepo interface {
+	NewTx() (*sqlx.Tx, error)
+	CreateAPIKey(ctx context.Context, tx *sqlx.Tx, key model.APIKey) (result model.APIKey, err error)
+	GetAPIKeys(ctx context.Context) (keys []model.APIKey, err error)
+	GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (key model.APIKey, err error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID) (err error)
+	RevokeAPIKey(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (key model.APIKey, err error)
+}
+
+type apiKeyRepo struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeyRepo(db *sqlx.DB) APIKeyRepo {
+	return &apiKeyRepo{
+		db: db,
+	}
+}
+
+const apiKeyColumns = `"id", "name", "prefix", "keyHash", "scopes", "createdBy", "expiresAt", "lastUsedAt", "revokedAt", "createdAt"`
+
+func scanAPIKey(row sqlx.ColScanner) (key model.APIKey, err error) {
+	var scopesByte []byte
+	err = row.Scan(&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &scopesByte, &key.CreatedBy, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	json.Unmarshal(scopesByte, &key.Scopes)
+	if key.Scopes == nil {
+		key.Scopes = []model.APIKeyScope{}
+	}
+
+	return key, nil
+}
+
+var (
+	createAPIKeyQuery = `INSERT INTO "api_key" ("id", "name", "prefix", "keyHash", "scopes", "createdBy", "expiresAt", "createdAt")
+	VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+	RETURNING ` + apiKeyColumns + `;`
+)
+
+func (r *apiKeyRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *apiKeyRepo) CreateAPIKey(ctx context.Context, tx *sqlx.Tx, key model.APIKey) (result model.APIKey, err error) {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	return scanAPIKey(tx.QueryRowxContext(ctx, createAPIKeyQuery, uuid.New(), key.Name, key.Prefix, key.KeyHash, scopes, key.CreatedBy, key.ExpiresAt))
+}
+
+var (
+	getAPIKeysQuery = `SELECT ` + apiKeyColumns + ` FROM "api_key" ORDER BY "createdAt" DESC;`
+)
+
+func (r *apiKeyRepo) GetAPIKeys(ctx context.Context) (keys []model.APIKey, err error) {
+	rows, err := r.db.QueryxContext(ctx, getAPIKeysQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys = []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+var (
+	getActiveAPIKeyByHashQuery = `SELECT ` + apiKeyColumns + ` FROM "api_key"
+	WHERE "keyHash" = $1 AND "revokedAt" IS NULL AND ("expiresAt" IS NULL OR "expiresAt" > NOW())
+	LIMIT 1;`
+	touchAPIKeyQuery  = `UPDATE "api_key" SET "lastUsedAt" = NOW() WHERE "id" = $1;`
+	revokeAPIKeyQuery = `UPDATE "api_key" SET "revokedAt" = COALESCE("revokedAt", NOW()) WHERE "id" = $1
+	RETURNING ` + apiKeyColumns + `;`
+)
+
+// GetActiveAPIKeyByHash only finds keys that are neither revoked nor
+// expired.
+func (r *apiKeyRepo) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (key model.APIKey, err error) {
+	return scanAPIKey(r.db.QueryRowxContext(ctx, getActiveAPIKeyByHashQuery, keyHash))
+}
+
+func (r *apiKeyRepo) TouchAPIKey(ctx context.Context, id uuid.UUID) (err error) {
+	_, err = r.db.ExecContext(ctx, touchAPIKeyQuery, id)
+	return err
+}
+
+func (r *apiKeyRepo) RevokeAPIKey(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (key model.APIKey, err error) {
+	return scanAPIKey(tx.QueryRowxContext(ctx, revokeAPIKeyQuery, id))
+}
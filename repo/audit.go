@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"context"
+	"eniqilo-store/model"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepo interface {
+	CreateEntryTx(ctx context.Context, tx *sqlx.Tx, entry model.AuditEntry) (err error)
+	RedactEntityTx(ctx context.Context, tx *sqlx.Tx, entityType model.AuditEntityType, entityId string) (err error)
+	GetEntries(ctx context.Context, params model.GetAuditParam) (entries []model.AuditEntry, err error)
+	CountEntries(ctx context.Context, params model.GetAuditParam) (total int, err error)
+}
+
+type auditRepo struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepo(db *sqlx.DB) AuditRepo {
+	return &auditRepo{
+		db: db,
+	}
+}
+
+const auditColumns = `"id", "actorType", "actorId", "actorName", "action", "entityType", "entityId", "before", "after", "requestId", "createdAt"`
+
+func scanAuditEntry(row sqlx.ColScanner) (entry model.AuditEntry, err error) {
+	var before, after []byte
+	err = row.Scan(&entry.ID, &entry.ActorType, &entry.ActorId, &entry.ActorName, &entry.Action, &entry.EntityType, &entry.EntityId, &before, &after, &entry.RequestId, &entry.CreatedAt)
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	entry.Before, entry.After = before, after
+	return entry, nil
+}
+
+var (
+	createAuditEntryQuery = `INSERT INTO "audit_log" (` + auditColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW());`
+)
+
+func auditEntryArgs(entry model.AuditEntry) []interface{} {
+	// a nil json.RawMessage is stored as NULL, not as an empty document
+	var before, after interface{}
+	if len(entry.Before) > 0 {
+		before = []byte(entry.Before)
+	}
+	if len(entry.After) > 0 {
+		after = []byte(entry.After)
+	}
+
+	return []interface{}{uuid.New(), entry.ActorType, entry.ActorId, entry.ActorName, entry.Action, entry.EntityType, entry.EntityId, before, after, entry.RequestId}
+}
+
+// CreateEntryTx writes the entry inside tx so it is kept exactly when the
+// change is.
+func (r *auditRepo) CreateEntryTx(ctx context.Context, tx *sqlx.Tx, entry model.AuditEntry) (err error) {
+	_, err = tx.ExecContext(ctx, createAuditEntryQuery, auditEntryArgs(entry)...)
+	return err
+}
+
+var (
+	redactAuditEntityQuery = `UPDATE "audit_log" SET "before" = NULL, "after" = NULL
+	WHERE "entityType" = $1 AND "entityId" = $2;`
+)
+
+// RedactEntityTx drops the recorded state of an entity but keeps who did
+// what and when.
+func (r *auditRepo) RedactEntityTx(ctx context.Context, tx *sqlx.Tx, entityType model.AuditEntityType, entityId string) (err error) {
+	_, err = tx.ExecContext(ctx, redactAuditEntityQuery, entityType, entityId)
+	return err
+}
+
+// auditFilter builds the WHERE clause shared by the audit list and its
+// count, every value is passed as a query argument.
+func auditFilter(params model.GetAuditParam) (string, []interface{}) {
+	where := ` WHERE 1=1`
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if params.ActorType != nil {
+		where += ` AND "actorType" = ` + arg(*params.ActorType)
+	}
+	if params.ActorId != nil {
+		where += ` AND "actorId" = ` + arg(*params.ActorId)
+	}
+	if params.Action != nil {
+		where += ` AND "action" = ` + arg(*params.Action)
+	}
+	if params.EntityType != nil {
+		where += ` AND "entityType" = ` + arg(*params.EntityType)
+	}
+	if params.EntityId != nil {
+		where += ` AND "entityId" = ` + arg(*params.EntityId)
+	}
+	if params.RequestId != nil {
+		where += ` AND "requestId" = ` + arg(*params.RequestId)
+	}
+	if params.From != nil {
+		where += ` AND "createdAt" >= ` + arg(*params.From)
+	}
+	if params.To != nil {
+		where += ` AND "createdAt" <= ` + arg(*params.To)
+	}
+
+	return where, args
+}
+
+func (r *auditRepo) GetEntries(ctx context.Context, params model.GetAuditParam) (entries []model.AuditEntry, err error) {
+	where, args := auditFilter(params)
+	query := `SELECT ` + auditColumns + ` FROM "audit_log"` + where +
+		fmt.Sprintf(` ORDER BY "createdAt" DESC, "id" LIMIT %d OFFSET %d`, params.Limit, params.Offset)
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries = []model.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (r *auditRepo) CountEntries(ctx context.Context, params model.GetAuditParam) (total int, err error) {
+	where, args := auditFilter(params)
+	err = r.db.QueryRowxContext(ctx, `SELECT COUNT(*) FROM "audit_log"`+where, args...).Scan(&total)
+	return total, err
+}
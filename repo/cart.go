@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"eniqilo-store/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type CartRepo interface {
+	CreateCart(ctx context.Context, cart model.Cart) (result model.Cart, err error)
+	GetCartById(ctx context.Context, id uuid.UUID) (cart model.Cart, err error)
+	GetCarts(ctx context.Context, status model.CartStatus, staffId *uuid.UUID) (carts []model.Cart, err error)
+	UpdateCartLines(ctx context.Context, id uuid.UUID, productDetails []model.ProductDetail) (cart model.Cart, err error)
+	UpdateCartCustomer(ctx context.Context, id uuid.UUID, customerId uuid.UUID) (cart model.Cart, err error)
+	UpdateCartStatus(ctx context.Context, id uuid.UUID, from []model.CartStatus, to model.CartStatus) (cart model.Cart, err error)
+	CompleteCart(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, transactionId uuid.UUID) (err error)
+}
+
+type cartRepo struct {
+	db *sqlx.DB
+}
+
+func NewCartRepo(db *sqlx.DB) CartRepo {
+	return &cartRepo{
+		db: db,
+	}
+}
+
+const cartColumns = `"id", "customerId", "staffId", "status", "productDetails", "transactionId", "createdAt", "updatedAt"`
+
+func scanCart(row sqlx.ColScanner) (cart model.Cart, err error) {
+	var productDetailsByte []byte
+	err = row.Scan(&cart.ID, &cart.CustomerId, &cart.StaffId, &cart.Status, &productDetailsByte, &cart.TransactionId, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		return model.Cart{}, err
+	}
+
+	json.Unmarshal(productDetailsByte, &cart.ProductDetails)
+	if cart.ProductDetails == nil {
+		cart.ProductDetails = []model.ProductDetail{}
+	}
+
+	return cart, nil
+}
+
+var (
+	createCartQuery = `INSERT INTO "cart" ("id", "customerId", "staffId", "status", "productDetails", "createdAt", "updatedAt")
+	VALUES ($1, $2, $3, $4, '[]', NOW(), NOW())
+	RETURNING ` + cartColumns + `;`
+)
+
+func (r *cartRepo) CreateCart(ctx context.Context, cart model.Cart) (result model.Cart, err error) {
+	return scanCart(r.db.QueryRowxContext(ctx, createCartQuery, uuid.New(), cart.CustomerId, cart.StaffId, model.CartOpen))
+}
+
+var (
+	getCartByIdQuery = `SELECT ` + cartColumns + ` FROM "cart" WHERE "id" = $1 LIMIT 1;`
+)
+
+func (r *cartRepo) GetCartById(ctx context.Context, id uuid.UUID) (cart model.Cart, err error) {
+	return scanCart(r.db.QueryRowxContext(ctx, getCartByIdQuery, id))
+}
+
+var (
+	getCartsQuery = `SELECT ` + cartColumns + ` FROM "cart"
+	WHERE "status" = $1 AND ($2::uuid IS NULL OR "staffId" = $2)
+	ORDER BY "updatedAt" DESC;`
+)
+
+// GetCarts lists the carts in status, only those of staffId unless it is nil
+func (r *cartRepo) GetCarts(ctx context.Context, status model.CartStatus, staffId *uuid.UUID) (carts []model.Cart, err error) {
+	rows, err := r.db.QueryxContext(ctx, getCartsQuery, status, staffId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	carts = []model.Cart{}
+	for rows.Next() {
+		cart, err := scanCart(rows)
+		if err != nil {
+			return nil, err
+		}
+		carts = append(carts, cart)
+	}
+
+	return carts, rows.Err()
+}
+
+var (
+	updateCartLinesQuery = `UPDATE "cart" SET "productDetails" = $1, "updatedAt" = NOW()
+	WHERE "id" = $2 AND "status" = 'open'
+	RETURNING ` + cartColumns + `;`
+)
+
+// UpdateCartLines replaces the lines of an open cart, sql.ErrNoRows is
+// returned when the cart doesn't exist or isn't open.
+func (r *cartRepo) UpdateCartLines(ctx context.Context, id uuid.UUID, productDetails []model.ProductDetail) (cart model.Cart, err error) {
+	productDetailsByte, _ := json.Marshal(productDetails)
+	return scanCart(r.db.QueryRowxContext(ctx, updateCartLinesQuery, productDetailsByte, id))
+}
+
+var (
+	updateCartCustomerQuery = `UPDATE "cart" SET "customerId" = $1, "updatedAt" = NOW()
+	WHERE "id" = $2 AND "status" = 'open'
+	RETURNING ` + cartColumns + `;`
+)
+
+func (r *cartRepo) UpdateCartCustomer(ctx context.Context, id uuid.UUID, customerId uuid.UUID) (cart model.Cart, err error) {
+	return scanCart(r.db.QueryRowxContext(ctx, updateCartCustomerQuery, customerId, id))
+}
+
+var (
+	updateCartStatusQuery = `UPDATE "cart" SET "status" = $1, "updatedAt" = NOW()
+	WHERE "id" = $2 AND "status" = ANY ($3)
+	RETURNING ` + cartColumns + `;`
+)
+
+// UpdateCartStatus moves the cart to the given status only if it is
+// currently in one of the from statuses, so concurrent requests can't both
+// win the same transition.
+func (r *cartRepo) UpdateCartStatus(ctx context.Context, id uuid.UUID, from []model.CartStatus, to model.CartStatus) (cart model.Cart, err error) {
+	statuses := make([]string, 0, len(from))
+	for _, status := range from {
+		statuses = append(statuses, string(status))
+	}
+	return scanCart(r.db.QueryRowxContext(ctx, updateCartStatusQuery, to, id, pq.Array(statuses)))
+}
+
+var (
+	completeCartQuery = `UPDATE "cart" SET "status" = 'checkedOut', "transactionId" = $1, "updatedAt" = NOW()
+	WHERE "id" = $2 AND "status" = 'checkingOut'
+	RETURNING "id";`
+)
+
+// CompleteCart links the cart to its transaction in the checkout tx,
+// sql.ErrNoRows is returned when the cart isn't being checked out.
+func (r *cartRepo) CompleteCart(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, transactionId uuid.UUID) (err error) {
+	var completed uuid.UUID
+	return tx.QueryRowxContext(ctx, completeCartQuery, transactionId, id).Scan(&completed)
+}
@@ -2,9 +2,11 @@ package repo
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"eniqilo-store/model"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -13,15 +15,24 @@ import (
 
 type CheckoutRepo interface {
 	NewTx() (*sqlx.Tx, error)
-	CreateCustomer(ctx context.Context, data model.CustomerRequest) (customer model.Customer, err error)
+	CreateCustomer(ctx context.Context, tx *sqlx.Tx, data model.CustomerRequest) (customer model.Customer, err error)
 	GetCustomerById(ctx context.Context, userId string) (customer model.Customer, err error)
 	GetCustomerByNumber(ctx context.Context, phoneNumber string) (customer model.Customer, err error)
 	GetProductById(ctx context.Context, productId string) (product model.Product, err error)
 	UpdateStockProduct(ctx context.Context, tx *sqlx.Tx, currentStock int, productId string) (err error)
+	RestockProduct(ctx context.Context, tx *sqlx.Tx, productId string, quantity int) (err error)
 	GetProductStocks(ctx context.Context, productIDs []string) (map[string]int, error)
-	GetAllCustomer(ctx context.Context, name, phoneNumber string, limit, offset int) (customers []model.CustomerResponseData, err error)
+	GetProductsByIds(ctx context.Context, productIDs []string) (map[string]model.Product, error)
+	GetAllCustomer(ctx context.Context, params model.GetCustomerParam) (customers []model.CustomerResponseData, err error)
 	CreateTransaction(ctx context.Context, tx *sqlx.Tx, transaction model.Transaction) (err error)
 	GetHistoryTransaction(ctx context.Context, params model.GetHistoryParam) (customers []model.Transaction, err error)
+	CountHistoryTransaction(ctx context.Context, params model.GetHistoryParam) (total int, err error)
+	GetTransactionById(ctx context.Context, transactionId uuid.UUID) (transaction model.Transaction, err error)
+	GetTransactionByReceiptNumber(ctx context.Context, receiptNumber string) (transaction model.Transaction, err error)
+	GetTransactionByIdForUpdate(ctx context.Context, tx *sqlx.Tx, transactionId uuid.UUID) (transaction model.Transaction, err error)
+	IsTransactionOfToday(ctx context.Context, tx *sqlx.Tx, transactionId uuid.UUID) (today bool, err error)
+	VoidTransaction(ctx context.Context, tx *sqlx.Tx, transactionId uuid.UUID, reason string, staffId uuid.UUID) (voidedAt time.Time, err error)
+	NextReceiptNumber(ctx context.Context, tx *sqlx.Tx, storeCode string) (number int, day time.Time, createdAt time.Time, err error)
 }
 
 type checkoutRepo struct {
@@ -41,20 +52,20 @@ func (r *checkoutRepo) NewTx() (*sqlx.Tx, error) {
 var (
 	createCustomerQuery = `INSERT INTO "customer" ("userId", "phoneNumber", "name", "createdAt") 
 	VALUES ($1, $2, $3, NOW())
-	RETURNING *;`
+	RETURNING ` + customerColumns + `;`
 )
 
-func (r *checkoutRepo) CreateCustomer(ctx context.Context, data model.CustomerRequest) (customer model.Customer, err error) {
+func (r *checkoutRepo) CreateCustomer(ctx context.Context, tx *sqlx.Tx, data model.CustomerRequest) (customer model.Customer, err error) {
 
 	customerId := uuid.New()
 
-	err = r.db.QueryRowxContext(ctx, createCustomerQuery, customerId, data.PhoneNumber, data.Name).StructScan(&customer)
+	err = tx.QueryRowxContext(ctx, createCustomerQuery, customerId, data.PhoneNumber, data.Name).StructScan(&customer)
 
 	return customer, err
 }
 
 var (
-	getCustomerQuery = `SELECT * FROM "customer" WHERE "userId" = $1 LIMIT 1;`
+	getCustomerQuery = `SELECT ` + customerColumns + ` FROM "customer" WHERE "userId" = $1 AND "deletedAt" IS NULL LIMIT 1;`
 )
 
 func (r *checkoutRepo) GetCustomerById(ctx context.Context, userId string) (customer model.Customer, err error) {
@@ -63,7 +74,7 @@ func (r *checkoutRepo) GetCustomerById(ctx context.Context, userId string) (cust
 }
 
 var (
-	getCustomerByNumberQuery = `SELECT * FROM "customer" WHERE "phoneNumber" = $1 LIMIT 1;`
+	getCustomerByNumberQuery = `SELECT ` + customerColumns + ` FROM "customer" WHERE "phoneNumber" = $1 AND "deletedAt" IS NULL LIMIT 1;`
 )
 
 func (r *checkoutRepo) GetCustomerByNumber(ctx context.Context, phoneNumber string) (customer model.Customer, err error) {
@@ -98,13 +109,51 @@ func (r *checkoutRepo) UpdateStockProduct(ctx context.Context, tx *sqlx.Tx, curr
 	return nil
 }
 
+var (
+	restockProductQuery = `UPDATE "product" SET "stock" = "stock" + $1 WHERE id = $2;`
+)
+
+// RestockProduct puts quantity back on the shelf, relative to the stock at
+// the time of the update so concurrent sales aren't overwritten.
+func (r *checkoutRepo) RestockProduct(ctx context.Context, tx *sqlx.Tx, productId string, quantity int) (err error) {
+	_, err = tx.ExecContext(ctx, restockProductQuery, quantity, productId)
+	return err
+}
+
 var (
 	getProductStocksQuery = `SELECT id, stock FROM product WHERE id = ANY ($1);`
 )
 
 func (r *checkoutRepo) GetProductStocks(ctx context.Context, productIDs []string) (map[string]int, error) {
 	// Construct efficient query to fetch product IDs and stocks in one go
-	rows, err := r.db.QueryContext(ctx, getProductStocksQuery, pq.Array(productIDs))
+	return scanStocks(r.db.QueryContext(ctx, getProductStocksQuery, pq.Array(productIDs)))
+}
+
+var (
+	getProductsByIdsQuery = `SELECT * FROM product WHERE id = ANY ($1);`
+)
+
+func (r *checkoutRepo) GetProductsByIds(ctx context.Context, productIDs []string) (map[string]model.Product, error) {
+	rows, err := r.db.QueryxContext(ctx, getProductsByIdsQuery, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make(map[string]model.Product)
+	for rows.Next() {
+		var product model.Product
+		if err := rows.StructScan(&product); err != nil {
+			return nil, err
+		}
+		products[product.ID.String()] = product
+	}
+
+	return products, rows.Err()
+}
+
+// scanStocks reads rows of (product id, quantity) into a map
+func scanStocks(rows *sql.Rows, err error) (map[string]int, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -126,19 +175,31 @@ func (r *checkoutRepo) GetProductStocks(ctx context.Context, productIDs []string
 	return productStocks, nil
 }
 
-func (r *checkoutRepo) GetAllCustomer(ctx context.Context, name, phoneNumber string, limit, offset int) (customers []model.CustomerResponseData, err error) {
+func (r *checkoutRepo) GetAllCustomer(ctx context.Context, params model.GetCustomerParam) (customers []model.CustomerResponseData, err error) {
 	var listCustomer []model.CustomerResponseData
-	var getAllCustomerQuery = `SELECT "userId", "phoneNumber", "name" FROM customer WHERE 1=1`
+	var getAllCustomerQuery = `SELECT "userId", "phoneNumber", "name", "tier" FROM customer WHERE "deletedAt" IS NULL`
+	var args []interface{}
 
-	if phoneNumber != "" {
-		getAllCustomerQuery += fmt.Sprintf(` AND "phoneNumber" LIKE '%%%s%%'`, phoneNumber)
+	if params.PhoneNumber != "" {
+		args = append(args, params.PhoneNumber)
+		getAllCustomerQuery += fmt.Sprintf(` AND "phoneNumber" LIKE '%%' || $%d || '%%'`, len(args))
 	}
-	if name != "" {
-		getAllCustomerQuery += fmt.Sprintf(` AND LOWER(name) LIKE LOWER('%%%s%%')`, name)
+	if params.Name != "" {
+		args = append(args, params.Name)
+		getAllCustomerQuery += fmt.Sprintf(` AND LOWER(name) LIKE '%%' || LOWER($%d) || '%%'`, len(args))
 	}
-	getAllCustomerQuery += fmt.Sprintf(` ORDER BY "createdAt" DESC LIMIT %d OFFSET %d`, limit, offset)
+	if params.Tier != "" {
+		args = append(args, params.Tier)
+		getAllCustomerQuery += fmt.Sprintf(` AND "tier" = $%d`, len(args))
+	}
+	if params.Segment != "" {
+		args = append(args, params.Segment)
+		getAllCustomerQuery += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM "customer_segment_member" m JOIN "customer_segment" s ON s."id" = m."segmentId"
+			WHERE m."customerId" = customer."userId" AND (s."id"::text = $%d OR s."name" = $%d))`, len(args), len(args))
+	}
+	getAllCustomerQuery += fmt.Sprintf(` ORDER BY "createdAt" DESC LIMIT %d OFFSET %d`, params.Limit, params.Offset)
 
-	rows, err := r.db.QueryContext(ctx, getAllCustomerQuery)
+	rows, err := r.db.QueryContext(ctx, getAllCustomerQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +208,7 @@ func (r *checkoutRepo) GetAllCustomer(ctx context.Context, name, phoneNumber str
 	// Iterate over the rows and scan each row into a struct
 	for rows.Next() {
 		var customer model.CustomerResponseData
-		if err := rows.Scan(&customer.UserId, &customer.PhoneNumber, &customer.Name); err != nil {
+		if err := rows.Scan(&customer.UserId, &customer.PhoneNumber, &customer.Name, &customer.Tier); err != nil {
 			return nil, err
 		}
 		listCustomer = append(listCustomer, customer)
@@ -156,12 +217,20 @@ func (r *checkoutRepo) GetAllCustomer(ctx context.Context, name, phoneNumber str
 }
 
 var (
-	createTransactionQuery = `INSERT INTO "transaction" ("transactionId", "customerId", "productDetails", "paid", "change", "createdAt") VALUES ($1, $2, $3, $4, $5, NOW());`
+	createTransactionQuery = `INSERT INTO "transaction" ("transactionId", "receiptNumber", "sessionId", "staffId", "customerId", "productDetails", "giftCards", "total", "payments", "paid", "change", "createdAt") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW());`
 )
 
 func (r *checkoutRepo) CreateTransaction(ctx context.Context, tx *sqlx.Tx, transaction model.Transaction) (err error) {
 	productDetailsByte, _ := json.Marshal(transaction.ProductDetails)
-	_, err = tx.ExecContext(ctx, createTransactionQuery, transaction.TransactionId, transaction.CustomerId, productDetailsByte, transaction.Paid, transaction.Change)
+	if transaction.Payments == nil {
+		transaction.Payments = []model.Payment{}
+	}
+	paymentsByte, _ := json.Marshal(transaction.Payments)
+	if transaction.GiftCards == nil {
+		transaction.GiftCards = []model.GiftCardSale{}
+	}
+	giftCardsByte, _ := json.Marshal(transaction.GiftCards)
+	_, err = tx.ExecContext(ctx, createTransactionQuery, transaction.TransactionId, transaction.ReceiptNumber, transaction.SessionId, transaction.StaffId, transaction.CustomerId, productDetailsByte, giftCardsByte, transaction.Total, paymentsByte, transaction.Paid, transaction.Change)
 	if err != nil {
 		return err
 	}
@@ -169,13 +238,145 @@ func (r *checkoutRepo) CreateTransaction(ctx context.Context, tx *sqlx.Tx, trans
 	return nil
 }
 
-func (r *checkoutRepo) GetHistoryTransaction(ctx context.Context, params model.GetHistoryParam) (customers []model.Transaction, err error) {
-	var listTransaction []model.Transaction
-	var getAllHistoryTransactionQuery = `SELECT * FROM "transaction" WHERE 1=1`
+// transactionColumns selects a transaction with the name of its cashier
+const transactionColumns = `"transactionId", COALESCE("receiptNumber", ''), "sessionId", "staffId",
+	COALESCE((SELECT "name" FROM "staff" WHERE "staff"."userId" = "transaction"."staffId"), ''), "customerId", "productDetails", "giftCards", COALESCE("total", 0), "payments", "paid", "change", "createdAt", "voidedAt", COALESCE("voidReason", ''), "voidedBy"`
+
+func scanTransaction(row sqlx.ColScanner) (transaction model.Transaction, err error) {
+	var productDetailsByte, giftCardsByte, paymentsByte []byte
+	err = row.Scan(&transaction.TransactionId, &transaction.ReceiptNumber, &transaction.SessionId, &transaction.StaffId, &transaction.CashierName, &transaction.CustomerId, &productDetailsByte, &giftCardsByte, &transaction.Total, &paymentsByte, &transaction.Paid, &transaction.Change, &transaction.CreatedAt, &transaction.VoidedAt, &transaction.VoidReason, &transaction.VoidedBy)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	json.Unmarshal(productDetailsByte, &transaction.ProductDetails)
+	json.Unmarshal(giftCardsByte, &transaction.GiftCards)
+	json.Unmarshal(paymentsByte, &transaction.Payments)
+
+	return transaction, nil
+}
+
+var (
+	getTransactionByIdQuery = `SELECT ` + transactionColumns + ` FROM "transaction" WHERE "transactionId" = $1 LIMIT 1;`
+)
+
+func (r *checkoutRepo) GetTransactionById(ctx context.Context, transactionId uuid.UUID) (transaction model.Transaction, err error) {
+	return scanTransaction(r.db.QueryRowxContext(ctx, getTransactionByIdQuery, transactionId))
+}
+
+var (
+	getTransactionByReceiptNumberQuery = `SELECT ` + transactionColumns + ` FROM "transaction" WHERE "receiptNumber" = $1 LIMIT 1;`
+)
+
+func (r *checkoutRepo) GetTransactionByReceiptNumber(ctx context.Context, receiptNumber string) (transaction model.Transaction, err error) {
+	return scanTransaction(r.db.QueryRowxContext(ctx, getTransactionByReceiptNumberQuery, receiptNumber))
+}
+
+var (
+	getTransactionByIdForUpdateQuery = `SELECT ` + transactionColumns + ` FROM "transaction" WHERE "transactionId" = $1 LIMIT 1 FOR UPDATE;`
+)
+
+func (r *checkoutRepo) GetTransactionByIdForUpdate(ctx context.Context, tx *sqlx.Tx, transactionId uuid.UUID) (transaction model.Transaction, err error) {
+	return scanTransaction(tx.QueryRowxContext(ctx, getTransactionByIdForUpdateQuery, transactionId))
+}
+
+var (
+	isTransactionOfTodayQuery = `SELECT "createdAt"::date = CURRENT_DATE FROM "transaction" WHERE "transactionId" = $1;`
+)
+
+// IsTransactionOfToday compares the day of the sale with the database's
+// current date, the clock createdAt was stamped with.
+func (r *checkoutRepo) IsTransactionOfToday(ctx context.Context, tx *sqlx.Tx, transactionId uuid.UUID) (today bool, err error) {
+	err = tx.QueryRowxContext(ctx, isTransactionOfTodayQuery, transactionId).Scan(&today)
+	return today, err
+}
+
+var (
+	voidTransactionQuery = `UPDATE "transaction" SET "voidedAt" = NOW(), "voidReason" = $2, "voidedBy" = $3 WHERE "transactionId" = $1 AND "voidedAt" IS NULL
+	RETURNING "voidedAt";`
+)
+
+// VoidTransaction marks the transaction as voided, sql.ErrNoRows is returned
+// when it was already voided.
+func (r *checkoutRepo) VoidTransaction(ctx context.Context, tx *sqlx.Tx, transactionId uuid.UUID, reason string, staffId uuid.UUID) (voidedAt time.Time, err error) {
+	err = tx.QueryRowxContext(ctx, voidTransactionQuery, transactionId, reason, staffId).Scan(&voidedAt)
+	return voidedAt, err
+}
+
+var (
+	nextReceiptNumberQuery = `INSERT INTO "receipt_sequence" ("storeCode", "day", "lastNumber") VALUES ($1, CURRENT_DATE, 1)
+	ON CONFLICT ("storeCode", "day") DO UPDATE SET "lastNumber" = "receipt_sequence"."lastNumber" + 1
+	RETURNING "lastNumber", "day", NOW();`
+)
+
+// NextReceiptNumber increments the counter of the store and day inside tx.
+// The row stays locked until tx ends and a rollback gives the number back,
+// so numbers are gap-free even under concurrent checkouts. The day and
+// createdAt come from the database clock of tx, the same NOW() the
+// transaction row is stamped with.
+func (r *checkoutRepo) NextReceiptNumber(ctx context.Context, tx *sqlx.Tx, storeCode string) (number int, day time.Time, createdAt time.Time, err error) {
+	err = tx.QueryRowxContext(ctx, nextReceiptNumberQuery, storeCode).Scan(&number, &day, &createdAt)
+	return number, day, createdAt, err
+}
+
+// historyFilter builds the WHERE clause shared by the history list and its
+// count, every value is passed as a query argument.
+func historyFilter(params model.GetHistoryParam) (string, []interface{}) {
+	where := ` WHERE 1=1`
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
 
 	if params.CustomerId != nil {
-		getAllHistoryTransactionQuery += fmt.Sprintf(` AND "customerId" = %s`, params.CustomerId)
+		where += ` AND "customerId" = ` + arg(*params.CustomerId)
+	}
+	if params.StaffId != nil {
+		where += ` AND "staffId" = ` + arg(*params.StaffId)
+	}
+	if params.ReceiptNumber != nil {
+		where += ` AND "receiptNumber" = ` + arg(*params.ReceiptNumber)
+	}
+	if params.From != nil {
+		where += ` AND "createdAt" >= ` + arg(*params.From)
+	}
+	if params.To != nil {
+		where += ` AND "createdAt" <= ` + arg(*params.To)
+	}
+	if params.MinTotal != nil {
+		where += ` AND COALESCE("total", 0) >= ` + arg(*params.MinTotal)
 	}
+	if params.MaxTotal != nil {
+		where += ` AND COALESCE("total", 0) <= ` + arg(*params.MaxTotal)
+	}
+	if params.ProductId != nil {
+		where += ` AND "productDetails" @> jsonb_build_array(jsonb_build_object('productId', ` + arg(*params.ProductId) + `::text))`
+	}
+	if params.PaymentMethod != nil {
+		if *params.PaymentMethod == model.PaymentCash {
+			where += ` AND "paid" - "change" > 0`
+		} else {
+			where += ` AND "payments" @> jsonb_build_array(jsonb_build_object('method', ` + arg(string(*params.PaymentMethod)) + `::text))`
+		}
+	}
+
+	switch params.Status {
+	case model.TransactionAll:
+	case model.TransactionVoided:
+		where += ` AND "voidedAt" IS NOT NULL`
+	default:
+		where += ` AND "voidedAt" IS NULL`
+	}
+
+	return where, args
+}
+
+func (r *checkoutRepo) GetHistoryTransaction(ctx context.Context, params model.GetHistoryParam) (customers []model.Transaction, err error) {
+	var listTransaction []model.Transaction
+	where, args := historyFilter(params)
+	var getAllHistoryTransactionQuery = `SELECT ` + transactionColumns + ` FROM "transaction"` + where
+
 	if params.CreatedAt != nil {
 		if *params.CreatedAt != "desc" && *params.CreatedAt != "asc" {
 			*params.CreatedAt = "desc"
@@ -191,7 +392,7 @@ func (r *checkoutRepo) GetHistoryTransaction(ctx context.Context, params model.G
 
 	getAllHistoryTransactionQuery += fmt.Sprintf(` LIMIT %d OFFSET %d`, params.Limit, params.Offset)
 
-	rows, err := r.db.QueryContext(ctx, getAllHistoryTransactionQuery)
+	rows, err := r.db.QueryxContext(ctx, getAllHistoryTransactionQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -200,14 +401,11 @@ func (r *checkoutRepo) GetHistoryTransaction(ctx context.Context, params model.G
 
 	// Iterate over the rows and scan each row into a struct
 	for rows.Next() {
-		var transaction model.Transaction
-		var productDetailsByte []byte
-		if err := rows.Scan(&transaction.TransactionId, &transaction.CustomerId, &productDetailsByte, &transaction.Paid, &transaction.Change, &transaction.CreatedAt); err != nil {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
 			return nil, err
 		}
 
-		json.Unmarshal(productDetailsByte, &transaction.ProductDetails)
-
 		listTransaction = append(listTransaction, transaction)
 	}
 	if err := rows.Err(); err != nil {
@@ -216,3 +414,9 @@ func (r *checkoutRepo) GetHistoryTransaction(ctx context.Context, params model.G
 
 	return listTransaction, nil
 }
+
+func (r *checkoutRepo) CountHistoryTransaction(ctx context.Context, params model.GetHistoryParam) (total int, err error) {
+	where, args := historyFilter(params)
+	err = r.db.QueryRowxContext(ctx, `SELECT COUNT(*) FROM "transaction"`+where, args...).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,349 @@
+package repo
+
+import (
+	"context"
+	"eniqilo-store/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// customerColumns selects a customer with the birthday formatted as a plain
+// date
+const customerColumns = `"userId", "phoneNumber", "name", "email", to_char("birthday", 'YYYY-MM-DD') AS "birthday", "notes", "tier", "createdAt", "deletedAt"`
+
+type CustomerRepo interface {
+	NewTx() (*sqlx.Tx, error)
+	GetCustomerByIdForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (customer model.Customer, err error)
+	UpdateCustomer(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, data model.UpdateCustomerRequest) (customer model.Customer, err error)
+	HasCustomerHistory(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (exists bool, err error)
+	DeleteCustomer(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (err error)
+	AnonymiseCustomer(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, name string) (err error)
+	GetCustomerTransactions(ctx context.Context, id uuid.UUID) (transactions []model.Transaction, err error)
+	GetCustomerPointEntries(ctx context.Context, id uuid.UUID) (entries []model.LoyaltyPoint, err error)
+	GetCustomerGiftCards(ctx context.Context, id uuid.UUID) (giftCards []model.GiftCard, err error)
+	DetachCustomerCarts(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (err error)
+	GetDuplicateCandidates(ctx context.Context) (groups []model.DuplicateGroup, err error)
+	ReassignCustomer(ctx context.Context, tx *sqlx.Tx, fromId uuid.UUID, toId uuid.UUID) (err error)
+	MergeCustomerProfile(ctx context.Context, tx *sqlx.Tx, targetId uuid.UUID, sourceId uuid.UUID) (err error)
+	RecordMerge(ctx context.Context, tx *sqlx.Tx, targetId uuid.UUID, sourceId uuid.UUID) (err error)
+	GetMergedIds(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (ids []uuid.UUID, err error)
+	GetCustomerTotals(ctx context.Context, id uuid.UUID) (summary model.CustomerSummary, err error)
+	GetCustomerTopProducts(ctx context.Context, id uuid.UUID, limit int) (products []model.ProductSummary, err error)
+	GetCustomerTopCategories(ctx context.Context, id uuid.UUID, limit int) (categories []model.CategorySummary, err error)
+}
+
+type customerRepo struct {
+	db *sqlx.DB
+}
+
+func NewCustomerRepo(db *sqlx.DB) CustomerRepo {
+	return &customerRepo{
+		db: db,
+	}
+}
+
+func (r *customerRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+var (
+	getCustomerByIdForUpdateQuery = `SELECT ` + customerColumns + ` FROM "customer" WHERE "userId" = $1 AND "deletedAt" IS NULL LIMIT 1 FOR UPDATE;`
+)
+
+func (r *customerRepo) GetCustomerByIdForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (customer model.Customer, err error) {
+	err = tx.QueryRowxContext(ctx, getCustomerByIdForUpdateQuery, id).StructScan(&customer)
+	return customer, err
+}
+
+var (
+	// nil keeps the current value, an empty string clears the optional fields
+	updateCustomerQuery = `UPDATE "customer" SET
+		"phoneNumber" = COALESCE($2, "phoneNumber"),
+		"name" = COALESCE($3, "name"),
+		"email" = CASE WHEN $4::text IS NULL THEN "email" ELSE NULLIF($4, '') END,
+		"birthday" = CASE WHEN $5::text IS NULL THEN "birthday" ELSE NULLIF($5, '')::date END,
+		"notes" = CASE WHEN $6::text IS NULL THEN "notes" ELSE NULLIF($6, '') END
+	WHERE "userId" = $1
+	RETURNING ` + customerColumns + `;`
+)
+
+func (r *customerRepo) UpdateCustomer(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, data model.UpdateCustomerRequest) (customer model.Customer, err error) {
+	err = tx.QueryRowxContext(ctx, updateCustomerQuery, id, data.PhoneNumber, data.Name, data.Email, data.Birthday, data.Notes).StructScan(&customer)
+	return customer, err
+}
+
+var (
+	hasCustomerHistoryQuery = `SELECT
+		EXISTS (SELECT 1 FROM "transaction" WHERE "customerId" = $1)
+		OR EXISTS (SELECT 1 FROM "loyalty_point" WHERE "customerId" = $1)
+		OR EXISTS (SELECT 1 FROM "gift_card" WHERE "customerId" = $1);`
+)
+
+// HasCustomerHistory tells whether anything that has to be kept for the
+// books refers to the customer.
+func (r *customerRepo) HasCustomerHistory(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (exists bool, err error) {
+	err = tx.QueryRowxContext(ctx, hasCustomerHistoryQuery, id).Scan(&exists)
+	return exists, err
+}
+
+var (
+	deleteCustomerQuery = `DELETE FROM "customer" WHERE "userId" = $1;`
+)
+
+func (r *customerRepo) DeleteCustomer(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, deleteCustomerQuery, id)
+	return err
+}
+
+var (
+	anonymiseCustomerQuery = `UPDATE "customer" SET "name" = $2, "phoneNumber" = '', "email" = NULL, "birthday" = NULL, "notes" = NULL, "deletedAt" = NOW()
+	WHERE "userId" = $1;`
+)
+
+// AnonymiseCustomer strips the personal data and renames the customer, the
+// row is kept so past transactions still point at a customer.
+func (r *customerRepo) AnonymiseCustomer(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, name string) (err error) {
+	_, err = tx.ExecContext(ctx, anonymiseCustomerQuery, id, name)
+	return err
+}
+
+var (
+	getCustomerTransactionsQuery = `SELECT ` + transactionColumns + ` FROM "transaction" WHERE "customerId" = $1 ORDER BY "createdAt";`
+)
+
+// GetCustomerTransactions returns every transaction of the customer, voided
+// ones included
+func (r *customerRepo) GetCustomerTransactions(ctx context.Context, id uuid.UUID) (transactions []model.Transaction, err error) {
+	rows, err := r.db.QueryxContext(ctx, getCustomerTransactionsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions = []model.Transaction{}
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+var (
+	getCustomerPointEntriesQuery = `SELECT * FROM "loyalty_point" WHERE "customerId" = $1 ORDER BY "createdAt";`
+)
+
+func (r *customerRepo) GetCustomerPointEntries(ctx context.Context, id uuid.UUID) (entries []model.LoyaltyPoint, err error) {
+	entries = []model.LoyaltyPoint{}
+	err = r.db.SelectContext(ctx, &entries, getCustomerPointEntriesQuery, id)
+	return entries, err
+}
+
+var (
+	getCustomerGiftCardsQuery = `SELECT * FROM "gift_card" WHERE "customerId" = $1 ORDER BY "createdAt";`
+)
+
+func (r *customerRepo) GetCustomerGiftCards(ctx context.Context, id uuid.UUID) (giftCards []model.GiftCard, err error) {
+	giftCards = []model.GiftCard{}
+	err = r.db.SelectContext(ctx, &giftCards, getCustomerGiftCardsQuery, id)
+	return giftCards, err
+}
+
+var (
+	detachCustomerCartsQuery = `UPDATE "cart" SET "customerId" = NULL, "updatedAt" = NOW() WHERE "customerId" = $1 AND "status" IN ('open', 'held');`
+)
+
+// DetachCustomerCarts takes the customer off carts that weren't checked out
+func (r *customerRepo) DetachCustomerCarts(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, detachCustomerCartsQuery, id)
+	return err
+}
+
+var (
+	getCustomerTotalsQuery = `SELECT COUNT(*), COALESCE(SUM("total"), 0), MAX("createdAt")
+	FROM "transaction" WHERE "customerId" = $1 AND "voidedAt" IS NULL;`
+)
+
+// GetCustomerTotals fills the visit count, total spend and last visit of
+// the summary
+func (r *customerRepo) GetCustomerTotals(ctx context.Context, id uuid.UUID) (summary model.CustomerSummary, err error) {
+	err = r.db.QueryRowxContext(ctx, getCustomerTotalsQuery, id).Scan(&summary.Visits, &summary.TotalSpend, &summary.LastVisit)
+	return summary, err
+}
+
+var (
+	// the name is the one on the customer's latest receipt of the product and
+	// the spend uses the price charged, lines bought before names and prices
+	// were snapshotted fall back to the current product
+	getCustomerTopProductsQuery = `SELECT d->>'productId',
+		COALESCE((ARRAY_AGG(d->>'name' ORDER BY t."createdAt" DESC) FILTER (WHERE d->>'name' IS NOT NULL))[1], MAX(p."name"), ''),
+		SUM((d->>'quantity')::int) AS "quantity",
+		SUM((d->>'quantity')::int * COALESCE((d->>'price')::int, p."price", 0))
+	FROM "transaction" t
+	CROSS JOIN jsonb_array_elements(t."productDetails") d
+	LEFT JOIN "product" p ON p."id"::text = d->>'productId'
+	WHERE t."customerId" = $1 AND t."voidedAt" IS NULL
+	GROUP BY d->>'productId'
+	ORDER BY "quantity" DESC
+	LIMIT $2;`
+)
+
+func (r *customerRepo) GetCustomerTopProducts(ctx context.Context, id uuid.UUID, limit int) (products []model.ProductSummary, err error) {
+	rows, err := r.db.QueryContext(ctx, getCustomerTopProductsQuery, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products = []model.ProductSummary{}
+	for rows.Next() {
+		var product model.ProductSummary
+		if err := rows.Scan(&product.ProductId, &product.Name, &product.Quantity, &product.Spend); err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, rows.Err()
+}
+
+var (
+	getCustomerTopCategoriesQuery = `SELECT p."category", SUM((d->>'quantity')::int) AS "quantity"
+	FROM "transaction" t
+	CROSS JOIN jsonb_array_elements(t."productDetails") d
+	JOIN "product" p ON p."id"::text = d->>'productId'
+	WHERE t."customerId" = $1 AND t."voidedAt" IS NULL AND p."category" IS NOT NULL
+	GROUP BY p."category"
+	ORDER BY "quantity" DESC
+	LIMIT $2;`
+)
+
+func (r *customerRepo) GetCustomerTopCategories(ctx context.Context, id uuid.UUID, limit int) (categories []model.CategorySummary, err error) {
+	rows, err := r.db.QueryContext(ctx, getCustomerTopCategoriesQuery, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories = []model.CategorySummary{}
+	for rows.Next() {
+		var category model.CategorySummary
+		if err := rows.Scan(&category.Category, &category.Quantity); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+var (
+	// customers sharing a phone number or, ignoring case and spaces, a name
+	getDuplicateCandidatesQuery = `SELECT 'phoneNumber', "phoneNumber", "userId", "phoneNumber", "name"
+	FROM "customer"
+	WHERE "deletedAt" IS NULL AND "phoneNumber" IN (
+		SELECT "phoneNumber" FROM "customer" WHERE "deletedAt" IS NULL GROUP BY "phoneNumber" HAVING COUNT(*) > 1
+	)
+	UNION ALL
+	SELECT 'name', LOWER(TRIM("name")), "userId", "phoneNumber", "name"
+	FROM "customer"
+	WHERE "deletedAt" IS NULL AND LOWER(TRIM("name")) IN (
+		SELECT LOWER(TRIM("name")) FROM "customer" WHERE "deletedAt" IS NULL GROUP BY LOWER(TRIM("name")) HAVING COUNT(*) > 1
+	)
+	ORDER BY 1, 2;`
+)
+
+func (r *customerRepo) GetDuplicateCandidates(ctx context.Context) (groups []model.DuplicateGroup, err error) {
+	rows, err := r.db.QueryContext(ctx, getDuplicateCandidatesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups = []model.DuplicateGroup{}
+	for rows.Next() {
+		var reason, key string
+		var customer model.CustomerResponseData
+		if err := rows.Scan(&reason, &key, &customer.UserId, &customer.PhoneNumber, &customer.Name); err != nil {
+			return nil, err
+		}
+
+		// rows come ordered by group
+		if len(groups) == 0 || groups[len(groups)-1].Reason != reason || groups[len(groups)-1].Key != key {
+			groups = append(groups, model.DuplicateGroup{Reason: reason, Key: key})
+		}
+		last := &groups[len(groups)-1]
+		last.Customers = append(last.Customers, customer)
+	}
+
+	return groups, rows.Err()
+}
+
+var (
+	reassignCustomerQueries = []string{
+		`UPDATE "transaction" SET "customerId" = $2 WHERE "customerId" = $1;`,
+		`UPDATE "loyalty_point" SET "customerId" = $2 WHERE "customerId" = $1;`,
+		`UPDATE "gift_card" SET "customerId" = $2 WHERE "customerId" = $1;`,
+		`UPDATE "cart" SET "customerId" = $2 WHERE "customerId" = $1;`,
+	}
+)
+
+// ReassignCustomer moves everything that refers to fromId over to toId
+func (r *customerRepo) ReassignCustomer(ctx context.Context, tx *sqlx.Tx, fromId uuid.UUID, toId uuid.UUID) (err error) {
+	for _, query := range reassignCustomerQueries {
+		if _, err = tx.ExecContext(ctx, query, fromId, toId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	mergeCustomerProfileQuery = `UPDATE "customer" t SET
+		"email" = COALESCE(t."email", s."email"),
+		"birthday" = COALESCE(t."birthday", s."birthday"),
+		"notes" = CASE
+			WHEN s."notes" IS NULL THEN t."notes"
+			WHEN t."notes" IS NULL THEN s."notes"
+			ELSE t."notes" || E'\n' || s."notes"
+		END
+	FROM "customer" s
+	WHERE t."userId" = $1 AND s."userId" = $2;`
+)
+
+// MergeCustomerProfile appends the notes of the source customer and fills
+// in the contact details the target customer is missing.
+func (r *customerRepo) MergeCustomerProfile(ctx context.Context, tx *sqlx.Tx, targetId uuid.UUID, sourceId uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, mergeCustomerProfileQuery, targetId, sourceId)
+	return err
+}
+
+var (
+	recordCustomerMergeQuery = `INSERT INTO "customer_merge" ("sourceId", "targetId", "mergedAt") VALUES ($1, $2, NOW());`
+	// follows merges of merges, a customer merged into the source earlier
+	// ended up in id as well
+	getMergedCustomerIdsQuery = `WITH RECURSIVE "merged" AS (
+		SELECT "sourceId" FROM "customer_merge" WHERE "targetId" = $1
+		UNION
+		SELECT m."sourceId" FROM "customer_merge" m JOIN "merged" ON m."targetId" = "merged"."sourceId"
+	)
+	SELECT "sourceId" FROM "merged";`
+)
+
+// RecordMerge keeps the lineage of a merge after the source customer is
+// gone, erasing the target has to reach the entries of the source too.
+func (r *customerRepo) RecordMerge(ctx context.Context, tx *sqlx.Tx, targetId uuid.UUID, sourceId uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, recordCustomerMergeQuery, sourceId, targetId)
+	return err
+}
+
+// GetMergedIds returns every customer merged into id, directly or through
+// other merges.
+func (r *customerRepo) GetMergedIds(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (ids []uuid.UUID, err error) {
+	err = tx.SelectContext(ctx, &ids, getMergedCustomerIdsQuery, id)
+	return ids, err
+}
@@ -0,0 +1,171 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type DrawerRepo interface {
+	CreateSession(ctx context.Context, session model.DrawerSession) (result model.DrawerSession, err error)
+	GetSessionById(ctx context.Context, id uuid.UUID) (session model.DrawerSession, err error)
+	GetSessionByIdForShare(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (session model.DrawerSession, err error)
+	GetOpenSession(ctx context.Context, staffId uuid.UUID) (session model.DrawerSession, err error)
+	GetOpenSessionForShare(ctx context.Context, tx *sqlx.Tx, staffId uuid.UUID) (session model.DrawerSession, err error)
+	CloseSession(ctx context.Context, id uuid.UUID, countedCash int) (session model.DrawerSession, err error)
+	CreateMovement(ctx context.Context, movement model.DrawerMovement) (result model.DrawerMovement, err error)
+	GetMovementTotals(ctx context.Context, sessionId uuid.UUID) (totals map[model.DrawerMovementType]int, err error)
+	GetSessionSales(ctx context.Context, sessionId uuid.UUID) (sales model.DrawerSales, err error)
+	GetSessionPayments(ctx context.Context, sessionId uuid.UUID) (payments map[string]int, err error)
+}
+
+type drawerRepo struct {
+	db *sqlx.DB
+}
+
+func NewDrawerRepo(db *sqlx.DB) DrawerRepo {
+	return &drawerRepo{
+		db: db,
+	}
+}
+
+var (
+	createDrawerSessionQuery = `INSERT INTO "drawer_session" ("id", "staffId", "status", "openingFloat", "openedAt")
+	VALUES ($1, $2, $3, $4, NOW())
+	RETURNING *;`
+)
+
+func (r *drawerRepo) CreateSession(ctx context.Context, session model.DrawerSession) (result model.DrawerSession, err error) {
+	err = r.db.QueryRowxContext(ctx, createDrawerSessionQuery, uuid.New(), session.StaffId, model.DrawerOpen, session.OpeningFloat).StructScan(&result)
+	return result, err
+}
+
+var (
+	getDrawerSessionByIdQuery         = `SELECT * FROM "drawer_session" WHERE "id" = $1 LIMIT 1;`
+	getDrawerSessionByIdForShareQuery = `SELECT * FROM "drawer_session" WHERE "id" = $1 LIMIT 1 FOR SHARE;`
+)
+
+func (r *drawerRepo) GetSessionById(ctx context.Context, id uuid.UUID) (session model.DrawerSession, err error) {
+	err = r.db.QueryRowxContext(ctx, getDrawerSessionByIdQuery, id).StructScan(&session)
+	return session, err
+}
+
+// GetSessionByIdForShare keeps the session from being closed until tx
+// ends, so a void can't change a session whose Z report was already taken.
+func (r *drawerRepo) GetSessionByIdForShare(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (session model.DrawerSession, err error) {
+	err = tx.QueryRowxContext(ctx, getDrawerSessionByIdForShareQuery, id).StructScan(&session)
+	return session, err
+}
+
+var (
+	getOpenDrawerSessionQuery         = `SELECT * FROM "drawer_session" WHERE "staffId" = $1 AND "status" = 'open' LIMIT 1;`
+	getOpenDrawerSessionForShareQuery = `SELECT * FROM "drawer_session" WHERE "staffId" = $1 AND "status" = 'open' LIMIT 1 FOR SHARE;`
+)
+
+func (r *drawerRepo) GetOpenSession(ctx context.Context, staffId uuid.UUID) (session model.DrawerSession, err error) {
+	err = r.db.QueryRowxContext(ctx, getOpenDrawerSessionQuery, staffId).StructScan(&session)
+	return session, err
+}
+
+// GetOpenSessionForShare keeps the session from being closed until tx ends,
+// so a checkout can't land on a session whose Z report was already taken.
+func (r *drawerRepo) GetOpenSessionForShare(ctx context.Context, tx *sqlx.Tx, staffId uuid.UUID) (session model.DrawerSession, err error) {
+	err = tx.QueryRowxContext(ctx, getOpenDrawerSessionForShareQuery, staffId).StructScan(&session)
+	return session, err
+}
+
+var (
+	closeDrawerSessionQuery = `UPDATE "drawer_session" SET "status" = 'closed', "countedCash" = $2, "closedAt" = NOW()
+	WHERE "id" = $1 AND "status" = 'open'
+	RETURNING *;`
+)
+
+// CloseSession closes an open session, sql.ErrNoRows is returned when it
+// was already closed. The update waits for the share locks of checkouts.
+func (r *drawerRepo) CloseSession(ctx context.Context, id uuid.UUID, countedCash int) (session model.DrawerSession, err error) {
+	err = r.db.QueryRowxContext(ctx, closeDrawerSessionQuery, id, countedCash).StructScan(&session)
+	return session, err
+}
+
+var (
+	createDrawerMovementQuery = `INSERT INTO "drawer_movement" ("id", "sessionId", "staffId", "type", "amount", "reason", "createdAt")
+	SELECT $1, "id", $3, $4, $5, $6, NOW() FROM "drawer_session" WHERE "id" = $2 AND "status" = 'open' FOR SHARE
+	RETURNING *;`
+)
+
+// CreateMovement records a pay-in or pay-out, sql.ErrNoRows is returned when
+// the session isn't open.
+func (r *drawerRepo) CreateMovement(ctx context.Context, movement model.DrawerMovement) (result model.DrawerMovement, err error) {
+	err = r.db.QueryRowxContext(ctx, createDrawerMovementQuery, uuid.New(), movement.SessionId, movement.StaffId, movement.Type, movement.Amount, movement.Reason).StructScan(&result)
+	return result, err
+}
+
+var (
+	getDrawerMovementTotalsQuery = `SELECT "type", SUM("amount") FROM "drawer_movement" WHERE "sessionId" = $1 GROUP BY "type";`
+)
+
+func (r *drawerRepo) GetMovementTotals(ctx context.Context, sessionId uuid.UUID) (totals map[model.DrawerMovementType]int, err error) {
+	rows, err := r.db.QueryContext(ctx, getDrawerMovementTotalsQuery, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals = make(map[model.DrawerMovementType]int)
+	for rows.Next() {
+		var movementType model.DrawerMovementType
+		var amount int
+		if err := rows.Scan(&movementType, &amount); err != nil {
+			return nil, err
+		}
+		totals[movementType] = amount
+	}
+
+	return totals, rows.Err()
+}
+
+var (
+	getDrawerSessionSalesQuery = `SELECT
+		COUNT(*) FILTER (WHERE "voidedAt" IS NULL),
+		COALESCE(SUM("total") FILTER (WHERE "voidedAt" IS NULL), 0),
+		COALESCE(SUM("paid" - "change") FILTER (WHERE "voidedAt" IS NULL), 0),
+		COUNT(*) FILTER (WHERE "voidedAt" IS NOT NULL),
+		COALESCE(SUM("total") FILTER (WHERE "voidedAt" IS NOT NULL), 0)
+	FROM "transaction" WHERE "sessionId" = $1;`
+)
+
+func (r *drawerRepo) GetSessionSales(ctx context.Context, sessionId uuid.UUID) (sales model.DrawerSales, err error) {
+	err = r.db.QueryRowxContext(ctx, getDrawerSessionSalesQuery, sessionId).Scan(&sales.Transactions, &sales.Total, &sales.Cash, &sales.Voided, &sales.VoidedTotal)
+	return sales, err
+}
+
+var (
+	getDrawerSessionPaymentsQuery = `SELECT p->>'method', SUM((p->>'amount')::int)
+	FROM "transaction" t CROSS JOIN jsonb_array_elements(COALESCE(t."payments", '[]')) p
+	WHERE t."sessionId" = $1 AND t."voidedAt" IS NULL
+	GROUP BY 1;`
+)
+
+// GetSessionPayments sums the non-cash tenders of the session per method
+func (r *drawerRepo) GetSessionPayments(ctx context.Context, sessionId uuid.UUID) (payments map[string]int, err error) {
+	rows, err := r.db.QueryContext(ctx, getDrawerSessionPaymentsQuery, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments = make(map[string]int)
+	for rows.Next() {
+		var method sql.NullString
+		var amount int
+		if err := rows.Scan(&method, &amount); err != nil {
+			return nil, err
+		}
+		payments[method.String] = amount
+	}
+
+	return payments, rows.Err()
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"eniqilo-store/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type GiftCardRepo interface {
+	NewTx() (*sqlx.Tx, error)
+	CreateGiftCard(ctx context.Context, tx *sqlx.Tx, card model.GiftCard) (giftCard model.GiftCard, err error)
+	GetGiftCardByCode(ctx context.Context, code string) (giftCard model.GiftCard, err error)
+	GetGiftCardByCodeForUpdate(ctx context.Context, tx *sqlx.Tx, code string) (giftCard model.GiftCard, err error)
+	UpdateGiftCardBalance(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, balance int) (err error)
+	CreateGiftCardMovement(ctx context.Context, tx *sqlx.Tx, movement model.GiftCardMovement) (err error)
+	GetGiftCardMovements(ctx context.Context, giftCardId uuid.UUID) (movements []model.GiftCardMovement, err error)
+}
+
+type giftCardRepo struct {
+	db *sqlx.DB
+}
+
+func NewGiftCardRepo(db *sqlx.DB) GiftCardRepo {
+	return &giftCardRepo{
+		db: db,
+	}
+}
+
+func (r *giftCardRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+var (
+	createGiftCardQuery = `INSERT INTO "gift_card" ("id", "code", "type", "customerId", "initialValue", "balance", "createdAt")
+	VALUES ($1, $2, $3, $4, $5, $6, NOW())
+	RETURNING *;`
+)
+
+func (r *giftCardRepo) CreateGiftCard(ctx context.Context, tx *sqlx.Tx, card model.GiftCard) (giftCard model.GiftCard, err error) {
+	err = tx.QueryRowxContext(ctx, createGiftCardQuery, uuid.New(), card.Code, card.Type, card.CustomerId, card.InitialValue, card.Balance).StructScan(&giftCard)
+	return giftCard, err
+}
+
+var (
+	getGiftCardByCodeQuery          = `SELECT * FROM "gift_card" WHERE "code" = $1 LIMIT 1;`
+	getGiftCardByCodeForUpdateQuery = `SELECT * FROM "gift_card" WHERE "code" = $1 LIMIT 1 FOR UPDATE;`
+)
+
+func (r *giftCardRepo) GetGiftCardByCode(ctx context.Context, code string) (giftCard model.GiftCard, err error) {
+	err = r.db.QueryRowxContext(ctx, getGiftCardByCodeQuery, code).StructScan(&giftCard)
+	return giftCard, err
+}
+
+func (r *giftCardRepo) GetGiftCardByCodeForUpdate(ctx context.Context, tx *sqlx.Tx, code string) (giftCard model.GiftCard, err error) {
+	err = tx.QueryRowxContext(ctx, getGiftCardByCodeForUpdateQuery, code).StructScan(&giftCard)
+	return giftCard, err
+}
+
+var (
+	updateGiftCardBalanceQuery = `UPDATE "gift_card" SET "balance" = $1 WHERE "id" = $2;`
+)
+
+func (r *giftCardRepo) UpdateGiftCardBalance(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, balance int) (err error) {
+	_, err = tx.ExecContext(ctx, updateGiftCardBalanceQuery, balance, id)
+	return err
+}
+
+var (
+	createGiftCardMovementQuery = `INSERT INTO "gift_card_movement" ("id", "giftCardId", "transactionId", "type", "amount", "balance", "createdAt") VALUES ($1, $2, $3, $4, $5, $6, NOW());`
+)
+
+func (r *giftCardRepo) CreateGiftCardMovement(ctx context.Context, tx *sqlx.Tx, movement model.GiftCardMovement) (err error) {
+	_, err = tx.ExecContext(ctx, createGiftCardMovementQuery, uuid.New(), movement.GiftCardId, movement.TransactionId, movement.Type, movement.Amount, movement.Balance)
+	return err
+}
+
+var (
+	getGiftCardMovementsQuery = `SELECT * FROM "gift_card_movement" WHERE "giftCardId" = $1 ORDER BY "createdAt" DESC;`
+)
+
+func (r *giftCardRepo) GetGiftCardMovements(ctx context.Context, giftCardId uuid.UUID) (movements []model.GiftCardMovement, err error) {
+	movements = []model.GiftCardMovement{}
+	err = r.db.SelectContext(ctx, &movements, getGiftCardMovementsQuery, giftCardId)
+	return movements, err
+}
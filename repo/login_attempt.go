@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type LoginAttemptRepo interface {
+	NewTx() (*sqlx.Tx, error)
+	GetLockRemaining(ctx context.Context, keys []string) (remaining time.Duration, err error)
+	ReserveAttempt(ctx context.Context, tx *sqlx.Tx, key string, window time.Duration) (attempts int, remaining time.Duration, err error)
+	Lock(ctx context.Context, tx *sqlx.Tx, key string, duration time.Duration) (err error)
+	Release(ctx context.Context, key string) (err error)
+	Reset(ctx context.Context, key string) (err error)
+}
+
+type loginAttemptRepo struct {
+	db *sqlx.DB
+}
+
+func NewLoginAttemptRepo(db *sqlx.DB) LoginAttemptRepo {
+	return &loginAttemptRepo{
+		db: db,
+	}
+}
+
+func (r *loginAttemptRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+// The lock times are computed by the database so they don't depend on the
+// clock or time zone of the app server.
+var (
+	getLoginLockRemainingQuery = `SELECT COALESCE(MAX(EXTRACT(EPOCH FROM "lockedUntil" - NOW())), 0)
+	FROM "login_attempt"
+	WHERE "key" = ANY ($1) AND "lockedUntil" > NOW();`
+	// a locked key is returned as it is, the attempt is not counted
+	reserveLoginAttemptQuery = `INSERT INTO "login_attempt" ("key", "failures", "lastFailedAt")
+	VALUES ($1, 1, NOW())
+	ON CONFLICT ("key") DO UPDATE SET
+		"failures" = CASE
+			WHEN "login_attempt"."lockedUntil" > NOW() THEN "login_attempt"."failures"
+			WHEN "login_attempt"."lastFailedAt" < NOW() - make_interval(secs => $2) THEN 1
+			ELSE "login_attempt"."failures" + 1
+		END,
+		"lastFailedAt" = CASE
+			WHEN "login_attempt"."lockedUntil" > NOW() THEN "login_attempt"."lastFailedAt"
+			ELSE NOW()
+		END
+	RETURNING "failures", COALESCE(GREATEST(EXTRACT(EPOCH FROM "lockedUntil" - NOW()), 0), 0);`
+	lockLoginQuery    = `UPDATE "login_attempt" SET "lockedUntil" = NOW() + make_interval(secs => $2) WHERE "key" = $1;`
+	releaseLoginQuery = `UPDATE "login_attempt" SET "failures" = GREATEST("failures" - 1, 0) WHERE "key" = $1;`
+	resetLoginQuery   = `DELETE FROM "login_attempt" WHERE "key" = $1;`
+)
+
+// GetLockRemaining returns how long the longest lock among keys still runs,
+// zero when none of them is locked.
+func (r *loginAttemptRepo) GetLockRemaining(ctx context.Context, keys []string) (remaining time.Duration, err error) {
+	var seconds float64
+	err = r.db.GetContext(ctx, &seconds, getLoginLockRemainingQuery, pq.Array(keys))
+	return time.Duration(seconds * float64(time.Second)), err
+}
+
+// ReserveAttempt counts a login attempt for key as a failure before the
+// credentials are checked and returns the attempts so far along with how
+// long the key is still locked. Attempts older than window are forgotten
+// and counting starts over. The row stays locked until tx ends, so
+// concurrent attempts on key wait for the lock set by this one.
+func (r *loginAttemptRepo) ReserveAttempt(ctx context.Context, tx *sqlx.Tx, key string, window time.Duration) (attempts int, remaining time.Duration, err error) {
+	var seconds float64
+	err = tx.QueryRowxContext(ctx, reserveLoginAttemptQuery, key, window.Seconds()).Scan(&attempts, &seconds)
+	return attempts, time.Duration(seconds * float64(time.Second)), err
+}
+
+func (r *loginAttemptRepo) Lock(ctx context.Context, tx *sqlx.Tx, key string, duration time.Duration) (err error) {
+	_, err = tx.ExecContext(ctx, lockLoginQuery, key, duration.Seconds())
+	return err
+}
+
+// Release takes back an attempt reserved for key that turned out to be a
+// successful login.
+func (r *loginAttemptRepo) Release(ctx context.Context, key string) (err error) {
+	_, err = r.db.ExecContext(ctx, releaseLoginQuery, key)
+	return err
+}
+
+func (r *loginAttemptRepo) Reset(ctx context.Context, key string) (err error) {
+	_, err = r.db.ExecContext(ctx, resetLoginQuery, key)
+	return err
+}
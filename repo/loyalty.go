@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"context"
+	"eniqilo-store/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type LoyaltyRepo interface {
+	LockCustomer(ctx context.Context, tx *sqlx.Tx, customerId uuid.UUID) (err error)
+	GetPointBalance(ctx context.Context, customerId uuid.UUID) (balance int, err error)
+	GetPointBalanceTx(ctx context.Context, tx *sqlx.Tx, customerId uuid.UUID) (balance int, err error)
+	GetPointHistory(ctx context.Context, customerId uuid.UUID, params model.GetPointHistoryParam) (history []model.LoyaltyPoint, err error)
+	CreatePointEntry(ctx context.Context, tx *sqlx.Tx, entry model.LoyaltyPoint) (err error)
+	ReverseTransactionPoints(ctx context.Context, tx *sqlx.Tx, transactionId uuid.UUID) (err error)
+}
+
+type loyaltyRepo struct {
+	db *sqlx.DB
+}
+
+func NewLoyaltyRepo(db *sqlx.DB) LoyaltyRepo {
+	return &loyaltyRepo{
+		db: db,
+	}
+}
+
+var (
+	lockCustomerQuery = `SELECT "userId" FROM "customer" WHERE "userId" = $1 FOR UPDATE;`
+)
+
+// LockCustomer serializes point movements of a single customer so the
+// balance can't be spent twice by concurrent checkouts.
+func (r *loyaltyRepo) LockCustomer(ctx context.Context, tx *sqlx.Tx, customerId uuid.UUID) (err error) {
+	var id uuid.UUID
+	return tx.QueryRowxContext(ctx, lockCustomerQuery, customerId).Scan(&id)
+}
+
+var (
+	getPointBalanceQuery = `SELECT COALESCE(SUM("points"), 0) FROM "loyalty_point" WHERE "customerId" = $1;`
+)
+
+func (r *loyaltyRepo) GetPointBalance(ctx context.Context, customerId uuid.UUID) (balance int, err error) {
+	err = r.db.QueryRowxContext(ctx, getPointBalanceQuery, customerId).Scan(&balance)
+	return balance, err
+}
+
+func (r *loyaltyRepo) GetPointBalanceTx(ctx context.Context, tx *sqlx.Tx, customerId uuid.UUID) (balance int, err error) {
+	err = tx.QueryRowxContext(ctx, getPointBalanceQuery, customerId).Scan(&balance)
+	return balance, err
+}
+
+var (
+	getPointHistoryQuery = `SELECT * FROM "loyalty_point" WHERE "customerId" = $1 ORDER BY "createdAt" DESC LIMIT $2 OFFSET $3;`
+)
+
+func (r *loyaltyRepo) GetPointHistory(ctx context.Context, customerId uuid.UUID, params model.GetPointHistoryParam) (history []model.LoyaltyPoint, err error) {
+	history = []model.LoyaltyPoint{}
+	err = r.db.SelectContext(ctx, &history, getPointHistoryQuery, customerId, params.Limit, params.Offset)
+	return history, err
+}
+
+var (
+	createPointEntryQuery = `INSERT INTO "loyalty_point" ("id", "customerId", "transactionId", "type", "points", "createdAt") VALUES ($1, $2, $3, $4, $5, NOW());`
+)
+
+func (r *loyaltyRepo) CreatePointEntry(ctx context.Context, tx *sqlx.Tx, entry model.LoyaltyPoint) (err error) {
+	_, err = tx.ExecContext(ctx, createPointEntryQuery, uuid.New(), entry.CustomerId, entry.TransactionId, entry.Type, entry.Points)
+	return err
+}
+
+var (
+	getTransactionPointsReversalQuery = `SELECT r."customerId", r."points"
+	FROM (
+		SELECT t."customerId",
+			CASE WHEN t."points" > 0 THEN -LEAST(t."points", GREATEST(b."balance", 0)) ELSE -t."points" END AS "points"
+		FROM (
+			SELECT "customerId", SUM("points") AS "points"
+			FROM "loyalty_point" WHERE "transactionId" = $1
+			GROUP BY "customerId"
+		) t
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM("points"), 0) AS "balance" FROM "loyalty_point" WHERE "customerId" = t."customerId"
+		) b
+	) r
+	WHERE r."points" <> 0;`
+)
+
+// ReverseTransactionPoints writes a compensating entry that cancels every
+// point earned or redeemed by the given transaction. Earned points that
+// were already spent are only taken back down to a zero balance, so the
+// customer has to be locked with LockCustomer first.
+func (r *loyaltyRepo) ReverseTransactionPoints(ctx context.Context, tx *sqlx.Tx, transactionId uuid.UUID) (err error) {
+	var reversals []model.LoyaltyPoint
+	err = tx.SelectContext(ctx, &reversals, getTransactionPointsReversalQuery, transactionId)
+	if err != nil {
+		return err
+	}
+
+	for _, reversal := range reversals {
+		reversal.TransactionId = &transactionId
+		reversal.Type = model.PointReversal
+		if err = r.CreatePointEntry(ctx, tx, reversal); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -14,11 +14,13 @@ import (
 )
 
 type ProductRepo interface {
+	NewTx() (*sqlx.Tx, error)
 	GetProductByID(ctx context.Context, id uuid.UUID) (model.Product, error)
+	GetProductByIDForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (model.Product, error)
 	GetProduct(ctx context.Context, param model.GetProductParam) ([]model.Product, error)
-	CreateProduct(ctx context.Context, data model.Product) (model.Product, error)
-	UpdateProduct(ctx context.Context, data model.Product) error
-	DeleteProduct(ctx context.Context, id uuid.UUID) error
+	CreateProduct(ctx context.Context, tx *sqlx.Tx, data model.Product) (model.Product, error)
+	UpdateProduct(ctx context.Context, tx *sqlx.Tx, data model.Product) error
+	DeleteProduct(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) error
 }
 
 type productRepo struct {
@@ -29,12 +31,16 @@ func NewProductRepo(db *sqlx.DB) ProductRepo {
 	return &productRepo{db: db}
 }
 
+func (r *productRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
 var createProductQuery = `INSERT INTO product 
     ("id",name, sku, category, "imageUrl", notes, stock, price, "isAvailable", location, "createdAt")
     VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
     RETURNING "id", "createdAt"`
 
-func (r *productRepo) CreateProduct(ctx context.Context, data model.Product) (model.Product, error) {
+func (r *productRepo) CreateProduct(ctx context.Context, tx *sqlx.Tx, data model.Product) (model.Product, error) {
 
 	// Generate UUID for the product ID
 	newUUID, err := uuid.NewRandom()
@@ -44,7 +50,7 @@ func (r *productRepo) CreateProduct(ctx context.Context, data model.Product) (mo
 	data.ID = newUUID
 	createdAt := time.Now()
 
-	err = r.db.QueryRowxContext(ctx, createProductQuery,
+	err = tx.QueryRowxContext(ctx, createProductQuery,
 		data.ID, data.Name, data.SKU, data.Category, data.ImageURL, data.Notes, data.Stock, data.Price, data.IsAvailable, data.Location, createdAt).Scan(&data.ID, &data.CreatedAt)
 	if err != nil {
 		return model.Product{}, fmt.Errorf("error executing query: %v", err)
@@ -58,8 +64,8 @@ SET "name"=$1, sku=$2, "category"=$3, stock=$4, price=$5, "imageUrl"=$6, notes=$
 WHERE id=$10;
 `
 
-func (r *productRepo) UpdateProduct(ctx context.Context, data model.Product) error {
-	err := r.db.QueryRowxContext(ctx, updateProductQuery,
+func (r *productRepo) UpdateProduct(ctx context.Context, tx *sqlx.Tx, data model.Product) error {
+	err := tx.QueryRowxContext(ctx, updateProductQuery,
 		data.Name, data.SKU, data.Category, data.Stock, data.Price, data.ImageURL, data.Notes, data.IsAvailable, data.Location, data.ID).Err()
 	if err != nil {
 		return err
@@ -68,9 +74,9 @@ func (r *productRepo) UpdateProduct(ctx context.Context, data model.Product) err
 	return nil
 }
 
-func (r *productRepo) DeleteProduct(ctx context.Context, id uuid.UUID) error {
+func (r *productRepo) DeleteProduct(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) error {
 	query := `DELETE FROM product WHERE id = $1`
-	result, err := r.db.ExecContext(ctx, query, id)
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -98,8 +104,26 @@ func (r *productRepo) GetProductByID(ctx context.Context, id uuid.UUID) (model.P
 	return product, nil
 }
 
+// GetProductByIDForUpdate locks the product until tx ends, so the state
+// logged as before is the one the change replaces.
+func (r *productRepo) GetProductByIDForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (product model.Product, err error) {
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM product WHERE id = $1 FOR UPDATE`, id).StructScan(&product)
+	return product, err
+}
+
+// availableProductSource exposes the product table with reserved stock
+// already taken off, so filters on "stock" work on the sellable quantity.
+var availableProductSource = `(SELECT p."id", p."name", p."sku", p."category",
+	p."stock" - COALESCE((SELECT SUM(r."quantity") FROM "stock_reservation" r WHERE r."productId" = p."id" AND r."expiresAt" > NOW()), 0) AS "stock",
+	p."price", p."imageUrl", p."notes", p."isAvailable", p."location", p."createdAt"
+	FROM product p) AS product`
+
 func (r *productRepo) GetProduct(ctx context.Context, param model.GetProductParam) (products []model.Product, err error) {
-	query := `SELECT * FROM product WHERE true ` + generateGetProductSQLFilter(param)
+	source := "product"
+	if param.AvailableStock {
+		source = availableProductSource
+	}
+	query := `SELECT * FROM ` + source + ` WHERE true ` + generateGetProductSQLFilter(param)
 	rows, err := r.db.QueryxContext(ctx, query)
 	if err != nil {
 		return products, err
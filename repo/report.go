@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"eniqilo-store/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ReportRepo interface {
+	GetStaffSales(ctx context.Context, params model.GetStaffSalesParam) (sales []model.StaffSales, err error)
+}
+
+type reportRepo struct {
+	db *sqlx.DB
+}
+
+func NewReportRepo(db *sqlx.DB) ReportRepo {
+	return &reportRepo{
+		db: db,
+	}
+}
+
+var (
+	// sales made before transactions carried a cashier have no staffId and
+	// are left out
+	getStaffSalesQuery = `WITH "sales" AS (
+		SELECT t."staffId", COUNT(*) AS "transactions",
+			COALESCE(SUM(t."total"), 0) AS "revenue",
+			COALESCE(SUM(i."items"), 0) AS "itemsSold"
+		FROM "transaction" t
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM((d->>'quantity')::int), 0) AS "items"
+			FROM jsonb_array_elements(COALESCE(t."productDetails", '[]'::jsonb)) d
+		) i
+		WHERE t."staffId" IS NOT NULL AND t."createdAt" >= $1 AND t."createdAt" <= $2
+		GROUP BY t."staffId"
+	), "refunds" AS (
+		SELECT "staffId", COUNT(*) AS "refunds", COALESCE(SUM("total"), 0) AS "refundAmount"
+		FROM "transaction"
+		WHERE "staffId" IS NOT NULL AND "voidedAt" >= $1 AND "voidedAt" <= $2
+		GROUP BY "staffId"
+	)
+	SELECT s."userId" AS "staffId", s."name",
+		COALESCE(t."transactions", 0) AS "transactions",
+		COALESCE(t."revenue", 0) AS "revenue",
+		COALESCE(t."itemsSold", 0) AS "itemsSold",
+		COALESCE(r."refunds", 0) AS "refunds",
+		COALESCE(r."refundAmount", 0) AS "refundAmount"
+	FROM "staff" s
+	LEFT JOIN "sales" t ON t."staffId" = s."userId"
+	LEFT JOIN "refunds" r ON r."staffId" = s."userId"
+	WHERE (t."staffId" IS NOT NULL OR r."staffId" IS NOT NULL) AND ($3::uuid IS NULL OR s."userId" = $3)
+	ORDER BY "revenue" DESC, s."name";`
+)
+
+// GetStaffSales reports the staff that sold or had a sale voided within
+// the period
+func (r *reportRepo) GetStaffSales(ctx context.Context, params model.GetStaffSalesParam) (sales []model.StaffSales, err error) {
+	sales = []model.StaffSales{}
+	err = r.db.SelectContext(ctx, &sales, getStaffSalesQuery, params.From, params.To, params.StaffId)
+	return sales, err
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"eniqilo-store/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type ReservationRepo interface {
+	NewTx() (*sqlx.Tx, error)
+	LockProductStocks(ctx context.Context, tx *sqlx.Tx, productIDs []string) (map[string]int, error)
+	GetReservedStocks(ctx context.Context, productIDs []string, excludeHolderId *uuid.UUID) (map[string]int, error)
+	GetReservedStocksTx(ctx context.Context, tx *sqlx.Tx, productIDs []string, excludeHolderId *uuid.UUID) (map[string]int, error)
+	CreateReservation(ctx context.Context, tx *sqlx.Tx, reservation model.StockReservation, ttl time.Duration) (err error)
+	DeleteReservations(ctx context.Context, holderId uuid.UUID) (err error)
+	DeleteReservationsTx(ctx context.Context, tx *sqlx.Tx, holderId uuid.UUID) (err error)
+	DeleteExpiredReservations(ctx context.Context) (deleted int64, err error)
+}
+
+type reservationRepo struct {
+	db *sqlx.DB
+}
+
+func NewReservationRepo(db *sqlx.DB) ReservationRepo {
+	return &reservationRepo{
+		db: db,
+	}
+}
+
+func (r *reservationRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+var (
+	lockProductStocksQuery = `SELECT id, stock FROM product WHERE id = ANY ($1) FOR UPDATE;`
+)
+
+// LockProductStocks locks the product rows so stock can't be reserved or
+// sold by someone else until the transaction ends.
+func (r *reservationRepo) LockProductStocks(ctx context.Context, tx *sqlx.Tx, productIDs []string) (map[string]int, error) {
+	return scanStocks(tx.QueryContext(ctx, lockProductStocksQuery, pq.Array(productIDs)))
+}
+
+var (
+	getReservedStocksQuery = `SELECT "productId", SUM("quantity") FROM "stock_reservation"
+	WHERE "productId" = ANY ($1) AND "expiresAt" > NOW() AND ($2::uuid IS NULL OR "holderId" <> $2)
+	GROUP BY "productId";`
+)
+
+// GetReservedStocks sums the active reservations per product, leaving out
+// the ones made by excludeHolderId.
+func (r *reservationRepo) GetReservedStocks(ctx context.Context, productIDs []string, excludeHolderId *uuid.UUID) (map[string]int, error) {
+	return scanStocks(r.db.QueryContext(ctx, getReservedStocksQuery, pq.Array(productIDs), excludeHolderId))
+}
+
+func (r *reservationRepo) GetReservedStocksTx(ctx context.Context, tx *sqlx.Tx, productIDs []string, excludeHolderId *uuid.UUID) (map[string]int, error) {
+	return scanStocks(tx.QueryContext(ctx, getReservedStocksQuery, pq.Array(productIDs), excludeHolderId))
+}
+
+var (
+	createReservationQuery = `INSERT INTO "stock_reservation" ("id", "productId", "holderId", "quantity", "expiresAt", "createdAt") VALUES ($1, $2, $3, $4, NOW() + $5 * interval '1 second', NOW());`
+)
+
+// CreateReservation lets the reservation expire ttl after the database's
+// NOW(), the clock the expiry is compared against.
+func (r *reservationRepo) CreateReservation(ctx context.Context, tx *sqlx.Tx, reservation model.StockReservation, ttl time.Duration) (err error) {
+	_, err = tx.ExecContext(ctx, createReservationQuery, uuid.New(), reservation.ProductId, reservation.HolderId, reservation.Quantity, ttl.Seconds())
+	return err
+}
+
+var (
+	deleteReservationsQuery        = `DELETE FROM "stock_reservation" WHERE "holderId" = $1;`
+	deleteExpiredReservationsQuery = `DELETE FROM "stock_reservation" WHERE "expiresAt" <= NOW();`
+)
+
+func (r *reservationRepo) DeleteReservations(ctx context.Context, holderId uuid.UUID) (err error) {
+	_, err = r.db.ExecContext(ctx, deleteReservationsQuery, holderId)
+	return err
+}
+
+func (r *reservationRepo) DeleteReservationsTx(ctx context.Context, tx *sqlx.Tx, holderId uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, deleteReservationsQuery, holderId)
+	return err
+}
+
+func (r *reservationRepo) DeleteExpiredReservations(ctx context.Context) (deleted int64, err error) {
+	result, err := r.db.ExecContext(ctx, deleteExpiredReservationsQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
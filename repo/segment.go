@@ -0,0 +1,220 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"eniqilo-store/model"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type SegmentRepo interface {
+	NewTx() (*sqlx.Tx, error)
+	CreateSegment(ctx context.Context, tx *sqlx.Tx, segment model.Segment) (result model.Segment, err error)
+	GetSegments(ctx context.Context) (segments []model.Segment, err error)
+	GetSegmentById(ctx context.Context, id uuid.UUID) (segment model.Segment, err error)
+	DeleteSegment(ctx context.Context, id uuid.UUID) (err error)
+	RefreshSegmentMembers(ctx context.Context, tx *sqlx.Tx, segment model.Segment) (members int, err error)
+	RecomputeTiers(ctx context.Context, since time.Time, silver, gold, platinum int) (updated int64, err error)
+}
+
+type segmentRepo struct {
+	db *sqlx.DB
+}
+
+func NewSegmentRepo(db *sqlx.DB) SegmentRepo {
+	return &segmentRepo{
+		db: db,
+	}
+}
+
+func (r *segmentRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+const segmentColumns = `"id", "name", "description", "rules", "refreshedAt", "createdAt",
+	(SELECT COUNT(*) FROM "customer_segment_member" m WHERE m."segmentId" = "customer_segment"."id")`
+
+func scanSegment(row sqlx.ColScanner) (segment model.Segment, err error) {
+	var rulesByte []byte
+	err = row.Scan(&segment.ID, &segment.Name, &segment.Description, &rulesByte, &segment.RefreshedAt, &segment.CreatedAt, &segment.Members)
+	if err != nil {
+		return model.Segment{}, err
+	}
+
+	if err = json.Unmarshal(rulesByte, &segment.Rules); err != nil {
+		return model.Segment{}, fmt.Errorf("segment %s has invalid rules: %w", segment.ID, err)
+	}
+
+	return segment, nil
+}
+
+var (
+	createSegmentQuery = `INSERT INTO "customer_segment" ("id", "name", "description", "rules", "createdAt")
+	VALUES ($1, $2, $3, $4, NOW())
+	RETURNING ` + segmentColumns + `;`
+)
+
+func (r *segmentRepo) CreateSegment(ctx context.Context, tx *sqlx.Tx, segment model.Segment) (result model.Segment, err error) {
+	rulesByte, err := json.Marshal(segment.Rules)
+	if err != nil {
+		return model.Segment{}, err
+	}
+	return scanSegment(tx.QueryRowxContext(ctx, createSegmentQuery, uuid.New(), segment.Name, segment.Description, rulesByte))
+}
+
+var (
+	getSegmentsQuery = `SELECT ` + segmentColumns + ` FROM "customer_segment" ORDER BY "name";`
+)
+
+func (r *segmentRepo) GetSegments(ctx context.Context) (segments []model.Segment, err error) {
+	rows, err := r.db.QueryxContext(ctx, getSegmentsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments = []model.Segment{}
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, rows.Err()
+}
+
+var (
+	getSegmentByIdQuery = `SELECT ` + segmentColumns + ` FROM "customer_segment" WHERE "id" = $1 LIMIT 1;`
+)
+
+func (r *segmentRepo) GetSegmentById(ctx context.Context, id uuid.UUID) (segment model.Segment, err error) {
+	return scanSegment(r.db.QueryRowxContext(ctx, getSegmentByIdQuery, id))
+}
+
+var (
+	deleteSegmentQuery = `DELETE FROM "customer_segment" WHERE "id" = $1;`
+)
+
+func (r *segmentRepo) DeleteSegment(ctx context.Context, id uuid.UUID) (err error) {
+	_, err = r.db.ExecContext(ctx, deleteSegmentQuery, id)
+	return err
+}
+
+var (
+	clearSegmentMembersQuery  = `DELETE FROM "customer_segment_member" WHERE "segmentId" = $1;`
+	markSegmentRefreshedQuery = `UPDATE "customer_segment" SET "refreshedAt" = NOW() WHERE "id" = $1;`
+)
+
+// RefreshSegmentMembers replaces the members of the segment with the
+// customers currently meeting its rules.
+func (r *segmentRepo) RefreshSegmentMembers(ctx context.Context, tx *sqlx.Tx, segment model.Segment) (members int, err error) {
+	if _, err = tx.ExecContext(ctx, clearSegmentMembersQuery, segment.ID); err != nil {
+		return 0, err
+	}
+
+	where, args := segmentFilter(segment.Rules, []interface{}{segment.ID})
+	result, err := tx.ExecContext(ctx, `INSERT INTO "customer_segment_member" ("segmentId", "customerId", "addedAt")
+	SELECT $1, c."userId", NOW() FROM "customer" c WHERE c."deletedAt" IS NULL`+where+`;`, args...)
+	if err != nil {
+		return 0, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.ExecContext(ctx, markSegmentRefreshedQuery, segment.ID); err != nil {
+		return 0, err
+	}
+
+	return int(inserted), nil
+}
+
+// spentOn is what the customer spent on merchandise in transaction t,
+// gift cards bought are only spent once they are redeemed.
+const spentOn = `(t."total" - COALESCE((SELECT SUM((g->>'amount')::int) FROM jsonb_array_elements(t."giftCards") g), 0))`
+
+// segmentFilter turns the rules into conditions on the customer c, all of
+// them have to be met. Values are appended to args as query arguments.
+func segmentFilter(rules []model.SegmentRule, args []interface{}) (string, []interface{}) {
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var where strings.Builder
+	for _, rule := range rules {
+		// the customer's transactions t that count for the rule
+		purchased := `t."customerId" = c."userId" AND t."voidedAt" IS NULL`
+		if rule.WithinDays != nil {
+			purchased += ` AND t."createdAt" >= NOW() - make_interval(days => ` + arg(*rule.WithinDays) + `::int)`
+		}
+		purchases := `FROM "transaction" t WHERE ` + purchased
+
+		switch rule.Type {
+		case model.RuleBoughtCategory:
+			where.WriteString(` AND EXISTS (SELECT 1 FROM "transaction" t
+				CROSS JOIN jsonb_array_elements(t."productDetails") d
+				JOIN "product" p ON p."id"::text = d->>'productId'
+				WHERE ` + purchased + ` AND p."category"::text = ` + arg(string(*rule.Category)) + `)`)
+		case model.RuleBoughtProduct:
+			where.WriteString(` AND EXISTS (SELECT 1 ` + purchases + ` AND t."productDetails" @> jsonb_build_array(jsonb_build_object('productId', ` + arg(*rule.ProductId) + `::text)))`)
+		case model.RuleTotalSpend, model.RuleVisits:
+			aggregate := `COALESCE(SUM(` + spentOn + `), 0)`
+			if rule.Type == model.RuleVisits {
+				aggregate = `COUNT(*)`
+			}
+			if rule.Min != nil {
+				where.WriteString(` AND (SELECT ` + aggregate + ` ` + purchases + `) >= ` + arg(*rule.Min))
+			}
+			if rule.Max != nil {
+				where.WriteString(` AND (SELECT ` + aggregate + ` ` + purchases + `) <= ` + arg(*rule.Max))
+			}
+		case model.RuleTier:
+			tiers := make([]string, 0, len(rule.Tiers))
+			for _, tier := range rule.Tiers {
+				tiers = append(tiers, string(tier))
+			}
+			where.WriteString(` AND c."tier" = ANY (` + arg(pq.Array(tiers)) + `)`)
+		case model.RuleBirthdayMonth:
+			where.WriteString(` AND EXTRACT(MONTH FROM c."birthday") = ` + arg(*rule.Month))
+		}
+	}
+
+	return where.String(), args
+}
+
+var (
+	// customers without purchases in the window drop back to standard
+	recomputeTiersQuery = `UPDATE "customer" c SET "tier" = CASE
+		WHEN s."spend" >= $4 THEN 'platinum'
+		WHEN s."spend" >= $3 THEN 'gold'
+		WHEN s."spend" >= $2 THEN 'silver'
+		ELSE 'standard'
+	END
+	FROM (
+		SELECT cu."userId", COALESCE(SUM(` + spentOn + `), 0) AS "spend"
+		FROM "customer" cu
+		LEFT JOIN "transaction" t ON t."customerId" = cu."userId" AND t."voidedAt" IS NULL AND t."createdAt" >= $1
+		WHERE cu."deletedAt" IS NULL
+		GROUP BY cu."userId"
+	) s
+	WHERE c."userId" = s."userId";`
+)
+
+// RecomputeTiers sets the tier of every customer from what they spent since
+func (r *segmentRepo) RecomputeTiers(ctx context.Context, since time.Time, silver, gold, platinum int) (updated int64, err error) {
+	result, err := r.db.ExecContext(ctx, recomputeTiersQuery, since, silver, gold, platinum)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -1,14 +1,40 @@
 package repo
 
 import (
+	"context"
+	"database/sql"
 	"eniqilo-store/model"
+	"strconv"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type StaffRepo interface {
+	NewTx() (*sqlx.Tx, error)
 	GetStaff(phoneNumber string) (*model.Staff, error)
-	CreateStaff(newStaff model.Staff, hashPassword string) error
+	GetStaffById(ctx context.Context, id uuid.UUID) (staff model.Staff, err error)
+	GetStaffByIdForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (staff model.Staff, err error)
+	GetStaffList(ctx context.Context, params model.GetStaffParam) (staff []model.Staff, err error)
+	CountStaffLocked(ctx context.Context, tx *sqlx.Tx) (count int, err error)
+	CreateStaff(ctx context.Context, tx *sqlx.Tx, newStaff model.Staff, hashPassword string) error
+	UpdateStaffRole(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, role model.StaffRole) (staff model.Staff, err error)
+	SetStaffDeactivated(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, deactivated bool) (staff model.Staff, err error)
+	CreateInvite(ctx context.Context, tx *sqlx.Tx, invite model.StaffInvite) (result model.StaffInvite, err error)
+	UseInvite(ctx context.Context, tx *sqlx.Tx, codeHash string, staffId uuid.UUID) (invite model.StaffInvite, err error)
+	UpdatePassword(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, hashPassword string) (staff model.Staff, err error)
+	CreatePasswordReset(ctx context.Context, tx *sqlx.Tx, reset model.PasswordReset) (result model.PasswordReset, err error)
+	UsePasswordReset(ctx context.Context, tx *sqlx.Tx, tokenHash string) (reset model.PasswordReset, err error)
+	UpdatePin(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, hashPin string) (err error)
+	SetTotpSecret(ctx context.Context, id uuid.UUID, secret string) (err error)
+	UpdateTotpSecret(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, secret string) (err error)
+	UseTotpCounter(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, counter int64) (ok bool, err error)
+	EnableTotp(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (err error)
+	DisableTotp(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (err error)
+	ReplaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, codeHashes []string) (err error)
+	UseRecoveryCode(ctx context.Context, id uuid.UUID, codeHash string) (err error)
 }
 
 type staffRepo struct {
@@ -19,6 +45,10 @@ func NewStaffRepo(db *sqlx.DB) StaffRepo {
 	return &staffRepo{db}
 }
 
+func (r *staffRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
 func (r *staffRepo) GetStaff(phoneNumber string) (*model.Staff, error) {
 	var staff model.Staff
 
@@ -32,12 +62,75 @@ func (r *staffRepo) GetStaff(phoneNumber string) (*model.Staff, error) {
 	return &staff, nil
 }
 
-func (r *staffRepo) CreateStaff(newStaff model.Staff, hashPassword string) error {
+var (
+	getStaffByIdQuery          = `SELECT * FROM "staff" WHERE "userId" = $1 LIMIT 1;`
+	getStaffByIdForUpdateQuery = `SELECT * FROM "staff" WHERE "userId" = $1 LIMIT 1 FOR UPDATE;`
+)
+
+func (r *staffRepo) GetStaffById(ctx context.Context, id uuid.UUID) (staff model.Staff, err error) {
+	err = r.db.GetContext(ctx, &staff, getStaffByIdQuery, id)
+	return staff, err
+}
+
+// GetStaffByIdForUpdate locks the staff row until tx ends
+func (r *staffRepo) GetStaffByIdForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (staff model.Staff, err error) {
+	err = tx.GetContext(ctx, &staff, getStaffByIdForUpdateQuery, id)
+	return staff, err
+}
+
+func (r *staffRepo) GetStaffList(ctx context.Context, params model.GetStaffParam) (staff []model.Staff, err error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if params.Role != "" {
+		args = append(args, params.Role)
+		conditions = append(conditions, `"role" = $`+strconv.Itoa(len(args)))
+	}
+
+	switch params.Status {
+	case "active":
+		conditions = append(conditions, `"deactivatedAt" IS NULL`)
+	case "inactive":
+		conditions = append(conditions, `"deactivatedAt" IS NOT NULL`)
+	}
+
+	query := `SELECT * FROM "staff"`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, params.Limit, params.Offset)
+	query += ` ORDER BY "createdAt" LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+
+	staff = []model.Staff{}
+	err = r.db.SelectContext(ctx, &staff, query, args...)
+	return staff, err
+}
+
+var (
+	lockStaffQuery  = `LOCK TABLE "staff" IN SHARE ROW EXCLUSIVE MODE;`
+	countStaffQuery = `SELECT COUNT(*) FROM "staff";`
+)
+
+// CountStaffLocked counts the staff while keeping other registrations out
+// until tx ends, so only one of them can become the bootstrap admin.
+func (r *staffRepo) CountStaffLocked(ctx context.Context, tx *sqlx.Tx) (count int, err error) {
+	if _, err = tx.ExecContext(ctx, lockStaffQuery); err != nil {
+		return 0, err
+	}
+
+	err = tx.GetContext(ctx, &count, countStaffQuery)
+	return count, err
+}
+
+func (r *staffRepo) CreateStaff(ctx context.Context, tx *sqlx.Tx, newStaff model.Staff, hashPassword string) error {
 	var userId string
 
-	query := `INSERT INTO staff ("userId", name, "phoneNumber", password, "createdAt") VALUES ($1, $2, $3, $4, NOW()) RETURNING "userId"`
+	query := `INSERT INTO staff ("userId", name, "phoneNumber", password, role, "createdAt") VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING "userId"`
 
-	row := r.db.QueryRowx(query, newStaff.UserId, newStaff.Name, newStaff.PhoneNumber, hashPassword)
+	row := tx.QueryRowxContext(ctx, query, newStaff.UserId, newStaff.Name, newStaff.PhoneNumber, hashPassword, newStaff.Role)
 
 	if err := row.Scan(&userId); err != nil {
 		return err
@@ -45,3 +138,179 @@ func (r *staffRepo) CreateStaff(newStaff model.Staff, hashPassword string) error
 
 	return nil
 }
+
+var (
+	updateStaffRoleQuery = `UPDATE "staff" SET "role" = $2 WHERE "userId" = $1 RETURNING *;`
+	deactivateStaffQuery = `UPDATE "staff" SET "deactivatedAt" = COALESCE("deactivatedAt", NOW()) WHERE "userId" = $1 RETURNING *;`
+	reactivateStaffQuery = `UPDATE "staff" SET "deactivatedAt" = NULL WHERE "userId" = $1 RETURNING *;`
+)
+
+func (r *staffRepo) UpdateStaffRole(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, role model.StaffRole) (staff model.Staff, err error) {
+	err = tx.QueryRowxContext(ctx, updateStaffRoleQuery, id, role).StructScan(&staff)
+	return staff, err
+}
+
+func (r *staffRepo) SetStaffDeactivated(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, deactivated bool) (staff model.Staff, err error) {
+	query := reactivateStaffQuery
+	if deactivated {
+		query = deactivateStaffQuery
+	}
+
+	err = tx.QueryRowxContext(ctx, query, id).StructScan(&staff)
+	return staff, err
+}
+
+var (
+	createStaffInviteQuery = `INSERT INTO "staff_invite" ("id", "codeHash", "role", "createdBy", "expiresAt", "createdAt")
+	VALUES ($1, $2, $3, $4, $5, NOW())
+	RETURNING *;`
+	useStaffInviteQuery = `UPDATE "staff_invite" SET "usedAt" = NOW(), "usedBy" = $2
+	WHERE "codeHash" = $1 AND "usedAt" IS NULL AND "expiresAt" > NOW()
+	RETURNING *;`
+)
+
+func (r *staffRepo) CreateInvite(ctx context.Context, tx *sqlx.Tx, invite model.StaffInvite) (result model.StaffInvite, err error) {
+	err = tx.QueryRowxContext(ctx, createStaffInviteQuery, uuid.New(), invite.CodeHash, invite.Role, invite.CreatedBy, invite.ExpiresAt).StructScan(&result)
+	return result, err
+}
+
+// UseInvite marks an unused, unexpired invite as taken by staffId,
+// sql.ErrNoRows is returned when there is no such invite.
+func (r *staffRepo) UseInvite(ctx context.Context, tx *sqlx.Tx, codeHash string, staffId uuid.UUID) (invite model.StaffInvite, err error) {
+	err = tx.QueryRowxContext(ctx, useStaffInviteQuery, codeHash, staffId).StructScan(&invite)
+	return invite, err
+}
+
+var (
+	updateStaffPasswordQuery = `UPDATE "staff" SET "password" = $2, "tokenVersion" = "tokenVersion" + 1
+	WHERE "userId" = $1
+	RETURNING *;`
+)
+
+// UpdatePassword also bumps the token version, which revokes every token
+// issued so far.
+func (r *staffRepo) UpdatePassword(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, hashPassword string) (staff model.Staff, err error) {
+	err = tx.QueryRowxContext(ctx, updateStaffPasswordQuery, id, hashPassword).StructScan(&staff)
+	return staff, err
+}
+
+var (
+	// a new reset token expires the ones issued before it
+	createPasswordResetQuery = `WITH "expired" AS (
+		UPDATE "staff_password_reset" SET "expiresAt" = NOW()
+		WHERE "staffId" = $2 AND "usedAt" IS NULL AND "expiresAt" > NOW()
+	)
+	INSERT INTO "staff_password_reset" ("id", "staffId", "tokenHash", "createdBy", "expiresAt", "createdAt")
+	VALUES ($1, $2, $3, $4, $5, NOW())
+	RETURNING *;`
+	usePasswordResetQuery = `UPDATE "staff_password_reset" SET "usedAt" = NOW()
+	WHERE "tokenHash" = $1 AND "usedAt" IS NULL AND "expiresAt" > NOW()
+	RETURNING *;`
+)
+
+func (r *staffRepo) CreatePasswordReset(ctx context.Context, tx *sqlx.Tx, reset model.PasswordReset) (result model.PasswordReset, err error) {
+	err = tx.QueryRowxContext(ctx, createPasswordResetQuery, uuid.New(), reset.StaffId, reset.TokenHash, reset.CreatedBy, reset.ExpiresAt).StructScan(&result)
+	return result, err
+}
+
+// UsePasswordReset marks an unused, unexpired reset token as used,
+// sql.ErrNoRows is returned when there is no such token.
+func (r *staffRepo) UsePasswordReset(ctx context.Context, tx *sqlx.Tx, tokenHash string) (reset model.PasswordReset, err error) {
+	err = tx.QueryRowxContext(ctx, usePasswordResetQuery, tokenHash).StructScan(&reset)
+	return reset, err
+}
+
+var (
+	updateStaffPinQuery = `UPDATE "staff" SET "pinHash" = $2 WHERE "userId" = $1;`
+)
+
+func (r *staffRepo) UpdatePin(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, hashPin string) (err error) {
+	_, err = tx.ExecContext(ctx, updateStaffPinQuery, id, hashPin)
+	return err
+}
+
+var (
+	setStaffTotpSecretQuery    = `UPDATE "staff" SET "totpSecret" = $2 WHERE "userId" = $1 AND "totpEnabledAt" IS NULL;`
+	updateStaffTotpSecretQuery = `UPDATE "staff" SET "totpSecret" = $2 WHERE "userId" = $1 AND "totpSecret" IS NOT NULL;`
+	useStaffTotpCounterQuery   = `UPDATE "staff" SET "totpLastCounter" = $2 WHERE "userId" = $1 AND "totpLastCounter" < $2;`
+	enableStaffTotpQuery       = `UPDATE "staff" SET "totpEnabledAt" = NOW() WHERE "userId" = $1;`
+	disableStaffTotpQuery      = `UPDATE "staff" SET "totpSecret" = NULL, "totpEnabledAt" = NULL WHERE "userId" = $1;`
+)
+
+// SetTotpSecret stores the secret of an enrolment that still has to be
+// confirmed, sql.ErrNoRows is returned when two-factor is already enabled.
+func (r *staffRepo) SetTotpSecret(ctx context.Context, id uuid.UUID, secret string) (err error) {
+	res, err := r.db.ExecContext(ctx, setStaffTotpSecretQuery, id, secret)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateTotpSecret rewrites the stored secret, e.g. to encrypt it
+func (r *staffRepo) UpdateTotpSecret(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, secret string) (err error) {
+	_, err = tx.ExecContext(ctx, updateStaffTotpSecretQuery, id, secret)
+	return err
+}
+
+// UseTotpCounter records the time step of an accepted code. It is false
+// when a code of that step or a later one was already used.
+func (r *staffRepo) UseTotpCounter(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, counter int64) (ok bool, err error) {
+	res, err := tx.ExecContext(ctx, useStaffTotpCounterQuery, id, counter)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *staffRepo) EnableTotp(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, enableStaffTotpQuery, id)
+	return err
+}
+
+func (r *staffRepo) DisableTotp(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (err error) {
+	if _, err = tx.ExecContext(ctx, disableStaffTotpQuery, id); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, deleteRecoveryCodesQuery, id)
+	return err
+}
+
+var (
+	deleteRecoveryCodesQuery = `DELETE FROM "staff_recovery_code" WHERE "staffId" = $1;`
+	createRecoveryCodesQuery = `INSERT INTO "staff_recovery_code" ("id", "staffId", "codeHash", "createdAt")
+	SELECT "id", $1, "codeHash", NOW() FROM UNNEST($2::uuid[], $3::varchar[]) AS c ("id", "codeHash");`
+	useRecoveryCodeQuery = `UPDATE "staff_recovery_code" SET "usedAt" = NOW()
+	WHERE "staffId" = $1 AND "codeHash" = $2 AND "usedAt" IS NULL
+	RETURNING "id";`
+)
+
+// ReplaceRecoveryCodes drops the old recovery codes of the staff
+func (r *staffRepo) ReplaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, codeHashes []string) (err error) {
+	if _, err = tx.ExecContext(ctx, deleteRecoveryCodesQuery, id); err != nil {
+		return err
+	}
+
+	ids := make([]string, len(codeHashes))
+	for i := range ids {
+		ids[i] = uuid.New().String()
+	}
+
+	_, err = tx.ExecContext(ctx, createRecoveryCodesQuery, id, pq.Array(ids), pq.Array(codeHashes))
+	return err
+}
+
+// UseRecoveryCode spends a recovery code, sql.ErrNoRows is returned when
+// the staff has no such unused code.
+func (r *staffRepo) UseRecoveryCode(ctx context.Context, id uuid.UUID, codeHash string) (err error) {
+	var codeId uuid.UUID
+	return r.db.GetContext(ctx, &codeId, useRecoveryCodeQuery, id, codeHash)
+}
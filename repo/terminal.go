@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+	"eniqilo-store/model"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TerminalRepo interface {
+	NewTx() (*sqlx.Tx, error)
+	CreateTerminal(ctx context.Context, tx *sqlx.Tx, terminal model.Terminal) (result model.Terminal, err error)
+	GetTerminals(ctx context.Context) (terminals []model.Terminal, err error)
+	GetTerminalById(ctx context.Context, id uuid.UUID) (terminal model.Terminal, err error)
+	GetActiveTerminalByDevice(ctx context.Context, deviceHash string) (terminal model.Terminal, err error)
+	TouchTerminal(ctx context.Context, id uuid.UUID) (err error)
+	RevokeTerminal(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (terminal model.Terminal, err error)
+}
+
+type terminalRepo struct {
+	db *sqlx.DB
+}
+
+func NewTerminalRepo(db *sqlx.DB) TerminalRepo {
+	return &terminalRepo{
+		db: db,
+	}
+}
+
+var (
+	createTerminalQuery = `INSERT INTO "terminal" ("id", "name", "deviceHash", "createdBy", "createdAt")
+	VALUES ($1, $2, $3, $4, NOW())
+	RETURNING *;`
+	getTerminalsQuery              = `SELECT * FROM "terminal" ORDER BY "createdAt";`
+	getTerminalByIdQuery           = `SELECT * FROM "terminal" WHERE "id" = $1 LIMIT 1;`
+	getActiveTerminalByDeviceQuery = `SELECT * FROM "terminal" WHERE "deviceHash" = $1 AND "revokedAt" IS NULL LIMIT 1;`
+	touchTerminalQuery             = `UPDATE "terminal" SET "lastUsedAt" = NOW() WHERE "id" = $1;`
+	revokeTerminalQuery            = `UPDATE "terminal" SET "revokedAt" = COALESCE("revokedAt", NOW()) WHERE "id" = $1 RETURNING *;`
+)
+
+func (r *terminalRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+func (r *terminalRepo) CreateTerminal(ctx context.Context, tx *sqlx.Tx, terminal model.Terminal) (result model.Terminal, err error) {
+	err = tx.QueryRowxContext(ctx, createTerminalQuery, uuid.New(), terminal.Name, terminal.DeviceHash, terminal.CreatedBy).StructScan(&result)
+	return result, err
+}
+
+func (r *terminalRepo) GetTerminals(ctx context.Context) (terminals []model.Terminal, err error) {
+	terminals = []model.Terminal{}
+	err = r.db.SelectContext(ctx, &terminals, getTerminalsQuery)
+	return terminals, err
+}
+
+func (r *terminalRepo) GetTerminalById(ctx context.Context, id uuid.UUID) (terminal model.Terminal, err error) {
+	err = r.db.GetContext(ctx, &terminal, getTerminalByIdQuery, id)
+	return terminal, err
+}
+
+func (r *terminalRepo) GetActiveTerminalByDevice(ctx context.Context, deviceHash string) (terminal model.Terminal, err error) {
+	err = r.db.GetContext(ctx, &terminal, getActiveTerminalByDeviceQuery, deviceHash)
+	return terminal, err
+}
+
+func (r *terminalRepo) TouchTerminal(ctx context.Context, id uuid.UUID) (err error) {
+	_, err = r.db.ExecContext(ctx, touchTerminalQuery, id)
+	return err
+}
+
+func (r *terminalRepo) RevokeTerminal(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (terminal model.Terminal, err error) {
+	err = tx.QueryRowxContext(ctx, revokeTerminalQuery, id).StructScan(&terminal)
+	return terminal, err
+}
@@ -0,0 +1,279 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type TimeClockRepo interface {
+	NewTx() (*sqlx.Tx, error)
+	ClockIn(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error)
+	GetOpenEntry(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error)
+	GetOpenEntryForUpdate(ctx context.Context, tx *sqlx.Tx, staffId uuid.UUID) (entry model.TimeEntry, err error)
+	ClockOut(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (entry model.TimeEntry, err error)
+	StartBreak(ctx context.Context, tx *sqlx.Tx, entryId uuid.UUID) (err error)
+	EndBreak(ctx context.Context, tx *sqlx.Tx, entryId uuid.UUID) (err error)
+	GetEntryForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (entry model.TimeEntry, err error)
+	CreateEntry(ctx context.Context, tx *sqlx.Tx, entry model.TimeEntry) (result model.TimeEntry, err error)
+	CorrectEntry(ctx context.Context, tx *sqlx.Tx, entry model.TimeEntry) (result model.TimeEntry, err error)
+	ReplaceBreaks(ctx context.Context, tx *sqlx.Tx, entryId uuid.UUID, breaks []model.TimeBreak) (err error)
+	HasOverlappingEntry(ctx context.Context, tx *sqlx.Tx, staffId uuid.UUID, excludeId uuid.UUID, from, to time.Time) (overlaps bool, err error)
+	GetBreaks(ctx context.Context, entryIds []uuid.UUID) (breaks map[uuid.UUID][]model.TimeBreak, err error)
+	GetEntries(ctx context.Context, params model.GetTimeEntryParam) (entries []model.TimeEntry, err error)
+	CountEntries(ctx context.Context, params model.GetTimeEntryParam) (total int, err error)
+	GetAttendance(ctx context.Context, params model.GetAttendanceParam) (attendance []model.StaffAttendance, err error)
+}
+
+type timeClockRepo struct {
+	db *sqlx.DB
+}
+
+func NewTimeClockRepo(db *sqlx.DB) TimeClockRepo {
+	return &timeClockRepo{
+		db: db,
+	}
+}
+
+func (r *timeClockRepo) NewTx() (*sqlx.Tx, error) {
+	return r.db.Beginx()
+}
+
+var (
+	clockInQuery = `INSERT INTO "time_entry" ("id", "staffId", "clockInAt", "createdAt")
+	VALUES ($1, $2, NOW(), NOW())
+	RETURNING *;`
+	getOpenTimeEntryQuery          = `SELECT * FROM "time_entry" WHERE "staffId" = $1 AND "clockOutAt" IS NULL LIMIT 1;`
+	getOpenTimeEntryForUpdateQuery = `SELECT * FROM "time_entry" WHERE "staffId" = $1 AND "clockOutAt" IS NULL LIMIT 1 FOR UPDATE;`
+	clockOutQuery                  = `UPDATE "time_entry" SET "clockOutAt" = NOW() WHERE "id" = $1 AND "clockOutAt" IS NULL
+	RETURNING *;`
+)
+
+// ClockIn fails on the unique index when the staff is already clocked in
+func (r *timeClockRepo) ClockIn(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error) {
+	err = r.db.QueryRowxContext(ctx, clockInQuery, uuid.New(), staffId).StructScan(&entry)
+	return entry, err
+}
+
+func (r *timeClockRepo) GetOpenEntry(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error) {
+	err = r.db.QueryRowxContext(ctx, getOpenTimeEntryQuery, staffId).StructScan(&entry)
+	return entry, err
+}
+
+func (r *timeClockRepo) GetOpenEntryForUpdate(ctx context.Context, tx *sqlx.Tx, staffId uuid.UUID) (entry model.TimeEntry, err error) {
+	err = tx.QueryRowxContext(ctx, getOpenTimeEntryForUpdateQuery, staffId).StructScan(&entry)
+	return entry, err
+}
+
+func (r *timeClockRepo) ClockOut(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (entry model.TimeEntry, err error) {
+	err = tx.QueryRowxContext(ctx, clockOutQuery, id).StructScan(&entry)
+	return entry, err
+}
+
+var (
+	startBreakQuery = `INSERT INTO "time_break" ("id", "entryId", "startedAt") VALUES ($1, $2, NOW());`
+	endBreakQuery   = `UPDATE "time_break" SET "endedAt" = NOW() WHERE "entryId" = $1 AND "endedAt" IS NULL;`
+)
+
+// StartBreak fails on the unique index when a break is already running
+func (r *timeClockRepo) StartBreak(ctx context.Context, tx *sqlx.Tx, entryId uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, startBreakQuery, uuid.New(), entryId)
+	return err
+}
+
+// EndBreak returns sql.ErrNoRows when no break is running
+func (r *timeClockRepo) EndBreak(ctx context.Context, tx *sqlx.Tx, entryId uuid.UUID) (err error) {
+	result, err := tx.ExecContext(ctx, endBreakQuery, entryId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+var (
+	getTimeEntryForUpdateQuery = `SELECT * FROM "time_entry" WHERE "id" = $1 LIMIT 1 FOR UPDATE;`
+	createTimeEntryQuery       = `INSERT INTO "time_entry" ("id", "staffId", "clockInAt", "clockOutAt", "correctedBy", "correctedAt", "correctionReason", "createdAt")
+	VALUES ($1, $2, $3, $4, $5, NOW(), $6, NOW())
+	RETURNING *;`
+	correctTimeEntryQuery = `UPDATE "time_entry" SET "clockInAt" = $2, "clockOutAt" = $3, "correctedBy" = $4, "correctedAt" = NOW(), "correctionReason" = $5
+	WHERE "id" = $1
+	RETURNING *;`
+	deleteTimeBreaksQuery = `DELETE FROM "time_break" WHERE "entryId" = $1;`
+	createTimeBreakQuery  = `INSERT INTO "time_break" ("id", "entryId", "startedAt", "endedAt") VALUES ($1, $2, $3, $4);`
+)
+
+func (r *timeClockRepo) GetEntryForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (entry model.TimeEntry, err error) {
+	err = tx.QueryRowxContext(ctx, getTimeEntryForUpdateQuery, id).StructScan(&entry)
+	return entry, err
+}
+
+// CreateEntry adds a shift on behalf of a staff member, it is marked as
+// corrected by entry.CorrectedBy.
+func (r *timeClockRepo) CreateEntry(ctx context.Context, tx *sqlx.Tx, entry model.TimeEntry) (result model.TimeEntry, err error) {
+	err = tx.QueryRowxContext(ctx, createTimeEntryQuery, uuid.New(), entry.StaffId, entry.ClockInAt, entry.ClockOutAt, entry.CorrectedBy, entry.CorrectionReason).StructScan(&result)
+	return result, err
+}
+
+func (r *timeClockRepo) CorrectEntry(ctx context.Context, tx *sqlx.Tx, entry model.TimeEntry) (result model.TimeEntry, err error) {
+	err = tx.QueryRowxContext(ctx, correctTimeEntryQuery, entry.ID, entry.ClockInAt, entry.ClockOutAt, entry.CorrectedBy, entry.CorrectionReason).StructScan(&result)
+	return result, err
+}
+
+func (r *timeClockRepo) ReplaceBreaks(ctx context.Context, tx *sqlx.Tx, entryId uuid.UUID, breaks []model.TimeBreak) (err error) {
+	if _, err = tx.ExecContext(ctx, deleteTimeBreaksQuery, entryId); err != nil {
+		return err
+	}
+
+	for _, b := range breaks {
+		if _, err = tx.ExecContext(ctx, createTimeBreakQuery, b.ID, entryId, b.StartedAt, b.EndedAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var (
+	hasOverlappingTimeEntryQuery = `SELECT EXISTS (
+		SELECT 1 FROM "time_entry"
+		WHERE "staffId" = $1 AND "id" <> $2 AND "clockInAt" < $4 AND COALESCE("clockOutAt", NOW()) > $3
+	);`
+)
+
+// HasOverlappingEntry tells whether another shift of the staff overlaps
+// from and to, a running shift lasts until now.
+func (r *timeClockRepo) HasOverlappingEntry(ctx context.Context, tx *sqlx.Tx, staffId uuid.UUID, excludeId uuid.UUID, from, to time.Time) (overlaps bool, err error) {
+	err = tx.QueryRowxContext(ctx, hasOverlappingTimeEntryQuery, staffId, excludeId, from, to).Scan(&overlaps)
+	return overlaps, err
+}
+
+var (
+	getTimeBreaksQuery = `SELECT * FROM "time_break" WHERE "entryId" = ANY ($1) ORDER BY "startedAt";`
+)
+
+// GetBreaks loads the breaks of the entries keyed by entry id
+func (r *timeClockRepo) GetBreaks(ctx context.Context, entryIds []uuid.UUID) (breaks map[uuid.UUID][]model.TimeBreak, err error) {
+	ids := make([]string, len(entryIds))
+	for i, id := range entryIds {
+		ids[i] = id.String()
+	}
+
+	rows, err := r.db.QueryxContext(ctx, getTimeBreaksQuery, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breaks = map[uuid.UUID][]model.TimeBreak{}
+	for rows.Next() {
+		var b model.TimeBreak
+		if err := rows.StructScan(&b); err != nil {
+			return nil, err
+		}
+		breaks[b.EntryId] = append(breaks[b.EntryId], b)
+	}
+
+	return breaks, rows.Err()
+}
+
+// timeEntryFilter builds the WHERE clause shared by the entry list and
+// its count, every value is passed as a query argument.
+func timeEntryFilter(params model.GetTimeEntryParam) (string, []interface{}) {
+	where := ` WHERE 1=1`
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if params.StaffId != nil {
+		where += ` AND "staffId" = ` + arg(*params.StaffId)
+	}
+	if params.From != nil {
+		where += ` AND "clockInAt" >= ` + arg(*params.From)
+	}
+	if params.To != nil {
+		where += ` AND "clockInAt" <= ` + arg(*params.To)
+	}
+
+	return where, args
+}
+
+func (r *timeClockRepo) GetEntries(ctx context.Context, params model.GetTimeEntryParam) (entries []model.TimeEntry, err error) {
+	where, args := timeEntryFilter(params)
+	query := `SELECT * FROM "time_entry"` + where +
+		fmt.Sprintf(` ORDER BY "clockInAt" DESC LIMIT %d OFFSET %d`, params.Limit, params.Offset)
+
+	entries = []model.TimeEntry{}
+	err = r.db.SelectContext(ctx, &entries, query, args...)
+	return entries, err
+}
+
+func (r *timeClockRepo) CountEntries(ctx context.Context, params model.GetTimeEntryParam) (total int, err error) {
+	where, args := timeEntryFilter(params)
+	err = r.db.QueryRowxContext(ctx, `SELECT COUNT(*) FROM "time_entry"`+where, args...).Scan(&total)
+	return total, err
+}
+
+var (
+	// sales are the non-voided transactions booked by the staff as cashier
+	// in the same period, so they can be set against the hours worked
+	getAttendanceQuery = `WITH "attendance" AS (
+		SELECT e."staffId",
+			COUNT(*) AS "shifts",
+			SUM(EXTRACT(EPOCH FROM (COALESCE(e."clockOutAt", NOW()) - e."clockInAt")) - b."seconds") AS "workedSeconds",
+			SUM(b."seconds") AS "breakSeconds",
+			MIN(e."clockInAt") AS "firstClockIn",
+			MAX(e."clockOutAt") AS "lastClockOut",
+			BOOL_OR(e."clockOutAt" IS NULL) AS "openShift",
+			COUNT(*) FILTER (WHERE e."correctedAt" IS NOT NULL) AS "correctedShifts"
+		FROM "time_entry" e
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM(EXTRACT(EPOCH FROM (COALESCE(tb."endedAt", NOW()) - tb."startedAt"))), 0) AS "seconds"
+			FROM "time_break" tb WHERE tb."entryId" = e."id"
+		) b
+		WHERE e."clockInAt" >= $1 AND e."clockInAt" <= $2
+		GROUP BY e."staffId"
+	), "sales" AS (
+		SELECT "staffId", COUNT(*) AS "transactions", COALESCE(SUM("total"), 0) AS "revenue"
+		FROM "transaction"
+		WHERE "staffId" IS NOT NULL AND "voidedAt" IS NULL AND "createdAt" >= $1 AND "createdAt" <= $2
+		GROUP BY "staffId"
+	)
+	SELECT s."userId" AS "staffId", s."name",
+		COALESCE(a."shifts", 0) AS "shifts",
+		COALESCE(FLOOR(a."workedSeconds" / 60), 0)::int AS "workedMinutes",
+		COALESCE(FLOOR(a."breakSeconds" / 60), 0)::int AS "breakMinutes",
+		a."firstClockIn", a."lastClockOut",
+		COALESCE(a."openShift", false) AS "openShift",
+		COALESCE(a."correctedShifts", 0) AS "correctedShifts",
+		COALESCE(t."transactions", 0) AS "transactions",
+		COALESCE(t."revenue", 0) AS "revenue"
+	FROM "staff" s
+	LEFT JOIN "attendance" a ON a."staffId" = s."userId"
+	LEFT JOIN "sales" t ON t."staffId" = s."userId"
+	WHERE (a."staffId" IS NOT NULL OR t."staffId" IS NOT NULL) AND ($3::uuid IS NULL OR s."userId" = $3)
+	ORDER BY s."name";`
+)
+
+// GetAttendance reports the staff that worked or sold within the period
+func (r *timeClockRepo) GetAttendance(ctx context.Context, params model.GetAttendanceParam) (attendance []model.StaffAttendance, err error) {
+	attendance = []model.StaffAttendance{}
+	err = r.db.SelectContext(ctx, &attendance, getAttendanceQuery, params.From, params.To, params.StaffId)
+	return attendance, err
+}
@@ -4,6 +4,8 @@ import (
 	"eniqilo-store/config"
 	"eniqilo-store/controller"
 	"eniqilo-store/middleware"
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/crypto"
 	"eniqilo-store/repo"
 	"eniqilo-store/service"
 	"github.com/go-playground/validator/v10"
@@ -12,13 +14,36 @@ import (
 	"go.uber.org/zap"
 )
 
-func (s *Server) RegisterRoute(cfg *config.Config) {
+func (s *Server) RegisterRoute(cfg *config.Config, keys *crypto.KeySet, secrets *crypto.SecretBox) {
+	s.app.GET("/.well-known/jwks.json", controller.NewJWKSController(keys).GetJWKS)
+
 	mainRoute := s.app.Group("/v1")
+	staffRepo, terminalRepo := repo.NewStaffRepo(s.db), repo.NewTerminalRepo(s.db)
+	auth := middleware.Authentication(keys, staffRepo, terminalRepo, cfg.Staff.TwoFactor)
+	// lets staff who still have to set up two-factor reach the enrolment
+	enrolAuth := middleware.Authentication(keys, staffRepo, terminalRepo, config.TwoFactorConfig{})
+	keyAuth := middleware.APIKeyOr(auth, repo.NewAPIKeyRepo(s.db))
+	// manager operations need a password login by a manager or an admin,
+	// admin operations one by an admin
+	manager := []echo.MiddlewareFunc{auth, middleware.PasswordLogin, middleware.RequireRole(model.ManagerRoles...)}
+	admin := []echo.MiddlewareFunc{auth, middleware.PasswordLogin, middleware.RequireRole(model.RoleAdmin)}
 
 	registerHealthRoute(mainRoute, s.db)
-	registerStaffRoute(mainRoute, s.db, cfg, s.validator)
-	registerCustomerRoute(mainRoute, s.db, cfg, s.validator, s.logger)
-	registerProductRoute(mainRoute, s.db, cfg)
+	registerStaffRoute(mainRoute, s.db, cfg, keys, secrets, s.validator, s.logger, auth, enrolAuth, admin)
+	registerCustomerRoute(mainRoute, s.db, cfg, s.validator, s.logger, auth, keyAuth, manager)
+	registerCustomerProfileRoute(mainRoute, s.db, s.validator, s.logger, auth, manager)
+	registerSegmentRoute(mainRoute, s.db, cfg, s.validator, s.logger, auth, manager)
+	registerLoyaltyRoute(mainRoute, s.db, s.logger, auth)
+	registerGiftCardRoute(mainRoute, s.db, s.validator, s.logger, auth, manager)
+	registerCartRoute(mainRoute, s.db, cfg, s.validator, s.logger, auth)
+	registerReceiptRoute(mainRoute, s.db, cfg, s.logger, keyAuth)
+	registerDrawerRoute(mainRoute, s.db, s.validator, s.logger, auth)
+	registerTerminalRoute(mainRoute, s.db, s.validator, s.logger, manager)
+	registerAPIKeyRoute(mainRoute, s.db, s.validator, s.logger, admin)
+	registerAuditRoute(mainRoute, s.db, s.logger, admin)
+	registerTimeClockRoute(mainRoute, s.db, s.validator, s.logger, auth, manager)
+	registerReportRoute(mainRoute, s.db, s.logger, manager)
+	registerProductRoute(mainRoute, s.db, s.logger, auth, keyAuth, manager)
 }
 
 func registerHealthRoute(e *echo.Group, db *sqlx.DB) {
@@ -28,26 +53,140 @@ func registerHealthRoute(e *echo.Group, db *sqlx.DB) {
 
 }
 
-func registerCustomerRoute(e *echo.Group, db *sqlx.DB, cfg *config.Config, validate *validator.Validate, logger *zap.Logger) {
-	ctr := controller.NewCheckoutController(service.NewCheckoutService(repo.NewCheckoutRepo(db), logger), validate)
-	e.POST("/customer/register", ctr.PostCustomer, middleware.Authentication(cfg.JWTSecret))
-	e.POST("/product/checkout", ctr.PostCheckout, middleware.Authentication(cfg.JWTSecret))
-	e.GET("/customer", ctr.GetCustomer, middleware.Authentication(cfg.JWTSecret))
-	e.GET("/product/checkout/history", ctr.GetHistoryTransaction, middleware.Authentication(cfg.JWTSecret))
+func registerCustomerRoute(e *echo.Group, db *sqlx.DB, cfg *config.Config, validate *validator.Validate, logger *zap.Logger, auth echo.MiddlewareFunc, keyAuth middleware.ScopedAuth, manager []echo.MiddlewareFunc) {
+	ctr := controller.NewCheckoutController(service.NewCheckoutService(cfg, repo.NewCheckoutRepo(db), repo.NewLoyaltyRepo(db), repo.NewGiftCardRepo(db), repo.NewReservationRepo(db), repo.NewCartRepo(db), repo.NewDrawerRepo(db), repo.NewAuditRepo(db), logger), validate)
+	e.POST("/customer/register", ctr.PostCustomer, auth)
+	e.POST("/product/checkout", ctr.PostCheckout, auth)
+	e.GET("/customer", ctr.GetCustomer, keyAuth(model.ScopeCustomersRead))
+	e.GET("/product/checkout/history", ctr.GetHistoryTransaction, keyAuth(model.ScopeTransactionsRead))
+	e.POST("/product/checkout/:transactionId/void", ctr.PostVoidTransaction, manager...)
+}
+
+func registerCustomerProfileRoute(e *echo.Group, db *sqlx.DB, validate *validator.Validate, logger *zap.Logger, auth echo.MiddlewareFunc, manager []echo.MiddlewareFunc) {
+	ctr := controller.NewCustomerController(service.NewCustomerService(repo.NewCustomerRepo(db), repo.NewCheckoutRepo(db), repo.NewAuditRepo(db), logger), validate)
+	e.GET("/customer/duplicates", ctr.GetDuplicateCandidates, auth)
+	e.POST("/customer/:id/merge", ctr.PostMergeCustomer, manager...)
+	e.GET("/customer/:id", ctr.GetCustomerById, auth)
+	e.PATCH("/customer/:id", ctr.PatchCustomer, auth)
+	e.DELETE("/customer/:id", ctr.DeleteCustomer, manager...)
+	e.GET("/customer/:id/summary", ctr.GetCustomerSummary, auth)
+	e.GET("/customer/:id/export", ctr.GetCustomerExport, manager...)
+	e.POST("/customer/:id/erase", ctr.PostEraseCustomer, manager...)
+}
+
+func registerSegmentRoute(e *echo.Group, db *sqlx.DB, cfg *config.Config, validate *validator.Validate, logger *zap.Logger, auth echo.MiddlewareFunc, manager []echo.MiddlewareFunc) {
+	ctr := controller.NewSegmentController(service.NewSegmentService(cfg, repo.NewSegmentRepo(db), logger), validate)
+	e.POST("/customer-segment", ctr.PostSegment, manager...)
+	e.GET("/customer-segment", ctr.GetSegments, auth)
+	e.POST("/customer-segment/refresh", ctr.PostRefresh, manager...)
+	e.GET("/customer-segment/:id", ctr.GetSegment, auth)
+	e.DELETE("/customer-segment/:id", ctr.DeleteSegment, manager...)
+}
+
+func registerLoyaltyRoute(e *echo.Group, db *sqlx.DB, logger *zap.Logger, auth echo.MiddlewareFunc) {
+	ctr := controller.NewLoyaltyController(service.NewLoyaltyService(repo.NewLoyaltyRepo(db), repo.NewCheckoutRepo(db), logger))
+	e.GET("/customer/:id/points", ctr.GetCustomerPoints, auth)
+}
+
+func registerGiftCardRoute(e *echo.Group, db *sqlx.DB, validate *validator.Validate, logger *zap.Logger, auth echo.MiddlewareFunc, manager []echo.MiddlewareFunc) {
+	ctr := controller.NewGiftCardController(service.NewGiftCardService(repo.NewGiftCardRepo(db), logger), validate)
+	e.POST("/gift-card", ctr.PostGiftCard, manager...)
+	e.GET("/gift-card/:code", ctr.GetGiftCard, auth)
+}
+
+func registerCartRoute(e *echo.Group, db *sqlx.DB, cfg *config.Config, validate *validator.Validate, logger *zap.Logger, auth echo.MiddlewareFunc) {
+	checkoutSvc := service.NewCheckoutService(cfg, repo.NewCheckoutRepo(db), repo.NewLoyaltyRepo(db), repo.NewGiftCardRepo(db), repo.NewReservationRepo(db), repo.NewCartRepo(db), repo.NewDrawerRepo(db), repo.NewAuditRepo(db), logger)
+	reservationSvc := service.NewReservationService(cfg, repo.NewReservationRepo(db), logger)
+	ctr := controller.NewCartController(service.NewCartService(repo.NewCartRepo(db), checkoutSvc, reservationSvc, logger), validate)
+	e.POST("/cart", ctr.PostCart, auth)
+	e.GET("/cart", ctr.GetCarts, auth)
+	e.GET("/cart/:id", ctr.GetCart, auth)
+	e.POST("/cart/:id/items", ctr.PostCartItem, auth)
+	e.DELETE("/cart/:id/items/:productId", ctr.DeleteCartItem, auth)
+	e.PUT("/cart/:id/customer", ctr.PutCartCustomer, auth)
+	e.POST("/cart/:id/hold", ctr.HoldCart, auth)
+	e.POST("/cart/:id/resume", ctr.ResumeCart, auth)
+	e.POST("/cart/:id/checkout", ctr.CheckoutCart, auth)
+}
+
+func registerReceiptRoute(e *echo.Group, db *sqlx.DB, cfg *config.Config, logger *zap.Logger, keyAuth middleware.ScopedAuth) {
+	ctr := controller.NewReceiptController(service.NewReceiptService(cfg, repo.NewCheckoutRepo(db), logger))
+	e.GET("/product/checkout/:transactionId/receipt", ctr.GetReceipt, keyAuth(model.ScopeTransactionsRead))
+}
+
+func registerDrawerRoute(e *echo.Group, db *sqlx.DB, validate *validator.Validate, logger *zap.Logger, auth echo.MiddlewareFunc) {
+	ctr := controller.NewDrawerController(service.NewDrawerService(repo.NewDrawerRepo(db), logger), validate)
+	e.POST("/drawer", ctr.OpenSession, auth)
+	e.GET("/drawer/current", ctr.GetCurrentSession, auth)
+	e.POST("/drawer/:id/movements", ctr.PostMovement, auth, middleware.PasswordLogin)
+	e.GET("/drawer/:id/x-report", ctr.GetXReport, auth)
+	e.POST("/drawer/:id/close", ctr.CloseSession, auth, middleware.PasswordLogin)
+	e.GET("/drawer/:id/z-report", ctr.GetZReport, auth)
+}
+
+func registerAPIKeyRoute(e *echo.Group, db *sqlx.DB, validate *validator.Validate, logger *zap.Logger, admin []echo.MiddlewareFunc) {
+	ctr := controller.NewAPIKeyController(service.NewAPIKeyService(repo.NewAPIKeyRepo(db), repo.NewAuditRepo(db), logger), validate)
+	e.POST("/api-key", ctr.PostAPIKey, admin...)
+	e.GET("/api-key", ctr.GetAPIKeys, admin...)
+	e.DELETE("/api-key/:id", ctr.DeleteAPIKey, admin...)
+}
+
+func registerAuditRoute(e *echo.Group, db *sqlx.DB, logger *zap.Logger, admin []echo.MiddlewareFunc) {
+	ctr := controller.NewAuditController(service.NewAuditService(repo.NewAuditRepo(db), logger))
+	e.GET("/audit", ctr.GetAudit, admin...)
+}
+
+func registerTimeClockRoute(e *echo.Group, db *sqlx.DB, validate *validator.Validate, logger *zap.Logger, auth echo.MiddlewareFunc, manager []echo.MiddlewareFunc) {
+	ctr := controller.NewTimeClockController(service.NewTimeClockService(repo.NewTimeClockRepo(db), repo.NewStaffRepo(db), repo.NewAuditRepo(db), logger), validate)
+	e.POST("/time-clock/clock-in", ctr.ClockIn, auth)
+	e.POST("/time-clock/clock-out", ctr.ClockOut, auth)
+	e.POST("/time-clock/break/start", ctr.StartBreak, auth)
+	e.POST("/time-clock/break/end", ctr.EndBreak, auth)
+	e.GET("/time-clock", ctr.GetCurrentEntry, auth)
+	e.GET("/time-clock/entries", ctr.GetEntries, manager...)
+	e.POST("/time-clock/entries", ctr.PostEntry, manager...)
+	e.PATCH("/time-clock/entries/:id", ctr.PatchEntry, manager...)
+	e.GET("/reports/attendance", ctr.GetAttendance, manager...)
 }
 
-func registerStaffRoute(e *echo.Group, db *sqlx.DB, cfg *config.Config, validate *validator.Validate) {
-	ctr := controller.NewStaffController(service.NewStaffService(cfg, repo.NewStaffRepo(db)), validate)
+func registerReportRoute(e *echo.Group, db *sqlx.DB, logger *zap.Logger, manager []echo.MiddlewareFunc) {
+	ctr := controller.NewReportController(service.NewReportService(repo.NewReportRepo(db), logger))
+	e.GET("/reports/staff-sales", ctr.GetStaffSales, manager...)
+}
+
+func registerTerminalRoute(e *echo.Group, db *sqlx.DB, validate *validator.Validate, logger *zap.Logger, manager []echo.MiddlewareFunc) {
+	ctr := controller.NewTerminalController(service.NewTerminalService(repo.NewTerminalRepo(db), repo.NewAuditRepo(db), logger), validate)
+	e.POST("/terminal", ctr.PostTerminal, manager...)
+	e.GET("/terminal", ctr.GetTerminals, manager...)
+	e.DELETE("/terminal/:id", ctr.DeleteTerminal, manager...)
+}
 
+func registerStaffRoute(e *echo.Group, db *sqlx.DB, cfg *config.Config, keys *crypto.KeySet, secrets *crypto.SecretBox, validate *validator.Validate, logger *zap.Logger, auth, enrolAuth echo.MiddlewareFunc, admin []echo.MiddlewareFunc) {
+	ctr := controller.NewStaffController(service.NewStaffService(cfg, keys, secrets, repo.NewStaffRepo(db), repo.NewLoginAttemptRepo(db), repo.NewTerminalRepo(db), repo.NewAuditRepo(db), logger), validate)
 	e.POST("/staff/login", ctr.Login)
+	e.POST("/staff/login/2fa", ctr.LoginTwoFactor)
+	e.POST("/staff/2fa/enrol", ctr.PostEnrolTwoFactor, enrolAuth, middleware.PasswordLogin)
+	e.POST("/staff/2fa/confirm", ctr.PostConfirmTwoFactor, enrolAuth, middleware.PasswordLogin)
+	e.POST("/staff/2fa/disable", ctr.PostDisableTwoFactor, auth, middleware.PasswordLogin)
+	e.POST("/staff/pin-login", ctr.PinLogin)
+	e.POST("/staff/pin", ctr.PostPin, auth, middleware.PasswordLogin)
 	e.POST("/staff/register", ctr.Register)
+	e.POST("/staff/password", ctr.PostChangePassword, auth, middleware.PasswordLogin)
+	e.POST("/staff/password/reset", ctr.PostResetPassword)
+	e.POST("/staff/invite", ctr.PostInvite, admin...)
+	e.GET("/staff", ctr.GetStaffList, admin...)
+	e.GET("/staff/:id", ctr.GetStaff, admin...)
+	e.PATCH("/staff/:id/role", ctr.PatchStaffRole, admin...)
+	e.POST("/staff/:id/deactivate", ctr.DeactivateStaff, admin...)
+	e.POST("/staff/:id/reactivate", ctr.ReactivateStaff, admin...)
+	e.POST("/staff/:id/password-reset", ctr.PostPasswordReset, admin...)
 }
 
-func registerProductRoute(e *echo.Group, db *sqlx.DB, cfg *config.Config) {
-	ctr := controller.NewProductController(service.NewProductService(repo.NewProductRepo(db)))
-	e.POST("/product", ctr.PostProduct, middleware.Authentication(cfg.JWTSecret))
-	e.PUT("/product/:id", ctr.UpdateProduct, middleware.Authentication(cfg.JWTSecret))
-	e.DELETE("/product/:id", ctr.DeleteProduct, middleware.Authentication(cfg.JWTSecret))
-	e.GET("/product", ctr.GetProduct, middleware.Authentication(cfg.JWTSecret))
+func registerProductRoute(e *echo.Group, db *sqlx.DB, logger *zap.Logger, auth echo.MiddlewareFunc, keyAuth middleware.ScopedAuth, manager []echo.MiddlewareFunc) {
+	ctr := controller.NewProductController(service.NewProductService(repo.NewProductRepo(db), repo.NewAuditRepo(db), logger))
+	e.POST("/product", ctr.PostProduct, keyAuth(model.ScopeProductsWrite), middleware.PasswordLogin)
+	e.PUT("/product/:id", ctr.UpdateProduct, keyAuth(model.ScopeProductsWrite), middleware.PasswordLogin)
+	e.DELETE("/product/:id", ctr.DeleteProduct, manager...)
+	e.GET("/product", ctr.GetProduct, keyAuth(model.ScopeProductsRead))
 	e.GET("/product/customer", ctr.GetProductCustomer)
 }
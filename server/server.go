@@ -1,7 +1,14 @@
 package server
 
 import (
+	"context"
+	"eniqilo-store/config"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	"eniqilo-store/service"
+
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -20,6 +27,20 @@ func NewServer(db *sqlx.DB, logger *zap.Logger) *Server {
 	validate := validator.New()
 
 	app.Use(middleware.Recover())
+	// the request id is sent back in X-Request-Id and stored with the audit
+	// entries of the request. It is always generated here, an id sent by
+	// the client could be used to forge the audit trail.
+	app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			requestId := uuid.NewString()
+			c.Response().Header().Set(echo.HeaderXRequestID, requestId)
+			c.SetRequest(c.Request().WithContext(model.ContextWithRequestId(c.Request().Context(), requestId)))
+			return next(c)
+		}
+	})
+	// only trust X-Forwarded-For set by proxies on private networks, the
+	// client ip is used to rate limit logins
+	app.IPExtractor = echo.ExtractIPFromXFFHeader()
 
 	return &Server{
 		db:        db,
@@ -29,6 +50,15 @@ func NewServer(db *sqlx.DB, logger *zap.Logger) *Server {
 	}
 }
 
+// StartWorkers runs the background jobs until ctx is cancelled
+func (s *Server) StartWorkers(ctx context.Context, cfg *config.Config) {
+	reservationSvc := service.NewReservationService(cfg, repo.NewReservationRepo(s.db), s.logger)
+	go reservationSvc.RunSweeper(ctx)
+
+	segmentSvc := service.NewSegmentService(cfg, repo.NewSegmentRepo(s.db), s.logger)
+	go segmentSvc.RunScheduler(ctx)
+}
+
 func (s *Server) Run() error {
 	return s.app.Start(":8080")
 }
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/crypto"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, staffId uuid.UUID, data model.CreateAPIKeyRequest) (key model.APIKey, err error)
+	GetAPIKeys(ctx context.Context) (keys []model.APIKey, err error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) (key model.APIKey, err error)
+}
+
+type apiKeyService struct {
+	repo      repo.APIKeyRepo
+	auditRepo repo.AuditRepo
+	logger    *zap.Logger
+}
+
+func NewAPIKeyService(r repo.APIKeyRepo, auditRepo repo.AuditRepo, logger *zap.Logger) APIKeyService {
+	return &apiKeyService{
+		repo:      r,
+		auditRepo: auditRepo,
+		logger:    logger,
+	}
+}
+
+// CreateAPIKey returns the key in full this one time, afterwards only its
+// prefix can be seen.
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, staffId uuid.UUID, data model.CreateAPIKeyRequest) (key model.APIKey, err error) {
+	if data.ExpiresAt != nil && data.ExpiresAt.Before(time.Now()) {
+		return model.APIKey{}, cerr.New(http.StatusBadRequest, "expiresAt must be in the future")
+	}
+
+	token, err := crypto.GenerateRandomToken(24)
+	if err != nil {
+		s.logger.Error("failed generate api key", zap.Error(err))
+		return model.APIKey{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	secret := model.APIKeyPrefix + token
+
+	scopes := make([]model.APIKeyScope, len(data.Scopes))
+	for i, scope := range data.Scopes {
+		scopes[i] = model.APIKeyScope(scope)
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.APIKey{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	key, err = s.repo.CreateAPIKey(ctx, tx, model.APIKey{
+		Name:      *data.Name,
+		Prefix:    secret[:len(model.APIKeyPrefix)+6],
+		KeyHash:   crypto.HashToken(secret),
+		Scopes:    scopes,
+		CreatedBy: staffId,
+		ExpiresAt: data.ExpiresAt,
+	})
+	if err != nil {
+		s.logger.Error("failed create api key", zap.Error(err))
+		return model.APIKey{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditAPIKeyCreate, model.AuditAPIKey, key.ID.String(), nil, key))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.APIKey{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	key.Key = secret
+
+	return key, nil
+}
+
+func (s *apiKeyService) GetAPIKeys(ctx context.Context) (keys []model.APIKey, err error) {
+	keys, err = s.repo.GetAPIKeys(ctx)
+	if err != nil {
+		s.logger.Error("failed get api keys", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return keys, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id uuid.UUID) (key model.APIKey, err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.APIKey{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	key, err = s.repo.RevokeAPIKey(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.APIKey{}, cerr.New(http.StatusNotFound, "api key is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed revoke api key", zap.Error(err))
+		return model.APIKey{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditAPIKeyRevoke, model.AuditAPIKey, key.ID.String(), nil, key))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.APIKey{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type AuditService interface {
+	GetEntries(ctx context.Context, params model.GetAuditParam) (entries []model.AuditEntry, meta model.PageMeta, err error)
+}
+
+type auditService struct {
+	repo   repo.AuditRepo
+	logger *zap.Logger
+}
+
+func NewAuditService(r repo.AuditRepo, logger *zap.Logger) AuditService {
+	return &auditService{
+		repo:   r,
+		logger: logger,
+	}
+}
+
+func (s *auditService) GetEntries(ctx context.Context, params model.GetAuditParam) (entries []model.AuditEntry, meta model.PageMeta, err error) {
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+	if params.Offset < 0 {
+		params.Offset = 0
+	}
+
+	entries, err = s.repo.GetEntries(ctx, params)
+	if err != nil {
+		s.logger.Error("failed get audit entries", zap.Error(err))
+		return nil, model.PageMeta{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	total, err := s.repo.CountEntries(ctx, params)
+	if err != nil {
+		s.logger.Error("failed count audit entries", zap.Error(err))
+		return nil, model.PageMeta{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return entries, model.PageMeta{
+		Total:  total,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	}, nil
+}
+
+// newAuditEntry describes a change made by the actor of ctx. before and
+// after are stored as JSON, so fields hidden from the responses are left
+// out of the log as well.
+func newAuditEntry(ctx context.Context, action model.AuditAction, entityType model.AuditEntityType, entityId string, before, after interface{}) model.AuditEntry {
+	actor := model.AuditActorFromContext(ctx)
+	entry := model.AuditEntry{
+		ActorType:  actor.Type,
+		ActorName:  actor.Name,
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+		RequestId:  model.RequestIdFromContext(ctx),
+	}
+	if actor.Id != "" {
+		entry.ActorId = &actor.Id
+	}
+
+	return entry
+}
+
+func auditJSON(value interface{}) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type CartService interface {
+	CreateCart(ctx context.Context, staffId uuid.UUID, data model.CreateCartRequest) (cart model.CartView, err error)
+	GetCart(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole) (cart model.CartView, err error)
+	GetCarts(ctx context.Context, status model.CartStatus, staffId uuid.UUID, role model.StaffRole) (carts []model.Cart, err error)
+	AddItem(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole, item model.CartItemRequest) (cart model.CartView, err error)
+	RemoveItem(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole, productId string) (cart model.CartView, err error)
+	AttachCustomer(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole, customerId string) (cart model.CartView, err error)
+	Hold(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole) (cart model.CartView, err error)
+	Resume(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole) (cart model.CartView, err error)
+	Checkout(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole, data model.CartCheckoutRequest) (transaction model.Transaction, err error)
+}
+
+type cartService struct {
+	repo           repo.CartRepo
+	checkoutSvc    CheckoutService
+	reservationSvc ReservationService
+	logger         *zap.Logger
+}
+
+func NewCartService(r repo.CartRepo, checkoutSvc CheckoutService, reservationSvc ReservationService, logger *zap.Logger) CartService {
+	return &cartService{
+		repo:           r,
+		checkoutSvc:    checkoutSvc,
+		reservationSvc: reservationSvc,
+		logger:         logger,
+	}
+}
+
+func (s *cartService) CreateCart(ctx context.Context, staffId uuid.UUID, data model.CreateCartRequest) (cart model.CartView, err error) {
+	newCart := model.Cart{StaffId: staffId}
+	if data.CustomerId != nil {
+		if _, err := s.checkoutSvc.ValidateUser(ctx, *data.CustomerId); err != nil {
+			return model.CartView{}, err
+		}
+		customerId := uuid.MustParse(*data.CustomerId)
+		newCart.CustomerId = &customerId
+	}
+
+	created, err := s.repo.CreateCart(ctx, newCart)
+	if err != nil {
+		s.logger.Error("failed create cart", zap.Error(err))
+		return model.CartView{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return s.priceCart(ctx, created), nil
+}
+
+func (s *cartService) GetCart(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole) (cart model.CartView, err error) {
+	found, err := s.getOwnCart(ctx, id, staffId, role)
+	if err != nil {
+		return model.CartView{}, err
+	}
+
+	return s.priceCart(ctx, found), nil
+}
+
+// GetCarts lists the carts of the staff member, managers see every cart.
+func (s *cartService) GetCarts(ctx context.Context, status model.CartStatus, staffId uuid.UUID, role model.StaffRole) (carts []model.Cart, err error) {
+	owner := &staffId
+	if role.IsManager() {
+		owner = nil
+	}
+
+	carts, err = s.repo.GetCarts(ctx, status, owner)
+	if err != nil {
+		s.logger.Error("failed get carts", zap.Error(err))
+		return []model.Cart{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return carts, nil
+}
+
+func (s *cartService) AddItem(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole, item model.CartItemRequest) (cart model.CartView, err error) {
+	found, err := s.getOwnCart(ctx, id, staffId, role)
+	if err != nil {
+		return model.CartView{}, err
+	}
+
+	line := model.ProductDetail{ProductId: item.ProductId, Quantity: item.Quantity}
+	lines := make([]model.ProductDetail, 0, len(found.ProductDetails)+1)
+	for _, existing := range found.ProductDetails {
+		if existing.ProductId == item.ProductId {
+			line.Quantity += existing.Quantity
+			continue
+		}
+		lines = append(lines, existing)
+	}
+
+	// the merged line has to be sellable right now
+	if _, err := s.checkoutSvc.ValidateProduct(ctx, []model.ProductDetail{line}, &id); err != nil {
+		return model.CartView{}, err
+	}
+	lines = append(lines, line)
+
+	updated, err := s.repo.UpdateCartLines(ctx, id, lines)
+	if err != nil {
+		return model.CartView{}, s.cartError(err)
+	}
+
+	return s.priceCart(ctx, updated), nil
+}
+
+func (s *cartService) RemoveItem(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole, productId string) (cart model.CartView, err error) {
+	found, err := s.getOwnCart(ctx, id, staffId, role)
+	if err != nil {
+		return model.CartView{}, err
+	}
+
+	lines := make([]model.ProductDetail, 0, len(found.ProductDetails))
+	for _, existing := range found.ProductDetails {
+		if existing.ProductId != productId {
+			lines = append(lines, existing)
+		}
+	}
+	if len(lines) == len(found.ProductDetails) {
+		return model.CartView{}, cerr.New(http.StatusNotFound, "productId is not in the cart")
+	}
+
+	updated, err := s.repo.UpdateCartLines(ctx, id, lines)
+	if err != nil {
+		return model.CartView{}, s.cartError(err)
+	}
+
+	return s.priceCart(ctx, updated), nil
+}
+
+func (s *cartService) AttachCustomer(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole, customerId string) (cart model.CartView, err error) {
+	if _, err := s.getOwnCart(ctx, id, staffId, role); err != nil {
+		return model.CartView{}, err
+	}
+
+	if _, err := s.checkoutSvc.ValidateUser(ctx, customerId); err != nil {
+		return model.CartView{}, err
+	}
+
+	updated, err := s.repo.UpdateCartCustomer(ctx, id, uuid.MustParse(customerId))
+	if err != nil {
+		return model.CartView{}, s.cartError(err)
+	}
+
+	return s.priceCart(ctx, updated), nil
+}
+
+// Hold parks the cart and reserves its lines so the goods aren't sold to
+// someone else while the customer is away.
+func (s *cartService) Hold(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole) (cart model.CartView, err error) {
+	found, err := s.getOwnCart(ctx, id, staffId, role)
+	if err != nil {
+		return model.CartView{}, err
+	}
+
+	err = s.reservationSvc.Reserve(ctx, id, found.ProductDetails)
+	if err != nil {
+		return model.CartView{}, err
+	}
+
+	updated, err := s.repo.UpdateCartStatus(ctx, id, []model.CartStatus{model.CartOpen}, model.CartHeld)
+	if err != nil {
+		_ = s.reservationSvc.Release(ctx, id)
+		return model.CartView{}, s.cartError(err)
+	}
+
+	return s.priceCart(ctx, updated), nil
+}
+
+func (s *cartService) Resume(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole) (cart model.CartView, err error) {
+	if _, err := s.getOwnCart(ctx, id, staffId, role); err != nil {
+		return model.CartView{}, err
+	}
+
+	updated, err := s.repo.UpdateCartStatus(ctx, id, []model.CartStatus{model.CartHeld}, model.CartOpen)
+	if err != nil {
+		return model.CartView{}, s.cartError(err)
+	}
+
+	err = s.reservationSvc.Release(ctx, id)
+	if err != nil {
+		return model.CartView{}, err
+	}
+
+	return s.priceCart(ctx, updated), nil
+}
+
+// Checkout turns the cart into a transaction through the regular checkout
+// path. The cart is claimed first so it can't be checked out twice, and is
+// completed in the checkout transaction together with the sale.
+func (s *cartService) Checkout(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole, data model.CartCheckoutRequest) (transaction model.Transaction, err error) {
+	current, err := s.getOwnCart(ctx, id, staffId, role)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	if current.Status != model.CartOpen && current.Status != model.CartHeld {
+		return model.Transaction{}, cerr.New(http.StatusConflict, "cart can't be checked out in its current status")
+	}
+
+	found, err := s.repo.UpdateCartStatus(ctx, id, []model.CartStatus{current.Status}, model.CartCheckingOut)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Transaction{}, cerr.New(http.StatusConflict, "cart can't be checked out in its current status")
+		}
+		return model.Transaction{}, s.cartError(err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		// give the cart back in the state it was claimed from
+		if _, releaseErr := s.repo.UpdateCartStatus(context.Background(), id, []model.CartStatus{model.CartCheckingOut}, current.Status); releaseErr != nil {
+			s.logger.Error("failed release cart", zap.Error(releaseErr))
+		}
+	}()
+
+	if found.CustomerId == nil {
+		return model.Transaction{}, cerr.New(http.StatusBadRequest, "customerId is required")
+	}
+	if len(found.ProductDetails) == 0 && len(data.GiftCards) == 0 {
+		return model.Transaction{}, cerr.New(http.StatusBadRequest, "cart is empty")
+	}
+
+	customerId := found.CustomerId.String()
+	if _, err = s.checkoutSvc.ValidateUser(ctx, customerId); err != nil {
+		return model.Transaction{}, err
+	}
+
+	totalPrice, err := s.checkoutSvc.ValidateProduct(ctx, found.ProductDetails, &id)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	prepared, err := s.checkoutSvc.PrepareTransaction(model.OrderRequest{
+		CustomerId:     &customerId,
+		ProductDetails: found.ProductDetails,
+		GiftCards:      data.GiftCards,
+		Payments:       data.Payments,
+		Paid:           data.Paid,
+		Change:         data.Change,
+	}, totalPrice)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	transaction, err = s.checkoutSvc.CheckoutProduct(ctx, staffId, &id, prepared)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	return transaction, nil
+}
+
+// priceCart prices every line with ValidateProduct so the cart always
+// reflects current prices, stock and availability.
+func (s *cartService) priceCart(ctx context.Context, cart model.Cart) model.CartView {
+	view := model.CartView{
+		Cart:  cart,
+		Lines: make([]model.CartLine, 0, len(cart.ProductDetails)),
+	}
+
+	for _, product := range cart.ProductDetails {
+		line := model.CartLine{
+			ProductId: product.ProductId,
+			Quantity:  product.Quantity,
+		}
+
+		lineTotal, err := s.checkoutSvc.ValidateProduct(ctx, []model.ProductDetail{product}, &cart.ID)
+		if err != nil {
+			line.Error = err.Error()
+		} else {
+			line.LineTotal = int(lineTotal)
+			view.Total += line.LineTotal
+		}
+
+		view.Lines = append(view.Lines, line)
+	}
+
+	return view
+}
+
+// getOwnCart returns the cart if it was opened by the staff member, managers
+// can act on any cart.
+func (s *cartService) getOwnCart(ctx context.Context, id uuid.UUID, staffId uuid.UUID, role model.StaffRole) (model.Cart, error) {
+	cart, err := s.repo.GetCartById(ctx, id)
+	if err != nil {
+		return model.Cart{}, s.cartError(err)
+	}
+	if cart.StaffId != staffId && !role.IsManager() {
+		return model.Cart{}, cerr.New(http.StatusForbidden, "cart belongs to another staff member")
+	}
+
+	return cart, nil
+}
+
+func (s *cartService) cartError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return cerr.New(http.StatusNotFound, "cart is not found or can't be changed in its current status")
+	}
+
+	s.logger.Error("failed update cart", zap.Error(err))
+	return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+}
@@ -3,33 +3,55 @@ package service
 import (
 	"context"
 	"database/sql"
+	"eniqilo-store/config"
 	"eniqilo-store/model"
+	"eniqilo-store/pkg/phone"
 	"eniqilo-store/repo"
 	cerr "eniqilo-store/utils/error"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
 type CheckoutService interface {
 	CreateNewCustomer(ctx context.Context, data model.CustomerRequest) (customer model.Customer, err error)
 	ValidateUser(ctx context.Context, userId string) (customer model.Customer, err error)
-	ValidateProduct(ctx context.Context, products []model.ProductDetail) (total float32, err error)
-	CheckoutProduct(ctx context.Context, transaction model.Transaction) (err error)
-	GetAllCustomer(ctx context.Context, name, phoneNumber string, limit, offset int) (listCustomer []model.CustomerResponseData, err error)
-	GetAllTransaction(ctx context.Context, params model.GetHistoryParam) (listTransaction []model.Transaction, err error)
+	ValidateProduct(ctx context.Context, products []model.ProductDetail, holderId *uuid.UUID) (total float32, err error)
+	PrepareTransaction(order model.OrderRequest, productTotal float32) (transaction model.Transaction, err error)
+	CheckoutProduct(ctx context.Context, staffId uuid.UUID, holderId *uuid.UUID, transaction model.Transaction) (result model.Transaction, err error)
+	VoidTransaction(ctx context.Context, transactionId uuid.UUID, managerId uuid.UUID, reason string) (result model.Transaction, err error)
+	GetAllCustomer(ctx context.Context, params model.GetCustomerParam) (listCustomer []model.CustomerResponseData, err error)
+	GetAllTransaction(ctx context.Context, params model.GetHistoryParam) (listTransaction []model.Transaction, meta model.PageMeta, err error)
 }
 
 type checkoutService struct {
-	repo   repo.CheckoutRepo
-	logger *zap.Logger
+	cfg             *config.Config
+	repo            repo.CheckoutRepo
+	loyaltyRepo     repo.LoyaltyRepo
+	giftCardRepo    repo.GiftCardRepo
+	reservationRepo repo.ReservationRepo
+	cartRepo        repo.CartRepo
+	drawerRepo      repo.DrawerRepo
+	auditRepo       repo.AuditRepo
+	logger          *zap.Logger
 }
 
-func NewCheckoutService(r repo.CheckoutRepo, logger *zap.Logger) CheckoutService {
+func NewCheckoutService(cfg *config.Config, r repo.CheckoutRepo, loyaltyRepo repo.LoyaltyRepo, giftCardRepo repo.GiftCardRepo, reservationRepo repo.ReservationRepo, cartRepo repo.CartRepo, drawerRepo repo.DrawerRepo, auditRepo repo.AuditRepo, logger *zap.Logger) CheckoutService {
 	return &checkoutService{
-		repo:   r,
-		logger: logger,
+		cfg:             cfg,
+		repo:            r,
+		loyaltyRepo:     loyaltyRepo,
+		giftCardRepo:    giftCardRepo,
+		reservationRepo: reservationRepo,
+		cartRepo:        cartRepo,
+		drawerRepo:      drawerRepo,
+		auditRepo:       auditRepo,
+		logger:          logger,
 	}
 }
 
@@ -37,17 +59,50 @@ func (s *checkoutService) CreateNewCustomer(ctx context.Context, data model.Cust
 	if data.PhoneNumber == nil {
 		return model.Customer{}, cerr.New(http.StatusBadRequest, "phoneNumber is required")
 	}
+	normalized, err := phone.Normalize(*data.PhoneNumber)
+	if err != nil {
+		return model.Customer{}, cerr.New(http.StatusBadRequest, "phoneNumber must be in E.164 format, e.g. +628123456789")
+	}
+	data.PhoneNumber = &normalized
+
 	_, err = s.repo.GetCustomerByNumber(ctx, *data.PhoneNumber)
+	if err == nil {
+		return model.Customer{}, cerr.New(http.StatusConflict, "phoneNumber already exists")
+	}
 	if !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error("failed get customer by number", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	customer, err = s.repo.CreateCustomer(ctx, tx, data)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
 		return model.Customer{}, cerr.New(http.StatusConflict, "phoneNumber already exists")
 	}
+	if err != nil {
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
 
-	dataCustomer, err := s.repo.CreateCustomer(ctx, data)
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditCustomerCreate, model.AuditCustomer, customer.UserId, nil, customer))
 	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
 		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
 	}
 
-	return dataCustomer, nil
+	return customer, nil
 }
 
 func (s *checkoutService) ValidateUser(ctx context.Context, userId string) (customer model.Customer, err error) {
@@ -63,7 +118,10 @@ func (s *checkoutService) ValidateUser(ctx context.Context, userId string) (cust
 	return dataCustomer, nil
 }
 
-func (s *checkoutService) ValidateProduct(ctx context.Context, products []model.ProductDetail) (total float32, err error) {
+// ValidateProduct prices the products and checks they can be sold. Stock
+// reserved by others counts as unavailable, reservations made by holderId
+// are the caller's own and are ignored.
+func (s *checkoutService) ValidateProduct(ctx context.Context, products []model.ProductDetail, holderId *uuid.UUID) (total float32, err error) {
 	var totalPrice float32
 	for _, product := range products {
 		if product.ProductId == "" {
@@ -78,7 +136,13 @@ func (s *checkoutService) ValidateProduct(ctx context.Context, products []model.
 			return 0, cerr.New(http.StatusNotFound, "productId is not found")
 		}
 
-		if *dataProduct.Stock < product.Quantity {
+		reserved, err := s.reservationRepo.GetReservedStocks(ctx, []string{product.ProductId}, holderId)
+		if err != nil {
+			s.logger.Error("failed get reserved stocks", zap.Error(err))
+			return 0, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+
+		if *dataProduct.Stock-reserved[product.ProductId] < product.Quantity {
 			return 0, cerr.New(http.StatusBadRequest, `quantity product id `+product.ProductId+` is not enough`)
 		}
 
@@ -92,61 +156,421 @@ func (s *checkoutService) ValidateProduct(ctx context.Context, products []model.
 	return totalPrice, nil
 }
 
-func (s *checkoutService) CheckoutProduct(ctx context.Context, transaction model.Transaction) (err error) {
+// PrepareTransaction settles the order against the price of its products
+// and gift cards. Non-cash tenders are deducted first and the rest has to
+// be paid in cash with the exact change.
+func (s *checkoutService) PrepareTransaction(order model.OrderRequest, productTotal float32) (transaction model.Transaction, err error) {
+	totalPrice := productTotal
+	for _, giftCard := range order.GiftCards {
+		totalPrice += float32(giftCard.Amount)
+	}
+
+	var tendered float32
+	for _, payment := range order.Payments {
+		tendered += float32(payment.Amount)
+	}
+	if tendered > totalPrice {
+		return model.Transaction{}, cerr.New(http.StatusBadRequest, "Payments exceed the total price of all bought products")
+	}
+	amountDue := totalPrice - tendered
+
+	if amountDue > float32(*order.Paid) {
+		return model.Transaction{}, cerr.New(http.StatusBadRequest, "Paid amount is not enough based on all bought products")
+	}
+
+	change := float32(*order.Paid) - amountDue
+	if float32(*order.Change) != change {
+		return model.Transaction{}, cerr.New(http.StatusBadRequest, "Change is not correct based on all bought products and what is paid")
+	}
+
+	return model.Transaction{
+		TransactionId:  uuid.New(),
+		CustomerId:     uuid.MustParse(*order.CustomerId),
+		ProductDetails: order.ProductDetails,
+		GiftCards:      order.GiftCards,
+		Total:          int(totalPrice),
+		Payments:       order.Payments,
+		Paid:           *order.Paid,
+		Change:         *order.Change,
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// CheckoutProduct books the transaction on the open cash drawer session of
+// the staff member at the register. Registers that don't track a cash
+// drawer can still check out, their sales carry no session. Stock held by
+// other reservations can't be sold, the reservations of holderId are the
+// caller's own and are released with the sale. holderId is the cart being
+// checked out, it is completed in the same transaction as the sale.
+func (s *checkoutService) CheckoutProduct(ctx context.Context, staffId uuid.UUID, holderId *uuid.UUID, transaction model.Transaction) (result model.Transaction, err error) {
 	// new tx
 	tx, err := s.repo.NewTx()
 	if err != nil {
 		s.logger.Error("CheckoutProduct:%v", zap.Error(err))
-		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
 	}
 	defer func() {
 		if err != nil {
-			err = tx.Rollback()
-		} else {
-			err = tx.Commit()
+			_ = tx.Rollback()
+			return
 		}
+		err = tx.Commit()
 	}()
 
+	session, err := s.drawerRepo.GetOpenSessionForShare(ctx, tx, staffId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error("failed get drawer session", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	if err == nil {
+		transaction.SessionId = &session.ID
+	}
+	transaction.StaffId = &staffId
+
 	productIDs := make([]string, 0, len(transaction.ProductDetails))
 	for _, product := range transaction.ProductDetails {
 		if product.ProductId == "" {
-			return cerr.New(http.StatusBadRequest, "productId cannot be empty")
+			return model.Transaction{}, cerr.New(http.StatusBadRequest, "productId cannot be empty")
 		}
 		productIDs = append(productIDs, product.ProductId)
 	}
 
-	productStocks, err := s.repo.GetProductStocks(ctx, productIDs)
+	// locked so the stock can't be sold or reserved by someone else between
+	// the check below and the update
+	productStocks, err := s.reservationRepo.LockProductStocks(ctx, tx, productIDs)
+	if err != nil {
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "error fetching product stock levels: "+err.Error())
+	}
+
+	reserved, err := s.reservationRepo.GetReservedStocksTx(ctx, tx, productIDs, holderId)
+	if err != nil {
+		s.logger.Error("failed get reserved stocks", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	// snapshot name and price so the receipt shows what was charged
+	products, err := s.repo.GetProductsByIds(ctx, productIDs)
 	if err != nil {
-		return cerr.New(http.StatusInternalServerError, "error fetching product stock levels: "+err.Error())
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "error fetching products: "+err.Error())
+	}
+	productDetails := make([]model.ProductDetail, 0, len(transaction.ProductDetails))
+	for _, product := range transaction.ProductDetails {
+		product.Name = products[product.ProductId].Name
+		product.Price = products[product.ProductId].Price
+		productDetails = append(productDetails, product)
 	}
+	transaction.ProductDetails = productDetails
 
 	updatedStocks := make(map[string]int)
 	for _, product := range transaction.ProductDetails {
 		existingStock, ok := productStocks[product.ProductId]
 		if !ok {
 			// Handle unexpected missing product (shouldn't occur after previous check)
-			return cerr.New(http.StatusInternalServerError, fmt.Sprintf("unexpected error: product %s not found in fetched stocks", product.ProductId))
+			return model.Transaction{}, cerr.New(http.StatusInternalServerError, fmt.Sprintf("unexpected error: product %s not found in fetched stocks", product.ProductId))
+		}
+		if stock, seen := updatedStocks[product.ProductId]; seen {
+			existingStock = stock
 		}
 		updatedStocks[product.ProductId] = existingStock - product.Quantity
 	}
+	for productId, stock := range updatedStocks {
+		if stock < reserved[productId] {
+			return model.Transaction{}, cerr.New(http.StatusBadRequest, `quantity product id `+productId+` is not enough`)
+		}
+	}
+
+	if holderId != nil {
+		err = s.reservationRepo.DeleteReservationsTx(ctx, tx, *holderId)
+		if err != nil {
+			s.logger.Error("failed release reservations", zap.Error(err))
+			return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
 
 	for productId, stock := range updatedStocks {
 		err = s.repo.UpdateStockProduct(ctx, tx, stock, productId)
 		if err != nil {
-			return cerr.New(http.StatusInternalServerError, fmt.Sprintf("error updating stock for product %s: %s", productId, err.Error()))
+			return model.Transaction{}, cerr.New(http.StatusInternalServerError, fmt.Sprintf("error updating stock for product %s: %s", productId, err.Error()))
 		}
 	}
 
+	err = s.applyGiftCards(ctx, tx, &transaction)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	number, day, createdAt, err := s.repo.NextReceiptNumber(ctx, tx, s.cfg.Store.Code)
+	if err != nil {
+		s.logger.Error("failed next receipt number", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	transaction.ReceiptNumber = formatReceiptNumber(s.cfg.Store.Code, day, number)
+	transaction.CreatedAt = createdAt
+
 	err = s.repo.CreateTransaction(ctx, tx, transaction)
 	if err != nil {
-		return cerr.New(http.StatusInternalServerError, fmt.Sprintf("error inserting transaction data"))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, fmt.Sprintf("error inserting transaction data"))
+	}
+
+	if holderId != nil {
+		err = s.cartRepo.CompleteCart(ctx, tx, *holderId, transaction.TransactionId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Transaction{}, cerr.New(http.StatusConflict, "cart can't be checked out in its current status")
+		}
+		if err != nil {
+			s.logger.Error("failed complete cart", zap.Error(err))
+			return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	err = s.applyLoyaltyPoints(ctx, tx, transaction)
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditTransactionCreate, model.AuditTransaction, transaction.TransactionId.String(), nil, transaction))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return transaction, nil
+}
+
+// formatReceiptNumber builds the human readable receipt number, e.g.
+// ENQ-20261018-0042
+func formatReceiptNumber(storeCode string, day time.Time, number int) string {
+	return fmt.Sprintf("%s-%s-%04d", storeCode, day.Format("20060102"), number)
+}
+
+// applyGiftCards issues the gift cards sold in the transaction and redeems
+// the ones used to pay. Generated codes are written back to the transaction.
+func (s *checkoutService) applyGiftCards(ctx context.Context, tx *sqlx.Tx, transaction *model.Transaction) (err error) {
+	for i, sale := range transaction.GiftCards {
+		card, err := issueGiftCard(ctx, tx, s.giftCardRepo, model.GiftCard{
+			Code:         sale.Code,
+			Type:         model.GiftCardSold,
+			InitialValue: sale.Amount,
+			Balance:      sale.Amount,
+		}, &transaction.TransactionId)
+		if err != nil {
+			return err
+		}
+		transaction.GiftCards[i].Code = card.Code
+	}
+
+	for _, payment := range transaction.Payments {
+		if payment.Method != model.PaymentGiftCard {
+			continue
+		}
+		err = redeemGiftCard(ctx, tx, s.giftCardRepo, payment.Reference, payment.Amount, transaction.TransactionId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyLoyaltyPoints redeems the points tendered for the transaction and
+// credits the points earned on the merchandise paid in cash. Gift cards
+// sold earn nothing, the points are earned when the card is spent, and no
+// other tender earns points either.
+func (s *checkoutService) applyLoyaltyPoints(ctx context.Context, tx *sqlx.Tx, transaction model.Transaction) (err error) {
+	err = s.loyaltyRepo.LockCustomer(ctx, tx, transaction.CustomerId)
+	if err != nil {
+		s.logger.Error("failed lock customer points", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	pointsTendered := 0
+	for _, payment := range transaction.Payments {
+		if payment.Method == model.PaymentPoints {
+			pointsTendered += payment.Amount
+		}
+	}
+
+	if pointsTendered > 0 {
+		redeemed, err := pointsForAmount(pointsTendered, s.cfg.Loyalty.PointValue)
+		if err != nil {
+			return err
+		}
+
+		balance, err := s.loyaltyRepo.GetPointBalanceTx(ctx, tx, transaction.CustomerId)
+		if err != nil {
+			s.logger.Error("failed get point balance", zap.Error(err))
+			return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+		if balance < redeemed {
+			return cerr.New(http.StatusBadRequest, "loyalty points balance is not enough")
+		}
+
+		err = s.loyaltyRepo.CreatePointEntry(ctx, tx, model.LoyaltyPoint{
+			CustomerId:    transaction.CustomerId,
+			TransactionId: &transaction.TransactionId,
+			Type:          model.PointRedeem,
+			Points:        -redeemed,
+		})
+		if err != nil {
+			s.logger.Error("failed redeem points", zap.Error(err))
+			return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	earned := int(float64(cashMerchandise(transaction)) * s.cfg.Loyalty.EarnRate)
+	if earned <= 0 {
+		return nil
+	}
+
+	err = s.loyaltyRepo.CreatePointEntry(ctx, tx, model.LoyaltyPoint{
+		CustomerId:    transaction.CustomerId,
+		TransactionId: &transaction.TransactionId,
+		Type:          model.PointEarn,
+		Points:        earned,
+	})
+	if err != nil {
+		s.logger.Error("failed earn points", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
 	}
 
 	return nil
 }
 
-func (s *checkoutService) GetAllCustomer(ctx context.Context, name, phoneNumber string, limit, offset int) (listCustomer []model.CustomerResponseData, err error) {
-	dataCustomer, err := s.repo.GetAllCustomer(ctx, name, phoneNumber, limit, offset)
+// cashMerchandise is the part of the products of the transaction paid in
+// cash, every non-cash tender is taken off the products first.
+func cashMerchandise(transaction model.Transaction) int {
+	amount := transaction.Total
+	for _, sale := range transaction.GiftCards {
+		amount -= sale.Amount
+	}
+	for _, payment := range transaction.Payments {
+		amount -= payment.Amount
+	}
+	if amount < 0 {
+		return 0
+	}
+	return amount
+}
+
+// VoidTransaction cancels a mistaken sale while its cash drawer session is
+// still open, sales without a session only on the same day.
+// The void is approved by managerId, who is recorded as voidedBy and can't
+// be the cashier of the sale. Stock, gift cards and loyalty points go back
+// to where they were before the sale, all in one database transaction.
+func (s *checkoutService) VoidTransaction(ctx context.Context, transactionId uuid.UUID, managerId uuid.UUID, reason string) (result model.Transaction, err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	transaction, err := s.repo.GetTransactionByIdForUpdate(ctx, tx, transactionId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Transaction{}, cerr.New(http.StatusNotFound, "transactionId is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get transaction", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if transaction.VoidedAt != nil {
+		return model.Transaction{}, cerr.New(http.StatusConflict, "transaction is already voided")
+	}
+	if transaction.StaffId != nil && *transaction.StaffId == managerId {
+		return model.Transaction{}, cerr.New(http.StatusForbidden, "a sale has to be voided by someone other than its cashier")
+	}
+	if transaction.SessionId != nil {
+		session, err := s.drawerRepo.GetSessionByIdForShare(ctx, tx, *transaction.SessionId)
+		if err != nil {
+			s.logger.Error("failed get drawer session", zap.Error(err))
+			return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+		if session.Status != model.DrawerOpen {
+			return model.Transaction{}, cerr.New(http.StatusBadRequest, "only transactions of an open shift can be voided")
+		}
+	} else {
+		today, err := s.repo.IsTransactionOfToday(ctx, tx, transaction.TransactionId)
+		if err != nil {
+			s.logger.Error("failed check transaction day", zap.Error(err))
+			return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+		if !today {
+			return model.Transaction{}, cerr.New(http.StatusBadRequest, "only transactions of the current day can be voided")
+		}
+	}
+
+	for _, product := range transaction.ProductDetails {
+		err = s.repo.RestockProduct(ctx, tx, product.ProductId, product.Quantity)
+		if err != nil {
+			s.logger.Error("failed restock product", zap.Error(err))
+			return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	for _, sale := range transaction.GiftCards {
+		err = reverseGiftCardSale(ctx, tx, s.giftCardRepo, sale.Code, transaction.TransactionId)
+		if err != nil {
+			return model.Transaction{}, err
+		}
+	}
+	for _, payment := range transaction.Payments {
+		if payment.Method != model.PaymentGiftCard {
+			continue
+		}
+		err = refundGiftCard(ctx, tx, s.giftCardRepo, payment.Reference, payment.Amount, transaction.TransactionId)
+		if err != nil {
+			return model.Transaction{}, err
+		}
+	}
+
+	err = s.loyaltyRepo.LockCustomer(ctx, tx, transaction.CustomerId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.Error("failed lock customer points", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.loyaltyRepo.ReverseTransactionPoints(ctx, tx, transaction.TransactionId)
+	if err != nil {
+		s.logger.Error("failed reverse points", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	voidedAt, err := s.repo.VoidTransaction(ctx, tx, transaction.TransactionId, reason, managerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Transaction{}, cerr.New(http.StatusConflict, "transaction is already voided")
+	}
+	if err != nil {
+		s.logger.Error("failed void transaction", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	before := transaction
+	transaction.VoidedAt = &voidedAt
+	transaction.VoidReason = reason
+	transaction.VoidedBy = &managerId
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditTransactionVoid, model.AuditTransaction, transaction.TransactionId.String(), before, transaction))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Transaction{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return transaction, nil
+}
+
+func (s *checkoutService) GetAllCustomer(ctx context.Context, params model.GetCustomerParam) (listCustomer []model.CustomerResponseData, err error) {
+	if params.PhoneNumber != "" {
+		params.PhoneNumber = phone.Search(params.PhoneNumber)
+	}
+
+	dataCustomer, err := s.repo.GetAllCustomer(ctx, params)
 	if err != nil {
 		s.logger.Error("failed getAllCustomer", zap.Error(err))
 		return []model.CustomerResponseData{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
@@ -155,11 +579,24 @@ func (s *checkoutService) GetAllCustomer(ctx context.Context, name, phoneNumber
 	return dataCustomer, nil
 }
 
-func (s *checkoutService) GetAllTransaction(ctx context.Context, params model.GetHistoryParam) (listTransaction []model.Transaction, err error) {
+func (s *checkoutService) GetAllTransaction(ctx context.Context, params model.GetHistoryParam) (listTransaction []model.Transaction, meta model.PageMeta, err error) {
+	if params.Limit == 0 {
+		params.Limit = 5 // default limit
+	}
+
 	listTransaction, err = s.repo.GetHistoryTransaction(ctx, params)
 	if err != nil {
 		return
 	}
 
-	return listTransaction, nil
+	total, err := s.repo.CountHistoryTransaction(ctx, params)
+	if err != nil {
+		return
+	}
+
+	return listTransaction, model.PageMeta{
+		Total:  total,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	}, nil
 }
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/phone"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type CustomerService interface {
+	GetCustomer(ctx context.Context, id uuid.UUID) (customer model.Customer, err error)
+	UpdateCustomer(ctx context.Context, id uuid.UUID, data model.UpdateCustomerRequest) (customer model.Customer, err error)
+	DeleteCustomer(ctx context.Context, id uuid.UUID) (result model.DeleteCustomerResult, err error)
+	GetCustomerSummary(ctx context.Context, id uuid.UUID) (summary model.CustomerSummary, err error)
+	GetDuplicateCandidates(ctx context.Context) (groups []model.DuplicateGroup, err error)
+	MergeCustomer(ctx context.Context, targetId uuid.UUID, sourceId uuid.UUID) (customer model.Customer, err error)
+	ExportCustomer(ctx context.Context, id uuid.UUID) (export model.CustomerExport, err error)
+	EraseCustomer(ctx context.Context, id uuid.UUID) (result model.EraseCustomerResult, err error)
+}
+
+const (
+	summaryTopProducts   = 5
+	summaryTopCategories = 3
+)
+
+type customerService struct {
+	repo         repo.CustomerRepo
+	checkoutRepo repo.CheckoutRepo
+	auditRepo    repo.AuditRepo
+	logger       *zap.Logger
+}
+
+func NewCustomerService(r repo.CustomerRepo, checkoutRepo repo.CheckoutRepo, auditRepo repo.AuditRepo, logger *zap.Logger) CustomerService {
+	return &customerService{
+		repo:         r,
+		checkoutRepo: checkoutRepo,
+		auditRepo:    auditRepo,
+		logger:       logger,
+	}
+}
+
+func (s *customerService) GetCustomer(ctx context.Context, id uuid.UUID) (customer model.Customer, err error) {
+	customer, err = s.checkoutRepo.GetCustomerById(ctx, id.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Customer{}, cerr.New(http.StatusNotFound, "customerId is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get customer", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return customer, nil
+}
+
+func (s *customerService) UpdateCustomer(ctx context.Context, id uuid.UUID, data model.UpdateCustomerRequest) (customer model.Customer, err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	current, err := s.repo.GetCustomerByIdForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Customer{}, cerr.New(http.StatusNotFound, "customerId is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get customer", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if data.PhoneNumber != nil {
+		normalized, err := phone.Normalize(*data.PhoneNumber)
+		if err != nil {
+			return model.Customer{}, cerr.New(http.StatusBadRequest, "phoneNumber must be in E.164 format, e.g. +628123456789")
+		}
+		data.PhoneNumber = &normalized
+	}
+	if data.PhoneNumber != nil && *data.PhoneNumber != current.PhoneNumber {
+		_, err = s.checkoutRepo.GetCustomerByNumber(ctx, *data.PhoneNumber)
+		if err == nil {
+			return model.Customer{}, cerr.New(http.StatusConflict, "phoneNumber already exists")
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Error("failed get customer by number", zap.Error(err))
+			return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	// the unique index decides when two updates race for the same number
+	customer, err = s.repo.UpdateCustomer(ctx, tx, id, data)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return model.Customer{}, cerr.New(http.StatusConflict, "phoneNumber already exists")
+	}
+	if err != nil {
+		s.logger.Error("failed update customer", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditCustomerUpdate, model.AuditCustomer, id.String(), current, customer))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return customer, nil
+}
+
+// DeleteCustomer removes a customer without any history. Customers with
+// transactions, points or gift cards are anonymised instead so the books
+// stay intact.
+func (s *customerService) DeleteCustomer(ctx context.Context, id uuid.UUID) (result model.DeleteCustomerResult, err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.DeleteCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	current, err := s.repo.GetCustomerByIdForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DeleteCustomerResult{}, cerr.New(http.StatusNotFound, "customerId is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get customer", zap.Error(err))
+		return model.DeleteCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	hasHistory, err := s.repo.HasCustomerHistory(ctx, tx, id)
+	if err != nil {
+		s.logger.Error("failed check customer history", zap.Error(err))
+		return model.DeleteCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	action := model.AuditCustomerDelete
+	if hasHistory {
+		action = model.AuditCustomerAnonymise
+		err = s.repo.AnonymiseCustomer(ctx, tx, id, "Deleted customer")
+	} else {
+		err = s.repo.DeleteCustomer(ctx, tx, id)
+	}
+	if err != nil {
+		s.logger.Error("failed delete customer", zap.Error(err))
+		return model.DeleteCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, action, model.AuditCustomer, id.String(), current, nil))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.DeleteCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return model.DeleteCustomerResult{
+		UserId:     id.String(),
+		Anonymised: hasHistory,
+	}, nil
+}
+
+func (s *customerService) GetCustomerSummary(ctx context.Context, id uuid.UUID) (summary model.CustomerSummary, err error) {
+	if _, err = s.GetCustomer(ctx, id); err != nil {
+		return model.CustomerSummary{}, err
+	}
+
+	summary, err = s.repo.GetCustomerTotals(ctx, id)
+	if err != nil {
+		s.logger.Error("failed get customer totals", zap.Error(err))
+		return model.CustomerSummary{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	summary.CustomerId = id.String()
+	if summary.Visits > 0 {
+		summary.AverageBasket = summary.TotalSpend / summary.Visits
+	}
+
+	summary.TopProducts, err = s.repo.GetCustomerTopProducts(ctx, id, summaryTopProducts)
+	if err != nil {
+		s.logger.Error("failed get customer top products", zap.Error(err))
+		return model.CustomerSummary{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	summary.FavouriteCategories, err = s.repo.GetCustomerTopCategories(ctx, id, summaryTopCategories)
+	if err != nil {
+		s.logger.Error("failed get customer top categories", zap.Error(err))
+		return model.CustomerSummary{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return summary, nil
+}
+
+func (s *customerService) GetDuplicateCandidates(ctx context.Context) (groups []model.DuplicateGroup, err error) {
+	groups, err = s.repo.GetDuplicateCandidates(ctx)
+	if err != nil {
+		s.logger.Error("failed get duplicate customers", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return groups, nil
+}
+
+// MergeCustomer moves the transactions, points, gift cards and notes of
+// the source customer to the target and removes the source.
+func (s *customerService) MergeCustomer(ctx context.Context, targetId uuid.UUID, sourceId uuid.UUID) (customer model.Customer, err error) {
+	if targetId == sourceId {
+		return model.Customer{}, cerr.New(http.StatusBadRequest, "a customer can't be merged into itself")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// lock in a fixed order so two merges of the same pair can't deadlock
+	ids := []uuid.UUID{targetId, sourceId}
+	if sourceId.String() < targetId.String() {
+		ids = []uuid.UUID{sourceId, targetId}
+	}
+	before := map[uuid.UUID]model.Customer{}
+	for _, id := range ids {
+		before[id], err = s.repo.GetCustomerByIdForUpdate(ctx, tx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Customer{}, cerr.New(http.StatusNotFound, "customerId "+id.String()+" is not found")
+		}
+		if err != nil {
+			s.logger.Error("failed get customer", zap.Error(err))
+			return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	err = s.repo.ReassignCustomer(ctx, tx, sourceId, targetId)
+	if err != nil {
+		s.logger.Error("failed reassign customer", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.repo.MergeCustomerProfile(ctx, tx, targetId, sourceId)
+	if err != nil {
+		s.logger.Error("failed merge customer profile", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.repo.DeleteCustomer(ctx, tx, sourceId)
+	if err != nil {
+		s.logger.Error("failed delete merged customer", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.repo.RecordMerge(ctx, tx, targetId, sourceId)
+	if err != nil {
+		s.logger.Error("failed record customer merge", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	customer, err = s.repo.GetCustomerByIdForUpdate(ctx, tx, targetId)
+	if err != nil {
+		s.logger.Error("failed get customer", zap.Error(err))
+		return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	// one entry for each side so the merge shows up in both histories
+	for _, entry := range []model.AuditEntry{
+		newAuditEntry(ctx, model.AuditCustomerMerge, model.AuditCustomer, targetId.String(), before[targetId], customer),
+		newAuditEntry(ctx, model.AuditCustomerMerge, model.AuditCustomer, sourceId.String(), before[sourceId], nil),
+	} {
+		if err = s.auditRepo.CreateEntryTx(ctx, tx, entry); err != nil {
+			s.logger.Error("failed write audit entry", zap.Error(err))
+			return model.Customer{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	return customer, nil
+}
+
+// ExportCustomer collects everything stored about the customer
+func (s *customerService) ExportCustomer(ctx context.Context, id uuid.UUID) (export model.CustomerExport, err error) {
+	customer, err := s.GetCustomer(ctx, id)
+	if err != nil {
+		return model.CustomerExport{}, err
+	}
+
+	transactions, err := s.repo.GetCustomerTransactions(ctx, id)
+	if err != nil {
+		s.logger.Error("failed get customer transactions", zap.Error(err))
+		return model.CustomerExport{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	entries, err := s.repo.GetCustomerPointEntries(ctx, id)
+	if err != nil {
+		s.logger.Error("failed get customer points", zap.Error(err))
+		return model.CustomerExport{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	balance := 0
+	for _, entry := range entries {
+		balance += entry.Points
+	}
+
+	giftCards, err := s.repo.GetCustomerGiftCards(ctx, id)
+	if err != nil {
+		s.logger.Error("failed get customer gift cards", zap.Error(err))
+		return model.CustomerExport{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return model.CustomerExport{
+		ExportedAt: time.Now(),
+		Profile: model.CustomerData{
+			Customer:  customer,
+			CreatedAt: customer.CreatedAt,
+		},
+		Transactions: transactions,
+		Loyalty: model.CustomerPoints{
+			CustomerId: id.String(),
+			Balance:    balance,
+			History:    entries,
+		},
+		GiftCards: giftCards,
+	}, nil
+}
+
+// EraseCustomer replaces the name with a random pseudonym and wipes the
+// phone number and the rest of the profile. Transactions, points and gift
+// cards stay for the books, pointing at the pseudonymised customer.
+func (s *customerService) EraseCustomer(ctx context.Context, id uuid.UUID) (result model.EraseCustomerResult, err error) {
+	pseudonym, err := customerPseudonym()
+	if err != nil {
+		s.logger.Error("failed generate pseudonym", zap.Error(err))
+		return model.EraseCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.EraseCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	_, err = s.repo.GetCustomerByIdForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.EraseCustomerResult{}, cerr.New(http.StatusNotFound, "customerId is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get customer", zap.Error(err))
+		return model.EraseCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.repo.DetachCustomerCarts(ctx, tx, id)
+	if err != nil {
+		s.logger.Error("failed detach customer carts", zap.Error(err))
+		return model.EraseCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.repo.AnonymiseCustomer(ctx, tx, id, pseudonym)
+	if err != nil {
+		s.logger.Error("failed erase customer", zap.Error(err))
+		return model.EraseCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	result = model.EraseCustomerResult{
+		UserId:    id.String(),
+		Pseudonym: pseudonym,
+	}
+
+	// the audit log would otherwise keep the profile that was just erased,
+	// including the profiles of the customers merged into it
+	merged, err := s.repo.GetMergedIds(ctx, tx, id)
+	if err != nil {
+		s.logger.Error("failed get merged customers", zap.Error(err))
+		return model.EraseCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	for _, entityId := range append([]uuid.UUID{id}, merged...) {
+		err = s.auditRepo.RedactEntityTx(ctx, tx, model.AuditCustomer, entityId.String())
+		if err != nil {
+			s.logger.Error("failed redact audit entries", zap.Error(err))
+			return model.EraseCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditCustomerErase, model.AuditCustomer, id.String(), nil, result))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.EraseCustomerResult{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return result, nil
+}
+
+// customerPseudonym is random rather than derived from the customer, so it
+// can't be traced back.
+func customerPseudonym() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "Customer " + strings.ToUpper(hex.EncodeToString(buf)), nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type DrawerService interface {
+	OpenSession(ctx context.Context, staffId uuid.UUID, data model.OpenDrawerRequest) (session model.DrawerSession, err error)
+	GetCurrentSession(ctx context.Context, staffId uuid.UUID) (session model.DrawerSession, err error)
+	AddMovement(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole, data model.DrawerMovementRequest) (movement model.DrawerMovement, err error)
+	GetXReport(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole) (report model.DrawerReport, err error)
+	CloseSession(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole, data model.CloseDrawerRequest) (report model.DrawerReport, err error)
+	GetZReport(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole) (report model.DrawerReport, err error)
+}
+
+type drawerService struct {
+	repo   repo.DrawerRepo
+	logger *zap.Logger
+}
+
+func NewDrawerService(r repo.DrawerRepo, logger *zap.Logger) DrawerService {
+	return &drawerService{
+		repo:   r,
+		logger: logger,
+	}
+}
+
+func (s *drawerService) OpenSession(ctx context.Context, staffId uuid.UUID, data model.OpenDrawerRequest) (session model.DrawerSession, err error) {
+	session, err = s.repo.CreateSession(ctx, model.DrawerSession{
+		StaffId:      staffId,
+		OpeningFloat: *data.OpeningFloat,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return model.DrawerSession{}, cerr.New(http.StatusConflict, "a cash drawer session is already open")
+	}
+	if err != nil {
+		s.logger.Error("failed open drawer session", zap.Error(err))
+		return model.DrawerSession{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return session, nil
+}
+
+func (s *drawerService) GetCurrentSession(ctx context.Context, staffId uuid.UUID) (session model.DrawerSession, err error) {
+	session, err = s.repo.GetOpenSession(ctx, staffId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DrawerSession{}, cerr.New(http.StatusNotFound, "no cash drawer session is open")
+	}
+	if err != nil {
+		s.logger.Error("failed get drawer session", zap.Error(err))
+		return model.DrawerSession{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return session, nil
+}
+
+func (s *drawerService) AddMovement(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole, data model.DrawerMovementRequest) (movement model.DrawerMovement, err error) {
+	_, err = s.getOwnSession(ctx, sessionId, staffId, role)
+	if err != nil {
+		return model.DrawerMovement{}, err
+	}
+
+	movement, err = s.repo.CreateMovement(ctx, model.DrawerMovement{
+		SessionId: sessionId,
+		StaffId:   staffId,
+		Type:      data.Type,
+		Amount:    *data.Amount,
+		Reason:    *data.Reason,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DrawerMovement{}, cerr.New(http.StatusConflict, "cash drawer session is not open")
+	}
+	if err != nil {
+		s.logger.Error("failed create drawer movement", zap.Error(err))
+		return model.DrawerMovement{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return movement, nil
+}
+
+// GetXReport reports on an open session without closing it
+func (s *drawerService) GetXReport(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole) (report model.DrawerReport, err error) {
+	session, err := s.getOwnSession(ctx, sessionId, staffId, role)
+	if err != nil {
+		return model.DrawerReport{}, err
+	}
+	if session.Status != model.DrawerOpen {
+		return model.DrawerReport{}, cerr.New(http.StatusConflict, "cash drawer session is closed, use the Z report")
+	}
+
+	return s.buildReport(ctx, model.DrawerReportX, session)
+}
+
+// CloseSession records the counted cash and returns the Z report. Closing
+// waits for checkouts still booking on the session. The staff member who
+// opened the drawer closes it, managers can close any drawer.
+func (s *drawerService) CloseSession(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole, data model.CloseDrawerRequest) (report model.DrawerReport, err error) {
+	session, err := s.getOwnSession(ctx, sessionId, staffId, role)
+	if err != nil {
+		return model.DrawerReport{}, err
+	}
+	if session.Status != model.DrawerOpen {
+		return model.DrawerReport{}, cerr.New(http.StatusConflict, "cash drawer session is already closed")
+	}
+
+	session, err = s.repo.CloseSession(ctx, sessionId, *data.CountedCash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DrawerReport{}, cerr.New(http.StatusConflict, "cash drawer session is already closed")
+	}
+	if err != nil {
+		s.logger.Error("failed close drawer session", zap.Error(err))
+		return model.DrawerReport{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return s.buildReport(ctx, model.DrawerReportZ, session)
+}
+
+// GetZReport reports on a closed session, it can be printed again later
+func (s *drawerService) GetZReport(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole) (report model.DrawerReport, err error) {
+	session, err := s.getOwnSession(ctx, sessionId, staffId, role)
+	if err != nil {
+		return model.DrawerReport{}, err
+	}
+	if session.Status != model.DrawerClosed {
+		return model.DrawerReport{}, cerr.New(http.StatusConflict, "cash drawer session is still open, use the X report")
+	}
+
+	return s.buildReport(ctx, model.DrawerReportZ, session)
+}
+
+// getOwnSession returns the session if staffId opened it or is a manager
+func (s *drawerService) getOwnSession(ctx context.Context, sessionId uuid.UUID, staffId uuid.UUID, role model.StaffRole) (model.DrawerSession, error) {
+	session, err := s.getSession(ctx, sessionId)
+	if err != nil {
+		return model.DrawerSession{}, err
+	}
+	if session.StaffId != staffId && !role.IsManager() {
+		return model.DrawerSession{}, cerr.New(http.StatusForbidden, "cash drawer session belongs to another staff member")
+	}
+
+	return session, nil
+}
+
+func (s *drawerService) getSession(ctx context.Context, sessionId uuid.UUID) (model.DrawerSession, error) {
+	session, err := s.repo.GetSessionById(ctx, sessionId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DrawerSession{}, cerr.New(http.StatusNotFound, "cash drawer session is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get drawer session", zap.Error(err))
+		return model.DrawerSession{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return session, nil
+}
+
+// buildReport works out the expected cash: the float plus cash taken and
+// pay-ins, minus pay-outs. Voided sales are left out.
+func (s *drawerService) buildReport(ctx context.Context, reportType model.DrawerReportType, session model.DrawerSession) (model.DrawerReport, error) {
+	sales, err := s.repo.GetSessionSales(ctx, session.ID)
+	if err != nil {
+		s.logger.Error("failed get drawer sales", zap.Error(err))
+		return model.DrawerReport{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	payments, err := s.repo.GetSessionPayments(ctx, session.ID)
+	if err != nil {
+		s.logger.Error("failed get drawer payments", zap.Error(err))
+		return model.DrawerReport{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	payments["cash"] = sales.Cash
+
+	movements, err := s.repo.GetMovementTotals(ctx, session.ID)
+	if err != nil {
+		s.logger.Error("failed get drawer movements", zap.Error(err))
+		return model.DrawerReport{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	report := model.DrawerReport{
+		Type:        reportType,
+		Session:     session,
+		Sales:       sales,
+		Payments:    payments,
+		PayIns:      movements[model.DrawerPayIn],
+		PayOuts:     movements[model.DrawerPayOut],
+		CountedCash: session.CountedCash,
+		GeneratedAt: time.Now(),
+	}
+	report.ExpectedCash = session.OpeningFloat + sales.Cash + report.PayIns - report.PayOuts
+	if session.CountedCash != nil {
+		difference := *session.CountedCash - report.ExpectedCash
+		report.Difference = &difference
+	}
+
+	return report, nil
+}
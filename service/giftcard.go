@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type GiftCardService interface {
+	IssueGiftCard(ctx context.Context, data model.IssueGiftCardRequest) (giftCard model.GiftCard, err error)
+	GetGiftCard(ctx context.Context, code string) (giftCard model.GiftCardDetail, err error)
+}
+
+type giftCardService struct {
+	repo   repo.GiftCardRepo
+	logger *zap.Logger
+}
+
+func NewGiftCardService(r repo.GiftCardRepo, logger *zap.Logger) GiftCardService {
+	return &giftCardService{
+		repo:   r,
+		logger: logger,
+	}
+}
+
+// IssueGiftCard issues a card outside of a sale, e.g. store credit given
+// to a customer for returned goods.
+func (s *giftCardService) IssueGiftCard(ctx context.Context, data model.IssueGiftCardRequest) (giftCard model.GiftCard, err error) {
+	card := model.GiftCard{
+		Type:         data.Type,
+		InitialValue: *data.Amount,
+		Balance:      *data.Amount,
+	}
+	if data.CustomerId != nil {
+		customerId := uuid.MustParse(*data.CustomerId)
+		card.CustomerId = &customerId
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.GiftCard{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	giftCard, err = issueGiftCard(ctx, tx, s.repo, card, nil)
+	if err != nil {
+		s.logger.Error("failed issue gift card", zap.Error(err))
+		return model.GiftCard{}, err
+	}
+
+	return giftCard, nil
+}
+
+func (s *giftCardService) GetGiftCard(ctx context.Context, code string) (giftCard model.GiftCardDetail, err error) {
+	card, err := s.repo.GetGiftCardByCode(ctx, normalizeGiftCardCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.GiftCardDetail{}, cerr.New(http.StatusNotFound, "gift card is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get gift card", zap.Error(err))
+		return model.GiftCardDetail{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	movements, err := s.repo.GetGiftCardMovements(ctx, card.ID)
+	if err != nil {
+		s.logger.Error("failed get gift card movements", zap.Error(err))
+		return model.GiftCardDetail{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return model.GiftCardDetail{
+		GiftCard:  card,
+		Movements: movements,
+	}, nil
+}
+
+// issueGiftCard creates the card and writes the opening ledger entry.
+func issueGiftCard(ctx context.Context, tx *sqlx.Tx, r repo.GiftCardRepo, card model.GiftCard, transactionId *uuid.UUID) (model.GiftCard, error) {
+	if card.Code == "" {
+		code, err := generateGiftCardCode()
+		if err != nil {
+			return model.GiftCard{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+		card.Code = code
+	}
+	card.Code = normalizeGiftCardCode(card.Code)
+
+	_, err := r.GetGiftCardByCodeForUpdate(ctx, tx, card.Code)
+	if err == nil {
+		return model.GiftCard{}, cerr.New(http.StatusConflict, "gift card code "+card.Code+" already exists")
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return model.GiftCard{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	// the lookup locks nothing when the code is free, a concurrent issue of
+	// the same code is caught by the unique constraint
+	created, err := r.CreateGiftCard(ctx, tx, card)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return model.GiftCard{}, cerr.New(http.StatusConflict, "gift card code "+card.Code+" already exists")
+	}
+	if err != nil {
+		return model.GiftCard{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = r.CreateGiftCardMovement(ctx, tx, model.GiftCardMovement{
+		GiftCardId:    created.ID,
+		TransactionId: transactionId,
+		Type:          model.GiftCardIssue,
+		Amount:        created.InitialValue,
+		Balance:       created.Balance,
+	})
+	if err != nil {
+		return model.GiftCard{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return created, nil
+}
+
+// redeemGiftCard takes amount off the card balance, leaving the remainder
+// on the card for a later purchase.
+func redeemGiftCard(ctx context.Context, tx *sqlx.Tx, r repo.GiftCardRepo, code string, amount int, transactionId uuid.UUID) error {
+	card, err := r.GetGiftCardByCodeForUpdate(ctx, tx, normalizeGiftCardCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return cerr.New(http.StatusNotFound, "gift card "+code+" is not found")
+	}
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if card.Balance < amount {
+		return cerr.New(http.StatusBadRequest, "gift card "+code+" balance is not enough")
+	}
+
+	balance := card.Balance - amount
+	err = r.UpdateGiftCardBalance(ctx, tx, card.ID, balance)
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = r.CreateGiftCardMovement(ctx, tx, model.GiftCardMovement{
+		GiftCardId:    card.ID,
+		TransactionId: &transactionId,
+		Type:          model.GiftCardRedeem,
+		Amount:        -amount,
+		Balance:       balance,
+	})
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+// reverseGiftCardSale cancels a card sold by a voided transaction. Only
+// cards that were never spent can be taken back.
+func reverseGiftCardSale(ctx context.Context, tx *sqlx.Tx, r repo.GiftCardRepo, code string, transactionId uuid.UUID) error {
+	card, err := r.GetGiftCardByCodeForUpdate(ctx, tx, normalizeGiftCardCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return cerr.New(http.StatusNotFound, "gift card "+code+" is not found")
+	}
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if card.Balance != card.InitialValue {
+		return cerr.New(http.StatusConflict, "gift card "+code+" has already been used")
+	}
+
+	err = r.UpdateGiftCardBalance(ctx, tx, card.ID, 0)
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = r.CreateGiftCardMovement(ctx, tx, model.GiftCardMovement{
+		GiftCardId:    card.ID,
+		TransactionId: &transactionId,
+		Type:          model.GiftCardReversal,
+		Amount:        -card.Balance,
+		Balance:       0,
+	})
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+// refundGiftCard puts an amount redeemed by a voided transaction back on
+// the card.
+func refundGiftCard(ctx context.Context, tx *sqlx.Tx, r repo.GiftCardRepo, code string, amount int, transactionId uuid.UUID) error {
+	card, err := r.GetGiftCardByCodeForUpdate(ctx, tx, normalizeGiftCardCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return cerr.New(http.StatusNotFound, "gift card "+code+" is not found")
+	}
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	balance := card.Balance + amount
+	err = r.UpdateGiftCardBalance(ctx, tx, card.ID, balance)
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = r.CreateGiftCardMovement(ctx, tx, model.GiftCardMovement{
+		GiftCardId:    card.ID,
+		TransactionId: &transactionId,
+		Type:          model.GiftCardReversal,
+		Amount:        amount,
+		Balance:       balance,
+	})
+	if err != nil {
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+const giftCardCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// generateGiftCardCode returns a random code formatted as XXXX-XXXX-XXXX-XXXX,
+// ambiguous characters like 0/O and 1/I are left out.
+func generateGiftCardCode() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, b := range buf {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(giftCardCodeAlphabet[int(b)%len(giftCardCodeAlphabet)])
+	}
+
+	return code.String(), nil
+}
+
+func normalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
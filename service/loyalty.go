@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type LoyaltyService interface {
+	GetCustomerPoints(ctx context.Context, customerId uuid.UUID, params model.GetPointHistoryParam) (points model.CustomerPoints, err error)
+}
+
+type loyaltyService struct {
+	repo         repo.LoyaltyRepo
+	checkoutRepo repo.CheckoutRepo
+	logger       *zap.Logger
+}
+
+func NewLoyaltyService(r repo.LoyaltyRepo, checkoutRepo repo.CheckoutRepo, logger *zap.Logger) LoyaltyService {
+	return &loyaltyService{
+		repo:         r,
+		checkoutRepo: checkoutRepo,
+		logger:       logger,
+	}
+}
+
+func (s *loyaltyService) GetCustomerPoints(ctx context.Context, customerId uuid.UUID, params model.GetPointHistoryParam) (points model.CustomerPoints, err error) {
+	_, err = s.checkoutRepo.GetCustomerById(ctx, customerId.String())
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.CustomerPoints{}, cerr.New(http.StatusNotFound, "customerId is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get customer", zap.Error(err))
+		return model.CustomerPoints{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	balance, err := s.repo.GetPointBalance(ctx, customerId)
+	if err != nil {
+		s.logger.Error("failed get point balance", zap.Error(err))
+		return model.CustomerPoints{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	history, err := s.repo.GetPointHistory(ctx, customerId, params)
+	if err != nil {
+		s.logger.Error("failed get point history", zap.Error(err))
+		return model.CustomerPoints{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return model.CustomerPoints{
+		CustomerId: customerId.String(),
+		Balance:    balance,
+		History:    history,
+	}, nil
+}
+
+// pointsForAmount converts a currency amount tendered with points into the
+// number of points it costs.
+func pointsForAmount(amount, pointValue int) (int, error) {
+	if pointValue <= 0 {
+		pointValue = 1
+	}
+	if amount%pointValue != 0 {
+		return 0, cerr.New(http.StatusBadRequest, "points amount must be a multiple of the point value")
+	}
+	return amount / pointValue, nil
+}
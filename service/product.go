@@ -8,6 +8,7 @@ import (
 	cerr "eniqilo-store/utils/error"
 	"errors"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"net/http"
 )
 
@@ -21,34 +22,75 @@ type ProductService interface {
 }
 
 type productService struct {
-	repo repo.ProductRepo
+	repo      repo.ProductRepo
+	auditRepo repo.AuditRepo
+	logger    *zap.Logger
 }
 
 // NewProductService creates a new instance of ProductService.
-func NewProductService(repo repo.ProductRepo) ProductService {
+func NewProductService(repo repo.ProductRepo, auditRepo repo.AuditRepo, logger *zap.Logger) ProductService {
 	return &productService{
-		repo: repo,
+		repo:      repo,
+		auditRepo: auditRepo,
+		logger:    logger,
 	}
 }
 
 // CreateProduct handles the creation of a new product.
-func (s *productService) CreateProduct(ctx context.Context, prod model.Product) (model.Product, error) {
+func (s *productService) CreateProduct(ctx context.Context, prod model.Product) (created model.Product, err error) {
 	// Validate the product
 	if err := validateCreateProduct(prod); err != nil {
 		return model.Product{}, cerr.New(http.StatusBadRequest, err.Error())
 	}
 
-	return s.repo.CreateProduct(ctx, prod)
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Product{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	created, err = s.repo.CreateProduct(ctx, tx, prod)
+	if err != nil {
+		return model.Product{}, err
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditProductCreate, model.AuditProduct, created.ID.String(), nil, created))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Product{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return created, nil
 }
 
 // UpdateProduct handles the creation of a new product.
-func (s *productService) UpdateProduct(ctx context.Context, prod model.Product) (model.Product, error) {
+func (s *productService) UpdateProduct(ctx context.Context, prod model.Product) (_ model.Product, err error) {
 	// Validate the product
 	if err := validateCreateProduct(prod); err != nil {
 		return model.Product{}, cerr.New(http.StatusBadRequest, err.Error())
 	}
 
-	_, err := s.repo.GetProductByID(ctx, prod.ID)
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Product{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	before, err := s.repo.GetProductByIDForUpdate(ctx, tx, prod.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return model.Product{}, cerr.New(http.StatusNotFound, "Product not found")
@@ -56,7 +98,19 @@ func (s *productService) UpdateProduct(ctx context.Context, prod model.Product)
 		return model.Product{}, cerr.New(http.StatusInternalServerError, "Error updating product")
 	}
 
-	return prod, s.repo.UpdateProduct(ctx, prod)
+	if err = s.repo.UpdateProduct(ctx, tx, prod); err != nil {
+		return prod, err
+	}
+
+	after := prod
+	after.CreatedAt = before.CreatedAt
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditProductUpdate, model.AuditProduct, prod.ID.String(), before, after))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Product{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return prod, nil
 }
 
 func validateCreateProduct(prod model.Product) error {
@@ -122,8 +176,41 @@ func validateCreateProduct(prod model.Product) error {
 	return nil
 }
 
-func (s *productService) DeleteProduct(ctx context.Context, id uuid.UUID) error {
-	return s.repo.DeleteProduct(ctx, id)
+func (s *productService) DeleteProduct(ctx context.Context, id uuid.UUID) (err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	before, err := s.repo.GetProductByIDForUpdate(ctx, tx, id)
+	if err == sql.ErrNoRows {
+		return cerr.New(http.StatusNotFound, "Product not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get product", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if err = s.repo.DeleteProduct(ctx, tx, id); err != nil {
+		s.logger.Error("failed delete product", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditProductDelete, model.AuditProduct, id.String(), before, nil))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
 }
 
 func (s *productService) GetProduct(ctx context.Context, param model.GetProductParam) (product []model.Product, err error) {
@@ -136,7 +223,11 @@ func (s *productService) GetProduct(ctx context.Context, param model.GetProductP
 	return product, err
 }
 
+// GetProductCustomer lists the products a customer can buy right now,
+// stock held for parked carts is not offered.
 func (s *productService) GetProductCustomer(ctx context.Context, param model.GetProductParam) ([]model.Product, error) {
-	*param.IsAvailable = true
-	return s.repo.GetProduct(ctx, param)
+	isAvailable := true
+	param.IsAvailable = &isAvailable
+	param.AvailableStock = true
+	return s.GetProduct(ctx, param)
 }
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/config"
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/receipt"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type ReceiptService interface {
+	GetReceipt(ctx context.Context, reference string) (receipt model.Receipt, err error)
+	RenderReceipt(ctx context.Context, reference string, format model.ReceiptFormat) (content []byte, err error)
+}
+
+type receiptService struct {
+	cfg          *config.Config
+	checkoutRepo repo.CheckoutRepo
+	logger       *zap.Logger
+}
+
+func NewReceiptService(cfg *config.Config, checkoutRepo repo.CheckoutRepo, logger *zap.Logger) ReceiptService {
+	return &receiptService{
+		cfg:          cfg,
+		checkoutRepo: checkoutRepo,
+		logger:       logger,
+	}
+}
+
+// GetReceipt looks the transaction up by its id or by its receipt number
+func (s *receiptService) GetReceipt(ctx context.Context, reference string) (result model.Receipt, err error) {
+	var transaction model.Transaction
+	if transactionId, parseErr := uuid.Parse(reference); parseErr == nil {
+		transaction, err = s.checkoutRepo.GetTransactionById(ctx, transactionId)
+	} else {
+		transaction, err = s.checkoutRepo.GetTransactionByReceiptNumber(ctx, reference)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Receipt{}, cerr.New(http.StatusNotFound, "transaction is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get transaction", zap.Error(err))
+		return model.Receipt{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	// transactions made before prices were snapshotted fall back to the
+	// current product data
+	var missing []string
+	for _, product := range transaction.ProductDetails {
+		if product.Name == "" {
+			missing = append(missing, product.ProductId)
+		}
+	}
+	products := map[string]model.Product{}
+	if len(missing) > 0 {
+		products, err = s.checkoutRepo.GetProductsByIds(ctx, missing)
+		if err != nil {
+			s.logger.Error("failed get products", zap.Error(err))
+			return model.Receipt{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	result = model.Receipt{
+		StoreName:     s.cfg.Store.Name,
+		StoreAddress:  s.cfg.Store.Address,
+		StorePhone:    s.cfg.Store.Phone,
+		TransactionId: transaction.TransactionId,
+		ReceiptNumber: transaction.ReceiptNumber,
+		Voided:        transaction.VoidedAt != nil,
+		CreatedAt:     transaction.CreatedAt,
+		Cashier:       transaction.CashierName,
+		Total:         transaction.Total,
+		TaxRate:       s.cfg.Store.TaxRate,
+		Paid:          transaction.Paid,
+		Change:        transaction.Change,
+	}
+
+	for _, product := range transaction.ProductDetails {
+		if product.Name == "" {
+			product.Name = products[product.ProductId].Name
+			product.Price = products[product.ProductId].Price
+		}
+		result.Lines = append(result.Lines, model.ReceiptLine{
+			Name:      product.Name,
+			Quantity:  product.Quantity,
+			UnitPrice: product.Price,
+			Total:     product.Price * product.Quantity,
+		})
+	}
+	for _, giftCard := range transaction.GiftCards {
+		result.Lines = append(result.Lines, model.ReceiptLine{
+			Name:      "Gift card " + maskCode(giftCard.Code),
+			Quantity:  1,
+			UnitPrice: giftCard.Amount,
+			Total:     giftCard.Amount,
+		})
+	}
+
+	for _, payment := range transaction.Payments {
+		method := "Points"
+		if payment.Method == model.PaymentGiftCard {
+			method = "Gift card " + maskCode(payment.Reference)
+		}
+		result.Payments = append(result.Payments, model.ReceiptPayment{
+			Method: method,
+			Amount: payment.Amount,
+		})
+	}
+
+	// prices include tax, so the tax is the part above the net amount. Gift
+	// cards sold are a prepayment, not a supply, the goods they buy later are
+	// taxed on the sale that redeems them.
+	if result.TaxRate > 0 {
+		taxable := result.Total
+		for _, giftCard := range transaction.GiftCards {
+			taxable -= giftCard.Amount
+		}
+		net := math.Round(float64(taxable) / (1 + result.TaxRate/100))
+		result.Tax = taxable - int(net)
+	}
+
+	return result, nil
+}
+
+func (s *receiptService) RenderReceipt(ctx context.Context, reference string, format model.ReceiptFormat) (content []byte, err error) {
+	result, err := s.GetReceipt(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case model.ReceiptText:
+		content, err = receipt.Text(result)
+	case model.ReceiptESCPOS:
+		content, err = receipt.ESCPOS(result)
+	case model.ReceiptPDF:
+		content, err = receipt.PDF(result)
+	default:
+		return nil, cerr.New(http.StatusBadRequest, "format must be one of text, escpos or pdf")
+	}
+	if err != nil {
+		s.logger.Error("failed render receipt", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return content, nil
+}
+
+// maskCode only shows the last four characters of a gift card code
+func maskCode(code string) string {
+	if len(code) <= 4 {
+		return code
+	}
+	return "****" + code[len(code)-4:]
+}
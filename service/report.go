@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type ReportService interface {
+	GetStaffSales(ctx context.Context, params model.GetStaffSalesParam) (sales []model.StaffSales, err error)
+	RenderStaffSalesCSV(sales []model.StaffSales) (content []byte, err error)
+}
+
+type reportService struct {
+	repo   repo.ReportRepo
+	logger *zap.Logger
+}
+
+func NewReportService(r repo.ReportRepo, logger *zap.Logger) ReportService {
+	return &reportService{
+		repo:   r,
+		logger: logger,
+	}
+}
+
+func (s *reportService) GetStaffSales(ctx context.Context, params model.GetStaffSalesParam) (sales []model.StaffSales, err error) {
+	if params.To.Before(params.From) {
+		return nil, cerr.New(http.StatusBadRequest, "from must not be after to")
+	}
+
+	sales, err = s.repo.GetStaffSales(ctx, params)
+	if err != nil {
+		s.logger.Error("failed get staff sales", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	for i, sale := range sales {
+		if sale.Transactions > 0 {
+			sales[i].AverageBasket = sale.Revenue / sale.Transactions
+		}
+		sales[i].NetRevenue = sale.Revenue - sale.RefundAmount
+	}
+
+	return sales, nil
+}
+
+var staffSalesCSVHeader = []string{"staffId", "name", "transactions", "revenue", "itemsSold", "averageBasket", "refunds", "refundAmount", "netRevenue"}
+
+// RenderStaffSalesCSV writes the report with the same columns as the JSON
+// response, one row per staff member. Names are escaped so spreadsheets
+// don't run them as formulas.
+func (s *reportService) RenderStaffSalesCSV(sales []model.StaffSales) (content []byte, err error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	_ = w.Write(staffSalesCSVHeader)
+	for _, sale := range sales {
+		_ = w.Write([]string{
+			sale.StaffId.String(),
+			csvText(sale.Name),
+			strconv.Itoa(sale.Transactions),
+			strconv.Itoa(sale.Revenue),
+			strconv.Itoa(sale.ItemsSold),
+			strconv.Itoa(sale.AverageBasket),
+			strconv.Itoa(sale.Refunds),
+			strconv.Itoa(sale.RefundAmount),
+			strconv.Itoa(sale.NetRevenue),
+		})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		s.logger.Error("failed render staff sales csv", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// csvText prefixes text a spreadsheet would read as a formula with a quote
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"eniqilo-store/config"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type ReservationService interface {
+	Reserve(ctx context.Context, holderId uuid.UUID, products []model.ProductDetail) (err error)
+	Release(ctx context.Context, holderId uuid.UUID) (err error)
+	RunSweeper(ctx context.Context)
+}
+
+type reservationService struct {
+	cfg    *config.Config
+	repo   repo.ReservationRepo
+	logger *zap.Logger
+}
+
+func NewReservationService(cfg *config.Config, r repo.ReservationRepo, logger *zap.Logger) ReservationService {
+	return &reservationService{
+		cfg:    cfg,
+		repo:   r,
+		logger: logger,
+	}
+}
+
+// Reserve sets the products aside for the holder until the reservation
+// TTL passes. Earlier reservations of the same holder are replaced. Held
+// carts are the only holders: checkout is paid on the spot, there are no
+// orders waiting for payment to reserve for yet.
+func (s *reservationService) Reserve(ctx context.Context, holderId uuid.UUID, products []model.ProductDetail) (err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	err = s.repo.DeleteReservationsTx(ctx, tx, holderId)
+	if err != nil {
+		s.logger.Error("failed release reservations", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	productIDs := make([]string, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ProductId)
+	}
+
+	stocks, err := s.repo.LockProductStocks(ctx, tx, productIDs)
+	if err != nil {
+		s.logger.Error("failed lock product stocks", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	reserved, err := s.repo.GetReservedStocksTx(ctx, tx, productIDs, nil)
+	if err != nil {
+		s.logger.Error("failed get reserved stocks", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	for _, product := range products {
+		stock, ok := stocks[product.ProductId]
+		if !ok {
+			return cerr.New(http.StatusNotFound, "productId "+product.ProductId+" is not found")
+		}
+		if stock-reserved[product.ProductId] < product.Quantity {
+			return cerr.New(http.StatusBadRequest, `quantity product id `+product.ProductId+` is not enough`)
+		}
+
+		err = s.repo.CreateReservation(ctx, tx, model.StockReservation{
+			ProductId: product.ProductId,
+			HolderId:  holderId,
+			Quantity:  product.Quantity,
+		}, s.cfg.Reservation.TTL)
+		if err != nil {
+			s.logger.Error("failed create reservation", zap.Error(err))
+			return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+	}
+
+	return nil
+}
+
+func (s *reservationService) Release(ctx context.Context, holderId uuid.UUID) (err error) {
+	err = s.repo.DeleteReservations(ctx, holderId)
+	if err != nil {
+		s.logger.Error("failed release reservations", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+// RunSweeper deletes expired reservations every sweep interval until the
+// context is cancelled.
+func (s *reservationService) RunSweeper(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Reservation.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteExpiredReservations(ctx)
+			if err != nil {
+				s.logger.Error("failed sweep expired reservations", zap.Error(err))
+				continue
+			}
+			if deleted > 0 {
+				s.logger.Info("released expired reservations", zap.Int64("count", deleted))
+			}
+		}
+	}
+}
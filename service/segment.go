@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/config"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+type SegmentService interface {
+	CreateSegment(ctx context.Context, data model.CreateSegmentRequest) (segment model.Segment, err error)
+	GetSegments(ctx context.Context) (segments []model.Segment, err error)
+	GetSegment(ctx context.Context, id uuid.UUID) (segment model.Segment, err error)
+	DeleteSegment(ctx context.Context, id uuid.UUID) (err error)
+	Refresh(ctx context.Context) (err error)
+	RunScheduler(ctx context.Context)
+}
+
+type segmentService struct {
+	cfg    *config.Config
+	repo   repo.SegmentRepo
+	logger *zap.Logger
+}
+
+func NewSegmentService(cfg *config.Config, r repo.SegmentRepo, logger *zap.Logger) SegmentService {
+	return &segmentService{
+		cfg:    cfg,
+		repo:   r,
+		logger: logger,
+	}
+}
+
+// CreateSegment stores the segment and computes its members right away,
+// the segment is only kept when both succeed.
+func (s *segmentService) CreateSegment(ctx context.Context, data model.CreateSegmentRequest) (segment model.Segment, err error) {
+	for _, rule := range data.Rules {
+		if (rule.Type == model.RuleTotalSpend || rule.Type == model.RuleVisits) && rule.Min == nil && rule.Max == nil {
+			return model.Segment{}, cerr.New(http.StatusBadRequest, "rule "+string(rule.Type)+" needs min or max")
+		}
+	}
+
+	segment, err = s.createSegment(ctx, model.Segment{
+		Name:        *data.Name,
+		Description: data.Description,
+		Rules:       data.Rules,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return model.Segment{}, cerr.New(http.StatusConflict, "segment name already exists")
+	}
+	if err != nil {
+		s.logger.Error("failed create segment", zap.Error(err))
+		return model.Segment{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return s.GetSegment(ctx, segment.ID)
+}
+
+func (s *segmentService) createSegment(ctx context.Context, data model.Segment) (segment model.Segment, err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		return model.Segment{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	segment, err = s.repo.CreateSegment(ctx, tx, data)
+	if err != nil {
+		return model.Segment{}, err
+	}
+
+	_, err = s.repo.RefreshSegmentMembers(ctx, tx, segment)
+	return segment, err
+}
+
+func (s *segmentService) GetSegments(ctx context.Context) (segments []model.Segment, err error) {
+	segments, err = s.repo.GetSegments(ctx)
+	if err != nil {
+		s.logger.Error("failed get segments", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return segments, nil
+}
+
+func (s *segmentService) GetSegment(ctx context.Context, id uuid.UUID) (segment model.Segment, err error) {
+	segment, err = s.repo.GetSegmentById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Segment{}, cerr.New(http.StatusNotFound, "segment is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get segment", zap.Error(err))
+		return model.Segment{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return segment, nil
+}
+
+func (s *segmentService) DeleteSegment(ctx context.Context, id uuid.UUID) (err error) {
+	if _, err = s.GetSegment(ctx, id); err != nil {
+		return err
+	}
+
+	if err = s.repo.DeleteSegment(ctx, id); err != nil {
+		s.logger.Error("failed delete segment", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+// Refresh recomputes the customer tiers first, so segments with tier
+// rules see the new tiers, then the members of every segment.
+func (s *segmentService) Refresh(ctx context.Context) (err error) {
+	since := time.Now().Add(-s.cfg.Segment.TierWindow)
+	updated, err := s.repo.RecomputeTiers(ctx, since, s.cfg.Segment.SilverSpend, s.cfg.Segment.GoldSpend, s.cfg.Segment.PlatinumSpend)
+	if err != nil {
+		s.logger.Error("failed recompute tiers", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	s.logger.Info("recomputed customer tiers", zap.Int64("customers", updated))
+
+	segments, err := s.repo.GetSegments(ctx)
+	if err != nil {
+		s.logger.Error("failed get segments", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	for _, segment := range segments {
+		if err := s.refreshSegment(ctx, segment); err != nil {
+			// one broken segment shouldn't hold back the others
+			s.logger.Error("failed refresh segment", zap.Error(err), zap.String("segment", segment.Name))
+		}
+	}
+
+	return nil
+}
+
+func (s *segmentService) refreshSegment(ctx context.Context, segment model.Segment) (err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	_, err = s.repo.RefreshSegmentMembers(ctx, tx, segment)
+	return err
+}
+
+// RunScheduler refreshes tiers and segments every refresh interval until
+// the context is cancelled.
+func (s *segmentService) RunScheduler(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Segment.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.Refresh(ctx)
+		}
+	}
+}
@@ -1,34 +1,77 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"eniqilo-store/config"
 	"eniqilo-store/model"
 	"eniqilo-store/pkg/crypto"
 	"eniqilo-store/pkg/customErr"
 	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 )
 
 type StaffService interface {
-	Register(newStaff model.Staff) (model.StaffWithToken, error)
-	Login(loginReq model.LoginStaffRequest) (model.StaffWithToken, error)
+	Register(ctx context.Context, newStaff model.Staff, inviteCode string) (model.StaffWithToken, error)
+	Login(ctx context.Context, loginReq model.LoginStaffRequest, ip string) (model.StaffWithToken, error)
+	PinLogin(ctx context.Context, loginReq model.PinLoginRequest, deviceId, ip string) (model.StaffWithToken, error)
+	SetPin(ctx context.Context, id uuid.UUID, data model.SetPinRequest) (err error)
+	LoginTwoFactor(ctx context.Context, data model.TwoFactorLoginRequest, ip string) (model.StaffWithToken, error)
+	EnrolTwoFactor(ctx context.Context, id uuid.UUID, data model.EnrolTwoFactorRequest) (enrolment model.TwoFactorEnrolment, err error)
+	ConfirmTwoFactor(ctx context.Context, id uuid.UUID, data model.ConfirmTwoFactorRequest) (codes model.RecoveryCodes, err error)
+	DisableTwoFactor(ctx context.Context, id uuid.UUID, data model.DisableTwoFactorRequest) (err error)
+	GetStaffList(ctx context.Context, params model.GetStaffParam) (staff []model.Staff, err error)
+	GetStaff(ctx context.Context, id uuid.UUID) (staff model.Staff, err error)
+	UpdateRole(ctx context.Context, adminId, id uuid.UUID, role model.StaffRole) (staff model.Staff, err error)
+	SetDeactivated(ctx context.Context, adminId, id uuid.UUID, deactivated bool) (staff model.Staff, err error)
+	CreateInvite(ctx context.Context, adminId uuid.UUID, role model.StaffRole) (invite model.StaffInvite, err error)
+	ChangePassword(ctx context.Context, id uuid.UUID, data model.ChangePasswordRequest) (staff model.StaffWithToken, err error)
+	IssuePasswordReset(ctx context.Context, adminId, id uuid.UUID) (reset model.PasswordReset, err error)
+	ResetPassword(ctx context.Context, data model.ResetPasswordRequest) (err error)
 }
 
 type staffSvc struct {
-	cfg  *config.Config
-	repo repo.StaffRepo
+	cfg          *config.Config
+	keys         *crypto.KeySet
+	secrets      *crypto.SecretBox
+	repo         repo.StaffRepo
+	attemptRepo  repo.LoginAttemptRepo
+	terminalRepo repo.TerminalRepo
+	auditRepo    repo.AuditRepo
+	logger       *zap.Logger
+	dummyOnce    sync.Once
+	dummyHash    string
 }
 
-func NewStaffService(cfg *config.Config, r repo.StaffRepo) StaffService {
+func NewStaffService(cfg *config.Config, keys *crypto.KeySet, secrets *crypto.SecretBox, r repo.StaffRepo, attemptRepo repo.LoginAttemptRepo, terminalRepo repo.TerminalRepo, auditRepo repo.AuditRepo, logger *zap.Logger) StaffService {
 	return &staffSvc{
-		cfg:  cfg,
-		repo: r,
+		cfg:          cfg,
+		keys:         keys,
+		secrets:      secrets,
+		repo:         r,
+		attemptRepo:  attemptRepo,
+		terminalRepo: terminalRepo,
+		auditRepo:    auditRepo,
+		logger:       logger,
 	}
 }
 
-func (s *staffSvc) Register(newStaff model.Staff) (model.StaffWithToken, error) {
+// Register creates a staff account with the role of its invite. While
+// there is no staff at all the first one registers without an invite and
+// becomes the admin.
+func (s *staffSvc) Register(ctx context.Context, newStaff model.Staff, inviteCode string) (model.StaffWithToken, error) {
 	existingData, err := s.repo.GetStaff(newStaff.PhoneNumber)
 
 	if err != nil && err != sql.ErrNoRows {
@@ -39,6 +82,10 @@ func (s *staffSvc) Register(newStaff model.Staff) (model.StaffWithToken, error)
 		return model.StaffWithToken{}, customErr.NewConflictError("User already exist")
 	}
 
+	if err := checkPasswordPolicy(s.cfg.Staff.Password, newStaff.Password); err != nil {
+		return model.StaffWithToken{}, customErr.NewBadRequestError(err.Error())
+	}
+
 	hashedPassword, err := crypto.GenerateHashedPassword(newStaff.Password, s.cfg.BcryptSalt)
 	if err != nil {
 		return model.StaffWithToken{}, err
@@ -47,12 +94,12 @@ func (s *staffSvc) Register(newStaff model.Staff) (model.StaffWithToken, error)
 	id := uuid.New()
 	newStaff.UserId = id
 
-	err = s.repo.CreateStaff(newStaff, hashedPassword)
+	err = s.createStaff(ctx, &newStaff, hashedPassword, inviteCode)
 	if err != nil {
 		return model.StaffWithToken{}, err
 	}
 
-	token, err := crypto.GenerateToken(id, newStaff.PhoneNumber, newStaff.Name, s.cfg.JWTSecret)
+	token, err := crypto.GenerateToken(id, newStaff.PhoneNumber, newStaff.Name, 0, s.keys)
 	if err != nil {
 		return model.StaffWithToken{}, err
 	}
@@ -60,24 +107,120 @@ func (s *staffSvc) Register(newStaff model.Staff) (model.StaffWithToken, error)
 	serviceResponse := model.
 		StaffWithToken{
 		UserId:      id.String(),
+		Role:        newStaff.Role,
 		AccessToken: token,
 	}
 
 	return serviceResponse, err
 }
 
-func (s *staffSvc) Login(loginReq model.LoginStaffRequest) (model.StaffWithToken, error) {
+func (s *staffSvc) createStaff(ctx context.Context, newStaff *model.Staff, hashedPassword, inviteCode string) (err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		return customErr.NewInternalServerError("Internal server error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	count, err := s.repo.CountStaffLocked(ctx, tx)
+	if err != nil {
+		return customErr.NewInternalServerError("Internal server error")
+	}
+
+	if count == 0 {
+		newStaff.Role = model.RoleAdmin
+	} else {
+		if inviteCode == "" {
+			return customErr.NewForbiddenError("Invite code is required")
+		}
+
+		invite, err := s.repo.UseInvite(ctx, tx, crypto.HashToken(inviteCode), newStaff.UserId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return customErr.NewForbiddenError("Invite code is invalid or expired")
+		}
+		if err != nil {
+			return customErr.NewInternalServerError("Internal server error")
+		}
+		newStaff.Role = invite.Role
+	}
+
+	if err = s.repo.CreateStaff(ctx, tx, *newStaff, hashedPassword); err != nil {
+		return err
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, selfAuditEntry(ctx, model.AuditStaffRegister, newStaff.UserId, newStaff.Name, nil, newStaff))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return customErr.NewInternalServerError("Internal server error")
+	}
+
+	return nil
+}
+
+// selfAuditEntry is for the public staff endpoints, where the staff member
+// acting on their own account is the actor.
+func selfAuditEntry(ctx context.Context, action model.AuditAction, id uuid.UUID, name string, before, after interface{}) model.AuditEntry {
+	entry := newAuditEntry(ctx, action, model.AuditStaff, id.String(), before, after)
+	entry.ActorType, entry.ActorId, entry.ActorName = model.AuditActorStaff, &entry.EntityId, name
+	return entry
+}
+
+// Login answers the same way for unknown phone numbers and wrong
+// passwords. Attempts are counted per phone number and per client ip,
+// see config.LoginPolicy for how failures slow down further attempts.
+func (s *staffSvc) Login(ctx context.Context, loginReq model.LoginStaffRequest, ip string) (model.StaffWithToken, error) {
+	phoneKey, ipKey := "phone:"+loginReq.PhoneNumber, "ip:"+ip
+
+	if err := s.reserveLogin(ctx, phoneKey, ipKey); err != nil {
+		return model.StaffWithToken{}, err
+	}
+
 	user, err := s.repo.GetStaff(loginReq.PhoneNumber)
 	if err != nil && err != sql.ErrNoRows {
 		return model.StaffWithToken{}, customErr.NewInternalServerError("Internal server error")
 	}
 
-	err = crypto.VerifyPassword(loginReq.Password, user.Password)
-	if err != nil {
+	// unknown numbers still pay for a bcrypt comparison, so the response
+	// time doesn't give away which numbers are registered
+	hashedPassword := s.dummyPasswordHash()
+	if user != nil {
+		hashedPassword = user.Password
+	}
+
+	err = crypto.VerifyPassword(loginReq.Password, hashedPassword)
+	if err != nil || user == nil {
 		return model.StaffWithToken{}, customErr.NewBadRequestError("Invalid phone or password")
 	}
 
-	token, err := crypto.GenerateToken(user.UserId, user.PhoneNumber, user.Name, s.cfg.JWTSecret)
+	s.releaseLogin(ctx, phoneKey, ipKey)
+
+	if user.DeactivatedAt != nil {
+		return model.StaffWithToken{}, customErr.NewForbiddenError("Account is deactivated")
+	}
+
+	// the access token is only handed out by LoginTwoFactor
+	if user.TotpEnabledAt != nil {
+		challenge, err := crypto.GenerateChallengeToken(user.UserId, user.TokenVersion, s.cfg.Staff.TwoFactor.ChallengeTTL, s.keys)
+		if err != nil {
+			return model.StaffWithToken{}, customErr.NewBadRequestError(err.Error())
+		}
+
+		return model.StaffWithToken{
+			UserId:            user.UserId.String(),
+			Name:              user.Name,
+			PhoneNumber:       user.PhoneNumber,
+			Role:              user.Role,
+			ChallengeToken:    challenge,
+			TwoFactorRequired: true,
+		}, nil
+	}
+
+	token, err := crypto.GenerateToken(user.UserId, user.PhoneNumber, user.Name, user.TokenVersion, s.keys)
 	if err != nil {
 		return model.StaffWithToken{}, customErr.NewBadRequestError(err.Error())
 	}
@@ -86,8 +229,613 @@ func (s *staffSvc) Login(loginReq model.LoginStaffRequest) (model.StaffWithToken
 		UserId:      user.UserId.String(),
 		Name:        user.Name,
 		PhoneNumber: user.PhoneNumber,
+		Role:        user.Role,
 		AccessToken: token,
 	}
 
 	return serviceResponse, nil
 }
+
+// PinLogin signs a cashier in on a registered terminal. The token it
+// issues is short lived, bound to the terminal and not accepted for
+// manager operations. Failures are limited per phone number and per
+// terminal just like password logins. A pin is only one factor, so staff
+// with two-factor enabled or required for their role have to log in with
+// their password.
+func (s *staffSvc) PinLogin(ctx context.Context, loginReq model.PinLoginRequest, deviceId, ip string) (model.StaffWithToken, error) {
+	if deviceId == "" {
+		return model.StaffWithToken{}, customErr.NewUnauthorizedError("Terminal is not registered")
+	}
+
+	terminal, err := s.terminalRepo.GetActiveTerminalByDevice(ctx, crypto.HashToken(deviceId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.StaffWithToken{}, customErr.NewUnauthorizedError("Terminal is not registered")
+	}
+	if err != nil {
+		s.logger.Error("failed get terminal", zap.Error(err))
+		return model.StaffWithToken{}, customErr.NewInternalServerError("Internal server error")
+	}
+
+	pinKey, terminalKey := "pin:"+loginReq.PhoneNumber, "terminal:"+terminal.ID.String()
+
+	if err := s.reserveLogin(ctx, pinKey, terminalKey, "ip:"+ip); err != nil {
+		return model.StaffWithToken{}, err
+	}
+
+	user, err := s.repo.GetStaff(loginReq.PhoneNumber)
+	if err != nil && err != sql.ErrNoRows {
+		return model.StaffWithToken{}, customErr.NewInternalServerError("Internal server error")
+	}
+
+	hashedPin := s.dummyPasswordHash()
+	if user != nil && user.PinHash != nil {
+		hashedPin = *user.PinHash
+	}
+
+	err = crypto.VerifyPassword(loginReq.Pin, hashedPin)
+	if err != nil || user == nil || user.PinHash == nil {
+		return model.StaffWithToken{}, customErr.NewBadRequestError("Invalid phone or pin")
+	}
+
+	s.releaseLogin(ctx, pinKey, terminalKey)
+
+	if user.DeactivatedAt != nil {
+		return model.StaffWithToken{}, customErr.NewForbiddenError("Account is deactivated")
+	}
+
+	if user.TotpEnabledAt != nil || s.cfg.Staff.TwoFactor.Requires(string(user.Role)) {
+		return model.StaffWithToken{}, customErr.NewForbiddenError("Pin login is not available with two-factor authentication, log in with your password")
+	}
+
+	token, err := crypto.GenerateTerminalToken(user.UserId, user.PhoneNumber, user.Name, user.TokenVersion, terminal.ID, s.cfg.Staff.PinTokenTTL, s.keys)
+	if err != nil {
+		return model.StaffWithToken{}, customErr.NewBadRequestError(err.Error())
+	}
+
+	if err := s.terminalRepo.TouchTerminal(ctx, terminal.ID); err != nil {
+		s.logger.Error("failed touch terminal", zap.Error(err))
+	}
+
+	return model.StaffWithToken{
+		UserId:      user.UserId.String(),
+		Name:        user.Name,
+		PhoneNumber: user.PhoneNumber,
+		Role:        user.Role,
+		AccessToken: token,
+	}, nil
+}
+
+// SetPin sets the pin used on terminals, it is hashed like a password.
+func (s *staffSvc) SetPin(ctx context.Context, id uuid.UUID, data model.SetPinRequest) (err error) {
+	staff, err := s.GetStaff(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if crypto.VerifyPassword(*data.Password, staff.Password) != nil {
+		return cerr.New(http.StatusBadRequest, "password is wrong")
+	}
+
+	if isTrivialPin(*data.Pin) {
+		return cerr.New(http.StatusBadRequest, "pin is too easy to guess")
+	}
+
+	hashedPin, err := crypto.GenerateHashedPassword(*data.Pin, s.cfg.BcryptSalt)
+	if err != nil {
+		s.logger.Error("failed hash pin", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if err = s.repo.UpdatePin(ctx, tx, id, hashedPin); err != nil {
+		s.logger.Error("failed update pin", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditStaffPinSet, model.AuditStaff, id.String(), nil, nil))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+// isTrivialPin rejects repeated digits like 0000 and runs like 1234 or 4321
+func isTrivialPin(pin string) bool {
+	repeated, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		diff := int(pin[i]) - int(pin[i-1])
+		repeated = repeated && diff == 0
+		ascending = ascending && diff == 1
+		descending = descending && diff == -1
+	}
+
+	return repeated || ascending || descending
+}
+
+// reserveLogin counts the attempt as a failure of the account (phone
+// number) and of where it came from (client ip or terminal) before the
+// credentials are checked, and sets the delay or lockout it earns right
+// away. The rows stay locked until both are counted, so parallel guesses
+// queue up and see each other's locks instead of all passing the check.
+// checkKeys are only checked for a lock. A successful login takes the
+// attempt back with releaseLogin.
+func (s *staffSvc) reserveLogin(ctx context.Context, accountKey, sourceKey string, checkKeys ...string) error {
+	policy := s.cfg.Staff.Login
+
+	if len(checkKeys) > 0 {
+		remaining, err := s.attemptRepo.GetLockRemaining(ctx, checkKeys)
+		if err != nil {
+			s.logger.Error("failed get login lock", zap.Error(err))
+			return customErr.NewInternalServerError("Internal server error")
+		}
+		if remaining > 0 {
+			return tooManyLoginAttempts(remaining)
+		}
+	}
+
+	tx, err := s.attemptRepo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return customErr.NewInternalServerError("Internal server error")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	attempts, remaining, err := s.attemptRepo.ReserveAttempt(ctx, tx, accountKey, policy.Window)
+	if err != nil {
+		s.logger.Error("failed reserve login attempt", zap.Error(err))
+		return customErr.NewInternalServerError("Internal server error")
+	}
+	if remaining > 0 {
+		return tooManyLoginAttempts(remaining)
+	}
+
+	switch {
+	case attempts >= policy.LockoutThreshold:
+		err = s.lockLogin(ctx, tx, accountKey, attempts, policy.LockoutDuration)
+	case attempts > policy.FreeAttempts:
+		delay := policy.MaxDelay
+		if shift := attempts - policy.FreeAttempts - 1; shift < 32 && policy.BaseDelay<<shift < policy.MaxDelay {
+			delay = policy.BaseDelay << shift
+		}
+		err = s.attemptRepo.Lock(ctx, tx, accountKey, delay)
+	}
+	if err != nil {
+		s.logger.Error("failed delay login", zap.Error(err))
+		return customErr.NewInternalServerError("Internal server error")
+	}
+
+	attempts, remaining, err = s.attemptRepo.ReserveAttempt(ctx, tx, sourceKey, policy.Window)
+	if err != nil {
+		s.logger.Error("failed reserve login attempt", zap.Error(err))
+		return customErr.NewInternalServerError("Internal server error")
+	}
+	if remaining > 0 {
+		return tooManyLoginAttempts(remaining)
+	}
+
+	if attempts >= policy.IPLockoutThreshold {
+		if err := s.lockLogin(ctx, tx, sourceKey, attempts, policy.LockoutDuration); err != nil {
+			s.logger.Error("failed lock login", zap.Error(err))
+			return customErr.NewInternalServerError("Internal server error")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("failed commit login attempt", zap.Error(err))
+		return customErr.NewInternalServerError("Internal server error")
+	}
+
+	return nil
+}
+
+// releaseLogin forgets the failures of the account and takes the attempt
+// reserved for the source back after a successful login.
+func (s *staffSvc) releaseLogin(ctx context.Context, accountKey, sourceKey string) {
+	if err := s.attemptRepo.Reset(ctx, accountKey); err != nil {
+		s.logger.Error("failed reset login attempts", zap.Error(err))
+	}
+	if err := s.attemptRepo.Release(ctx, sourceKey); err != nil {
+		s.logger.Error("failed release login attempt", zap.Error(err))
+	}
+}
+
+func (s *staffSvc) lockLogin(ctx context.Context, tx *sqlx.Tx, key string, attempts int, duration time.Duration) error {
+	if err := s.attemptRepo.Lock(ctx, tx, key, duration); err != nil {
+		return err
+	}
+
+	s.logger.Warn("login locked out",
+		zap.String("key", key),
+		zap.Int("failures", attempts),
+		zap.Duration("duration", duration),
+	)
+	return nil
+}
+
+func tooManyLoginAttempts(remaining time.Duration) customErr.CustomError {
+	seconds := strconv.Itoa(int(math.Ceil(remaining.Seconds())))
+	return customErr.NewTooManyRequestsError("Too many login attempts, try again in " + seconds + " seconds")
+}
+
+// dummyPasswordHash is hashed with the configured cost, so checking it
+// takes as long as checking a real password.
+func (s *staffSvc) dummyPasswordHash() string {
+	s.dummyOnce.Do(func() {
+		token, _ := crypto.GenerateRandomToken(16)
+		s.dummyHash, _ = crypto.GenerateHashedPassword(token, s.cfg.BcryptSalt)
+	})
+
+	return s.dummyHash
+}
+
+func (s *staffSvc) GetStaffList(ctx context.Context, params model.GetStaffParam) (staff []model.Staff, err error) {
+	staff, err = s.repo.GetStaffList(ctx, params)
+	if err != nil {
+		s.logger.Error("failed get staff list", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return staff, nil
+}
+
+// getStaffForUpdate is GetStaff with the row locked until tx ends
+func (s *staffSvc) getStaffForUpdate(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (staff model.Staff, err error) {
+	staff, err = s.repo.GetStaffByIdForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Staff{}, cerr.New(http.StatusNotFound, "staff is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get staff", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return staff, nil
+}
+
+func (s *staffSvc) GetStaff(ctx context.Context, id uuid.UUID) (staff model.Staff, err error) {
+	staff, err = s.repo.GetStaffById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Staff{}, cerr.New(http.StatusNotFound, "staff is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get staff", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return staff, nil
+}
+
+// UpdateRole changes the role of another staff member. Admins can't change
+// their own role, so there is always an admin left.
+func (s *staffSvc) UpdateRole(ctx context.Context, adminId, id uuid.UUID, role model.StaffRole) (staff model.Staff, err error) {
+	if adminId == id {
+		return model.Staff{}, cerr.New(http.StatusBadRequest, "you can't change your own role")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	before, err := s.getStaffForUpdate(ctx, tx, id)
+	if err != nil {
+		return model.Staff{}, err
+	}
+
+	staff, err = s.repo.UpdateStaffRole(ctx, tx, id, role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Staff{}, cerr.New(http.StatusNotFound, "staff is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed update staff role", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditStaffRoleChange, model.AuditStaff, id.String(), before, staff))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return staff, nil
+}
+
+// SetDeactivated blocks or unblocks an account, a deactivated staff can't
+// log in and its tokens are rejected.
+func (s *staffSvc) SetDeactivated(ctx context.Context, adminId, id uuid.UUID, deactivated bool) (staff model.Staff, err error) {
+	if adminId == id {
+		return model.Staff{}, cerr.New(http.StatusBadRequest, "you can't deactivate your own account")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	before, err := s.getStaffForUpdate(ctx, tx, id)
+	if err != nil {
+		return model.Staff{}, err
+	}
+
+	staff, err = s.repo.SetStaffDeactivated(ctx, tx, id, deactivated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Staff{}, cerr.New(http.StatusNotFound, "staff is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed update staff status", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	action := model.AuditStaffReactivate
+	if deactivated {
+		action = model.AuditStaffDeactivate
+	}
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, action, model.AuditStaff, id.String(), before, staff))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return staff, nil
+}
+
+func (s *staffSvc) CreateInvite(ctx context.Context, adminId uuid.UUID, role model.StaffRole) (invite model.StaffInvite, err error) {
+	code, err := crypto.GenerateRandomToken(16)
+	if err != nil {
+		s.logger.Error("failed generate invite code", zap.Error(err))
+		return model.StaffInvite{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.StaffInvite{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	invite, err = s.repo.CreateInvite(ctx, tx, model.StaffInvite{
+		CodeHash:  crypto.HashToken(code),
+		Role:      role,
+		CreatedBy: adminId,
+		ExpiresAt: time.Now().Add(s.cfg.Staff.InviteTTL),
+	})
+	if err != nil {
+		s.logger.Error("failed create invite", zap.Error(err))
+		return model.StaffInvite{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	// logged before the code is filled in, it must not end up in the log
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditStaffInvite, model.AuditStaff, invite.ID.String(), nil, invite))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.StaffInvite{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	invite.Code = code
+
+	return invite, nil
+}
+
+// ChangePassword sets a new password for the staff itself. Every token
+// issued before is revoked, the caller gets a fresh one back.
+func (s *staffSvc) ChangePassword(ctx context.Context, id uuid.UUID, data model.ChangePasswordRequest) (result model.StaffWithToken, err error) {
+	staff, err := s.GetStaff(ctx, id)
+	if err != nil {
+		return model.StaffWithToken{}, err
+	}
+
+	if crypto.VerifyPassword(*data.CurrentPassword, staff.Password) != nil {
+		return model.StaffWithToken{}, cerr.New(http.StatusBadRequest, "current password is wrong")
+	}
+
+	if *data.NewPassword == *data.CurrentPassword {
+		return model.StaffWithToken{}, cerr.New(http.StatusBadRequest, "new password must be different from the current one")
+	}
+
+	staff, err = s.setPassword(ctx, id, *data.NewPassword, "")
+	if err != nil {
+		return model.StaffWithToken{}, err
+	}
+
+	token, err := crypto.GenerateToken(staff.UserId, staff.PhoneNumber, staff.Name, staff.TokenVersion, s.keys)
+	if err != nil {
+		s.logger.Error("failed generate token", zap.Error(err))
+		return model.StaffWithToken{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return model.StaffWithToken{
+		UserId:      staff.UserId.String(),
+		Name:        staff.Name,
+		PhoneNumber: staff.PhoneNumber,
+		Role:        staff.Role,
+		AccessToken: token,
+	}, nil
+}
+
+// IssuePasswordReset creates a one-time token the admin hands to a staff
+// member who forgot their password.
+func (s *staffSvc) IssuePasswordReset(ctx context.Context, adminId, id uuid.UUID) (reset model.PasswordReset, err error) {
+	if _, err = s.GetStaff(ctx, id); err != nil {
+		return model.PasswordReset{}, err
+	}
+
+	token, err := crypto.GenerateRandomToken(16)
+	if err != nil {
+		s.logger.Error("failed generate reset token", zap.Error(err))
+		return model.PasswordReset{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.PasswordReset{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	reset, err = s.repo.CreatePasswordReset(ctx, tx, model.PasswordReset{
+		StaffId:   id,
+		TokenHash: crypto.HashToken(token),
+		CreatedBy: adminId,
+		ExpiresAt: time.Now().Add(s.cfg.Staff.ResetTokenTTL),
+	})
+	if err != nil {
+		s.logger.Error("failed create password reset", zap.Error(err))
+		return model.PasswordReset{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	// logged before the token is filled in, it must not end up in the log
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditStaffResetIssue, model.AuditStaff, id.String(), nil, reset))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.PasswordReset{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	reset.Token = token
+
+	return reset, nil
+}
+
+func (s *staffSvc) ResetPassword(ctx context.Context, data model.ResetPasswordRequest) (err error) {
+	_, err = s.setPassword(ctx, uuid.Nil, *data.NewPassword, *data.Token)
+	return err
+}
+
+// setPassword stores a new password for the staff, or for the owner of
+// resetToken when one is given, and revokes its tokens.
+func (s *staffSvc) setPassword(ctx context.Context, id uuid.UUID, password, resetToken string) (staff model.Staff, err error) {
+	if err := checkPasswordPolicy(s.cfg.Staff.Password, password); err != nil {
+		return model.Staff{}, cerr.New(http.StatusBadRequest, err.Error())
+	}
+
+	hashedPassword, err := crypto.GenerateHashedPassword(password, s.cfg.BcryptSalt)
+	if err != nil {
+		s.logger.Error("failed hash password", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if resetToken != "" {
+		reset, err := s.repo.UsePasswordReset(ctx, tx, crypto.HashToken(resetToken))
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Staff{}, cerr.New(http.StatusBadRequest, "reset token is invalid or expired")
+		}
+		if err != nil {
+			s.logger.Error("failed use password reset", zap.Error(err))
+			return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+		id = reset.StaffId
+	}
+
+	staff, err = s.repo.UpdatePassword(ctx, tx, id, hashedPassword)
+	if err != nil {
+		s.logger.Error("failed update password", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	entry := newAuditEntry(ctx, model.AuditStaffPasswordChange, model.AuditStaff, id.String(), nil, nil)
+	if resetToken != "" {
+		entry = selfAuditEntry(ctx, model.AuditStaffPasswordReset, id, staff.Name, nil, nil)
+	}
+	if err = s.auditRepo.CreateEntryTx(ctx, tx, entry); err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Staff{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return staff, nil
+}
+
+// checkPasswordPolicy tells everything that is wrong with password at once
+func checkPasswordPolicy(policy config.PasswordPolicy, password string) error {
+	var (
+		problems                      []string
+		hasUpper, hasDigit, hasSymbol bool
+	)
+
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	maxLength := policy.MaxLength
+	if maxLength <= 0 || maxLength > 72 {
+		maxLength = 72
+	}
+
+	if len([]rune(password)) < policy.MinLength {
+		problems = append(problems, "be at least "+strconv.Itoa(policy.MinLength)+" characters long")
+	}
+	if len(password) > maxLength {
+		problems = append(problems, "be at most "+strconv.Itoa(maxLength)+" bytes long")
+	}
+	if policy.RequireUpper && !hasUpper {
+		problems = append(problems, "contain an uppercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		problems = append(problems, "contain a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		problems = append(problems, "contain a symbol")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("password must " + strings.Join(problems, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/crypto"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type TerminalService interface {
+	RegisterTerminal(ctx context.Context, staffId uuid.UUID, data model.RegisterTerminalRequest) (terminal model.Terminal, err error)
+	GetTerminals(ctx context.Context) (terminals []model.Terminal, err error)
+	RevokeTerminal(ctx context.Context, id uuid.UUID) (terminal model.Terminal, err error)
+}
+
+type terminalService struct {
+	repo      repo.TerminalRepo
+	auditRepo repo.AuditRepo
+	logger    *zap.Logger
+}
+
+func NewTerminalService(r repo.TerminalRepo, auditRepo repo.AuditRepo, logger *zap.Logger) TerminalService {
+	return &terminalService{
+		repo:      r,
+		auditRepo: auditRepo,
+		logger:    logger,
+	}
+}
+
+// RegisterTerminal hands out the device id the till has to send with pin
+// logins, it can't be looked up again afterwards.
+func (s *terminalService) RegisterTerminal(ctx context.Context, staffId uuid.UUID, data model.RegisterTerminalRequest) (terminal model.Terminal, err error) {
+	deviceId, err := crypto.GenerateRandomToken(32)
+	if err != nil {
+		s.logger.Error("failed generate device id", zap.Error(err))
+		return model.Terminal{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Terminal{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	terminal, err = s.repo.CreateTerminal(ctx, tx, model.Terminal{
+		Name:       *data.Name,
+		DeviceHash: crypto.HashToken(deviceId),
+		CreatedBy:  staffId,
+	})
+	if err != nil {
+		s.logger.Error("failed create terminal", zap.Error(err))
+		return model.Terminal{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditTerminalRegister, model.AuditTerminal, terminal.ID.String(), nil, terminal))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Terminal{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	terminal.DeviceId = deviceId
+
+	return terminal, nil
+}
+
+func (s *terminalService) GetTerminals(ctx context.Context) (terminals []model.Terminal, err error) {
+	terminals, err = s.repo.GetTerminals(ctx)
+	if err != nil {
+		s.logger.Error("failed get terminals", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return terminals, nil
+}
+
+// RevokeTerminal stops pin logins from the terminal, tokens it already got
+// are rejected too.
+func (s *terminalService) RevokeTerminal(ctx context.Context, id uuid.UUID) (terminal model.Terminal, err error) {
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.Terminal{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	terminal, err = s.repo.RevokeTerminal(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Terminal{}, cerr.New(http.StatusNotFound, "terminal is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed revoke terminal", zap.Error(err))
+		return model.Terminal{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditTerminalRevoke, model.AuditTerminal, terminal.ID.String(), nil, terminal))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.Terminal{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return terminal, nil
+}
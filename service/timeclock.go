@@ -0,0 +1,440 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+	"eniqilo-store/repo"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// maxShiftLength is the longest shift a manager can enter, anything longer
+// is most likely a typo in the date
+const maxShiftLength = 24 * time.Hour
+
+type TimeClockService interface {
+	ClockIn(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error)
+	ClockOut(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error)
+	StartBreak(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error)
+	EndBreak(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error)
+	GetCurrentEntry(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error)
+	GetEntries(ctx context.Context, params model.GetTimeEntryParam) (entries []model.TimeEntry, meta model.PageMeta, err error)
+	CreateEntry(ctx context.Context, managerId uuid.UUID, data model.TimeEntryRequest) (entry model.TimeEntry, err error)
+	CorrectEntry(ctx context.Context, managerId uuid.UUID, id uuid.UUID, data model.TimeEntryRequest) (entry model.TimeEntry, err error)
+	GetAttendance(ctx context.Context, params model.GetAttendanceParam) (attendance []model.StaffAttendance, err error)
+}
+
+type timeClockService struct {
+	repo      repo.TimeClockRepo
+	staffRepo repo.StaffRepo
+	auditRepo repo.AuditRepo
+	logger    *zap.Logger
+}
+
+func NewTimeClockService(r repo.TimeClockRepo, staffRepo repo.StaffRepo, auditRepo repo.AuditRepo, logger *zap.Logger) TimeClockService {
+	return &timeClockService{
+		repo:      r,
+		staffRepo: staffRepo,
+		auditRepo: auditRepo,
+		logger:    logger,
+	}
+}
+
+func (s *timeClockService) ClockIn(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error) {
+	entry, err = s.repo.ClockIn(ctx, staffId)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return model.TimeEntry{}, cerr.New(http.StatusConflict, "you are already clocked in")
+	}
+	if err != nil {
+		s.logger.Error("failed clock in", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	entry.Breaks = []model.TimeBreak{}
+
+	return entry, nil
+}
+
+// ClockOut ends the running shift, a running break ends with it
+func (s *timeClockService) ClockOut(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error) {
+	return s.updateOpenEntry(ctx, staffId, func(tx *sqlx.Tx, entry model.TimeEntry) (model.TimeEntry, error) {
+		err := s.repo.EndBreak(ctx, tx, entry.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Error("failed end break", zap.Error(err))
+			return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+
+		entry, err = s.repo.ClockOut(ctx, tx, entry.ID)
+		if err != nil {
+			s.logger.Error("failed clock out", zap.Error(err))
+			return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+
+		return entry, nil
+	})
+}
+
+func (s *timeClockService) StartBreak(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error) {
+	return s.updateOpenEntry(ctx, staffId, func(tx *sqlx.Tx, entry model.TimeEntry) (model.TimeEntry, error) {
+		err := s.repo.StartBreak(ctx, tx, entry.ID)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return model.TimeEntry{}, cerr.New(http.StatusConflict, "you are already on a break")
+		}
+		if err != nil {
+			s.logger.Error("failed start break", zap.Error(err))
+			return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+
+		return entry, nil
+	})
+}
+
+func (s *timeClockService) EndBreak(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error) {
+	return s.updateOpenEntry(ctx, staffId, func(tx *sqlx.Tx, entry model.TimeEntry) (model.TimeEntry, error) {
+		err := s.repo.EndBreak(ctx, tx, entry.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.TimeEntry{}, cerr.New(http.StatusConflict, "you are not on a break")
+		}
+		if err != nil {
+			s.logger.Error("failed end break", zap.Error(err))
+			return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+
+		return entry, nil
+	})
+}
+
+// updateOpenEntry runs update on the locked running shift of the staff and
+// returns the shift with its breaks once the change is committed.
+func (s *timeClockService) updateOpenEntry(ctx context.Context, staffId uuid.UUID, update func(tx *sqlx.Tx, entry model.TimeEntry) (model.TimeEntry, error)) (model.TimeEntry, error) {
+	entry, err := func() (entry model.TimeEntry, err error) {
+		tx, err := s.repo.NewTx()
+		if err != nil {
+			s.logger.Error("failed begin tx", zap.Error(err))
+			return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+		defer func() {
+			if err != nil {
+				_ = tx.Rollback()
+				return
+			}
+			err = tx.Commit()
+		}()
+
+		entry, err = s.repo.GetOpenEntryForUpdate(ctx, tx, staffId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.TimeEntry{}, cerr.New(http.StatusConflict, "you are not clocked in")
+		}
+		if err != nil {
+			s.logger.Error("failed get time entry", zap.Error(err))
+			return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+
+		return update(tx, entry)
+	}()
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+
+	return s.withBreaks(ctx, entry)
+}
+
+func (s *timeClockService) GetCurrentEntry(ctx context.Context, staffId uuid.UUID) (entry model.TimeEntry, err error) {
+	entry, err = s.repo.GetOpenEntry(ctx, staffId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TimeEntry{}, cerr.New(http.StatusNotFound, "you are not clocked in")
+	}
+	if err != nil {
+		s.logger.Error("failed get time entry", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return s.withBreaks(ctx, entry)
+}
+
+func (s *timeClockService) withBreaks(ctx context.Context, entry model.TimeEntry) (model.TimeEntry, error) {
+	breaks, err := s.repo.GetBreaks(ctx, []uuid.UUID{entry.ID})
+	if err != nil {
+		s.logger.Error("failed get breaks", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	entry.Breaks = breaks[entry.ID]
+	if entry.Breaks == nil {
+		entry.Breaks = []model.TimeBreak{}
+	}
+	return entry, nil
+}
+
+func (s *timeClockService) GetEntries(ctx context.Context, params model.GetTimeEntryParam) (entries []model.TimeEntry, meta model.PageMeta, err error) {
+	if params.Limit <= 0 || params.Limit > 100 {
+		params.Limit = 20
+	}
+	if params.Offset < 0 {
+		params.Offset = 0
+	}
+
+	entries, err = s.repo.GetEntries(ctx, params)
+	if err != nil {
+		s.logger.Error("failed get time entries", zap.Error(err))
+		return nil, model.PageMeta{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	total, err := s.repo.CountEntries(ctx, params)
+	if err != nil {
+		s.logger.Error("failed count time entries", zap.Error(err))
+		return nil, model.PageMeta{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	ids := make([]uuid.UUID, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	breaks, err := s.repo.GetBreaks(ctx, ids)
+	if err != nil {
+		s.logger.Error("failed get breaks", zap.Error(err))
+		return nil, model.PageMeta{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	for i := range entries {
+		entries[i].Breaks = breaks[entries[i].ID]
+		if entries[i].Breaks == nil {
+			entries[i].Breaks = []model.TimeBreak{}
+		}
+	}
+
+	return entries, model.PageMeta{
+		Total:  total,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	}, nil
+}
+
+// CreateEntry adds a shift a staff member forgot to clock. Managers can't
+// enter their own hours.
+func (s *timeClockService) CreateEntry(ctx context.Context, managerId uuid.UUID, data model.TimeEntryRequest) (entry model.TimeEntry, err error) {
+	if data.StaffId == nil {
+		return model.TimeEntry{}, cerr.New(http.StatusBadRequest, "staffId is required")
+	}
+	staffId := uuid.MustParse(*data.StaffId)
+	if staffId == managerId {
+		return model.TimeEntry{}, cerr.New(http.StatusForbidden, "you can't enter your own hours")
+	}
+
+	breaks, err := checkTimeEntry(data)
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+
+	_, err = s.staffRepo.GetStaffById(ctx, staffId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TimeEntry{}, cerr.New(http.StatusNotFound, "staff is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get staff", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if err = s.checkOverlap(ctx, tx, staffId, uuid.Nil, data); err != nil {
+		return model.TimeEntry{}, err
+	}
+
+	entry, err = s.repo.CreateEntry(ctx, tx, model.TimeEntry{
+		StaffId:          staffId,
+		ClockInAt:        *data.ClockInAt,
+		ClockOutAt:       data.ClockOutAt,
+		CorrectedBy:      &managerId,
+		CorrectionReason: data.Reason,
+	})
+	if err != nil {
+		s.logger.Error("failed create time entry", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if err = s.repo.ReplaceBreaks(ctx, tx, entry.ID, breaks); err != nil {
+		s.logger.Error("failed create breaks", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	entry.Breaks = withEntryId(breaks, entry.ID)
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditTimeEntryCreate, model.AuditTimeEntry, entry.ID.String(), nil, entry))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return entry, nil
+}
+
+// CorrectEntry replaces the times and breaks of a shift, e.g. when a staff
+// member forgot to clock out. The reason and the manager are kept on the
+// entry and the old times in the audit log.
+func (s *timeClockService) CorrectEntry(ctx context.Context, managerId uuid.UUID, id uuid.UUID, data model.TimeEntryRequest) (entry model.TimeEntry, err error) {
+	breaks, err := checkTimeEntry(data)
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	current, err := s.repo.GetEntryForUpdate(ctx, tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TimeEntry{}, cerr.New(http.StatusNotFound, "time entry is not found")
+	}
+	if err != nil {
+		s.logger.Error("failed get time entry", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	if current.StaffId == managerId {
+		return model.TimeEntry{}, cerr.New(http.StatusForbidden, "you can't correct your own hours")
+	}
+
+	// the breaks only change while the entry is locked, so reading them
+	// outside tx gives the current ones
+	before, err := s.withBreaks(ctx, current)
+	if err != nil {
+		return model.TimeEntry{}, err
+	}
+
+	if err = s.checkOverlap(ctx, tx, current.StaffId, current.ID, data); err != nil {
+		return model.TimeEntry{}, err
+	}
+
+	entry, err = s.repo.CorrectEntry(ctx, tx, model.TimeEntry{
+		ID:               id,
+		ClockInAt:        *data.ClockInAt,
+		ClockOutAt:       data.ClockOutAt,
+		CorrectedBy:      &managerId,
+		CorrectionReason: data.Reason,
+	})
+	if err != nil {
+		s.logger.Error("failed correct time entry", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if err = s.repo.ReplaceBreaks(ctx, tx, entry.ID, breaks); err != nil {
+		s.logger.Error("failed replace breaks", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	entry.Breaks = withEntryId(breaks, entry.ID)
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditTimeEntryCorrect, model.AuditTimeEntry, entry.ID.String(), before, entry))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.TimeEntry{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return entry, nil
+}
+
+func (s *timeClockService) checkOverlap(ctx context.Context, tx *sqlx.Tx, staffId uuid.UUID, excludeId uuid.UUID, data model.TimeEntryRequest) error {
+	overlaps, err := s.repo.HasOverlappingEntry(ctx, tx, staffId, excludeId, *data.ClockInAt, *data.ClockOutAt)
+	if err != nil {
+		s.logger.Error("failed check overlapping time entries", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	if overlaps {
+		return cerr.New(http.StatusConflict, "the shift overlaps another shift of the staff")
+	}
+
+	return nil
+}
+
+// checkTimeEntry makes sure the shift ends after it starts and every break
+// lies within it without overlapping another break. The breaks are
+// returned sorted.
+func checkTimeEntry(data model.TimeEntryRequest) ([]model.TimeBreak, error) {
+	clockIn, clockOut := *data.ClockInAt, *data.ClockOutAt
+	if !clockOut.After(clockIn) {
+		return nil, cerr.New(http.StatusBadRequest, "clockOutAt must be after clockInAt")
+	}
+	if clockOut.Sub(clockIn) > maxShiftLength {
+		return nil, cerr.New(http.StatusBadRequest, "a shift can't be longer than 24 hours")
+	}
+
+	breaks := make([]model.TimeBreak, 0, len(data.Breaks))
+	for _, b := range data.Breaks {
+		if !b.EndedAt.After(*b.StartedAt) {
+			return nil, cerr.New(http.StatusBadRequest, "a break must end after it starts")
+		}
+		if b.StartedAt.Before(clockIn) || b.EndedAt.After(clockOut) {
+			return nil, cerr.New(http.StatusBadRequest, "breaks must lie within the shift")
+		}
+		breaks = append(breaks, model.TimeBreak{
+			ID:        uuid.New(),
+			StartedAt: *b.StartedAt,
+			EndedAt:   b.EndedAt,
+		})
+	}
+
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].StartedAt.Before(breaks[j].StartedAt) })
+	for i := 1; i < len(breaks); i++ {
+		if breaks[i].StartedAt.Before(*breaks[i-1].EndedAt) {
+			return nil, cerr.New(http.StatusBadRequest, "breaks must not overlap")
+		}
+	}
+
+	return breaks, nil
+}
+
+func withEntryId(breaks []model.TimeBreak, entryId uuid.UUID) []model.TimeBreak {
+	for i := range breaks {
+		breaks[i].EntryId = entryId
+	}
+	return breaks
+}
+
+// GetAttendance reports the hours of the shifts started within the period
+// next to the sales of each staff member.
+func (s *timeClockService) GetAttendance(ctx context.Context, params model.GetAttendanceParam) (attendance []model.StaffAttendance, err error) {
+	if params.To.Before(params.From) {
+		return nil, cerr.New(http.StatusBadRequest, "from must not be after to")
+	}
+
+	attendance, err = s.repo.GetAttendance(ctx, params)
+	if err != nil {
+		s.logger.Error("failed get attendance", zap.Error(err))
+		return nil, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	for i, a := range attendance {
+		if a.WorkedMinutes > 0 {
+			attendance[i].RevenuePerHour = a.Revenue * 60 / a.WorkedMinutes
+		}
+	}
+
+	return attendance, nil
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"eniqilo-store/model"
+	"eniqilo-store/pkg/crypto"
+	"eniqilo-store/pkg/customErr"
+	"eniqilo-store/pkg/totp"
+	cerr "eniqilo-store/utils/error"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeBytes gives every code 80 bits, too many to guess even
+	// with the stored hash at hand
+	recoveryCodeBytes = 10
+)
+
+// EnrolTwoFactor starts the enrolment, two-factor is only enforced once
+// the staff confirmed it with a code from the app.
+func (s *staffSvc) EnrolTwoFactor(ctx context.Context, id uuid.UUID, data model.EnrolTwoFactorRequest) (enrolment model.TwoFactorEnrolment, err error) {
+	staff, err := s.GetStaff(ctx, id)
+	if err != nil {
+		return model.TwoFactorEnrolment{}, err
+	}
+
+	if crypto.VerifyPassword(*data.Password, staff.Password) != nil {
+		return model.TwoFactorEnrolment{}, cerr.New(http.StatusBadRequest, "password is wrong")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		s.logger.Error("failed generate totp secret", zap.Error(err))
+		return model.TwoFactorEnrolment{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	sealed, err := s.secrets.Seal(secret, id.String())
+	if err != nil {
+		s.logger.Error("failed seal totp secret", zap.Error(err))
+		return model.TwoFactorEnrolment{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.repo.SetTotpSecret(ctx, id, sealed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TwoFactorEnrolment{}, cerr.New(http.StatusConflict, "two-factor authentication is already enabled")
+	}
+	if err != nil {
+		s.logger.Error("failed set totp secret", zap.Error(err))
+		return model.TwoFactorEnrolment{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return model.TwoFactorEnrolment{
+		Secret: secret,
+		URI:    totp.URI(s.cfg.Store.Name, staff.PhoneNumber, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor once the app produces a valid code
+// and hands out the recovery codes.
+func (s *staffSvc) ConfirmTwoFactor(ctx context.Context, id uuid.UUID, data model.ConfirmTwoFactorRequest) (codes model.RecoveryCodes, err error) {
+	staff, err := s.GetStaff(ctx, id)
+	if err != nil {
+		return model.RecoveryCodes{}, err
+	}
+
+	if staff.TotpEnabledAt != nil {
+		return model.RecoveryCodes{}, cerr.New(http.StatusConflict, "two-factor authentication is already enabled")
+	}
+	if staff.TotpSecret == nil {
+		return model.RecoveryCodes{}, cerr.New(http.StatusBadRequest, "two-factor enrolment has not been started")
+	}
+
+	secret, legacy, err := s.totpSecret(staff)
+	if err != nil {
+		return model.RecoveryCodes{}, err
+	}
+
+	counter, ok := totp.Validate(secret, *data.Code, time.Now(), 1)
+	if !ok {
+		return model.RecoveryCodes{}, cerr.New(http.StatusBadRequest, "code is invalid")
+	}
+
+	codes.Codes, err = generateRecoveryCodes()
+	if err != nil {
+		s.logger.Error("failed generate recovery codes", zap.Error(err))
+		return model.RecoveryCodes{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	hashes := make([]string, len(codes.Codes))
+	for i, code := range codes.Codes {
+		hashes[i] = crypto.HashToken(normalizeRecoveryCode(code))
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return model.RecoveryCodes{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if _, err = s.repo.UseTotpCounter(ctx, tx, id, counter); err != nil {
+		s.logger.Error("failed use totp counter", zap.Error(err))
+		return model.RecoveryCodes{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if legacy {
+		if err = s.sealTotpSecret(ctx, tx, id, secret); err != nil {
+			return model.RecoveryCodes{}, err
+		}
+	}
+
+	if err = s.repo.EnableTotp(ctx, tx, id); err != nil {
+		s.logger.Error("failed enable totp", zap.Error(err))
+		return model.RecoveryCodes{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if err = s.repo.ReplaceRecoveryCodes(ctx, tx, id, hashes); err != nil {
+		s.logger.Error("failed create recovery codes", zap.Error(err))
+		return model.RecoveryCodes{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditStaffTwoFactorOn, model.AuditStaff, id.String(), nil, nil))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return model.RecoveryCodes{}, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor off, which isn't allowed for the roles
+// it is enforced for.
+func (s *staffSvc) DisableTwoFactor(ctx context.Context, id uuid.UUID, data model.DisableTwoFactorRequest) (err error) {
+	staff, err := s.GetStaff(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if staff.TotpEnabledAt == nil {
+		return cerr.New(http.StatusBadRequest, "two-factor authentication is not enabled")
+	}
+	if s.cfg.Staff.TwoFactor.Requires(string(staff.Role)) {
+		return cerr.New(http.StatusBadRequest, "two-factor authentication is required for your role")
+	}
+
+	if crypto.VerifyPassword(*data.Password, staff.Password) != nil {
+		return cerr.New(http.StatusBadRequest, "password is wrong")
+	}
+
+	tx, err := s.repo.NewTx()
+	if err != nil {
+		s.logger.Error("failed begin tx", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if err = s.useTotpCode(ctx, tx, staff, *data.Code); err != nil {
+		return err
+	}
+
+	if err = s.repo.DisableTotp(ctx, tx, id); err != nil {
+		s.logger.Error("failed disable totp", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	err = s.auditRepo.CreateEntryTx(ctx, tx, newAuditEntry(ctx, model.AuditStaffTwoFactorOff, model.AuditStaff, id.String(), nil, nil))
+	if err != nil {
+		s.logger.Error("failed write audit entry", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+// LoginTwoFactor is the second step of a login for staff with two-factor
+// enabled. Attempts are counted like password logins, per staff and per
+// client ip.
+func (s *staffSvc) LoginTwoFactor(ctx context.Context, data model.TwoFactorLoginRequest, ip string) (model.StaffWithToken, error) {
+	challenge, err := crypto.VerifyToken(*data.ChallengeToken, s.keys)
+	if err != nil || challenge.Purpose != crypto.ChallengePurpose {
+		return model.StaffWithToken{}, customErr.NewUnauthorizedError("Challenge is invalid or expired")
+	}
+
+	id, err := uuid.Parse(challenge.Id)
+	if err != nil {
+		return model.StaffWithToken{}, customErr.NewUnauthorizedError("Challenge is invalid or expired")
+	}
+
+	accountKey, sourceKey := "2fa:"+id.String(), "ip:"+ip
+
+	if err := s.reserveLogin(ctx, accountKey, sourceKey); err != nil {
+		return model.StaffWithToken{}, err
+	}
+
+	user, err := s.repo.GetStaffById(ctx, id)
+	if err != nil {
+		return model.StaffWithToken{}, customErr.NewUnauthorizedError("Challenge is invalid or expired")
+	}
+
+	// a password change in between invalidates the challenge as well
+	if user.TotpEnabledAt == nil || user.TokenVersion != challenge.Version {
+		return model.StaffWithToken{}, customErr.NewUnauthorizedError("Challenge is invalid or expired")
+	}
+
+	if data.Code != "" {
+		err = s.useTotpCode(ctx, nil, user, data.Code)
+	} else {
+		err = s.repo.UseRecoveryCode(ctx, id, crypto.HashToken(normalizeRecoveryCode(data.RecoveryCode)))
+		if err == nil {
+			s.logger.Warn("recovery code used", zap.String("staffId", id.String()))
+		}
+	}
+	if err != nil {
+		if cerr.GetCode(err) == http.StatusInternalServerError {
+			return model.StaffWithToken{}, customErr.NewInternalServerError("Internal server error")
+		}
+		return model.StaffWithToken{}, customErr.NewBadRequestError("Invalid code")
+	}
+
+	s.releaseLogin(ctx, accountKey, sourceKey)
+
+	if user.DeactivatedAt != nil {
+		return model.StaffWithToken{}, customErr.NewForbiddenError("Account is deactivated")
+	}
+
+	token, err := crypto.GenerateToken(user.UserId, user.PhoneNumber, user.Name, user.TokenVersion, s.keys)
+	if err != nil {
+		return model.StaffWithToken{}, customErr.NewBadRequestError(err.Error())
+	}
+
+	return model.StaffWithToken{
+		UserId:      user.UserId.String(),
+		Name:        user.Name,
+		PhoneNumber: user.PhoneNumber,
+		Role:        user.Role,
+		AccessToken: token,
+	}, nil
+}
+
+// useTotpCode checks code and makes sure it can't be used again. Without
+// a tx the counter is updated on its own.
+func (s *staffSvc) useTotpCode(ctx context.Context, tx *sqlx.Tx, staff model.Staff, code string) (err error) {
+	if staff.TotpSecret == nil {
+		return cerr.New(http.StatusBadRequest, "code is invalid")
+	}
+
+	secret, legacy, err := s.totpSecret(staff)
+	if err != nil {
+		return err
+	}
+
+	counter, ok := totp.Validate(secret, code, time.Now(), 1)
+	if !ok {
+		return cerr.New(http.StatusBadRequest, "code is invalid")
+	}
+
+	if tx == nil {
+		tx, err = s.repo.NewTx()
+		if err != nil {
+			s.logger.Error("failed begin tx", zap.Error(err))
+			return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+		}
+		defer func() {
+			if err != nil {
+				_ = tx.Rollback()
+				return
+			}
+			err = tx.Commit()
+		}()
+	}
+
+	ok, err = s.repo.UseTotpCounter(ctx, tx, staff.UserId, counter)
+	if err != nil {
+		s.logger.Error("failed use totp counter", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+	if !ok {
+		return cerr.New(http.StatusBadRequest, "code has already been used")
+	}
+
+	if legacy {
+		return s.sealTotpSecret(ctx, tx, staff.UserId, secret)
+	}
+
+	return nil
+}
+
+// totpSecret decrypts the authenticator secret of staff. legacy is true
+// for a secret stored before secrets were encrypted, it is sealed by the
+// caller once a code has been accepted.
+func (s *staffSvc) totpSecret(staff model.Staff) (secret string, legacy bool, err error) {
+	if staff.TotpSecret == nil {
+		return "", false, cerr.New(http.StatusBadRequest, "two-factor enrolment has not been started")
+	}
+
+	secret, err = s.secrets.Open(*staff.TotpSecret, staff.UserId.String())
+	if errors.Is(err, crypto.ErrNotSealed) {
+		return *staff.TotpSecret, true, nil
+	}
+	if err != nil {
+		s.logger.Error("failed open totp secret", zap.Error(err), zap.String("staffId", staff.UserId.String()))
+		return "", false, cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return secret, false, nil
+}
+
+func (s *staffSvc) sealTotpSecret(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, secret string) error {
+	sealed, err := s.secrets.Seal(secret, id.String())
+	if err != nil {
+		s.logger.Error("failed seal totp secret", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	if err = s.repo.UpdateTotpSecret(ctx, tx, id, sealed); err != nil {
+		s.logger.Error("failed update totp secret", zap.Error(err))
+		return cerr.New(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return nil
+}
+
+// generateRecoveryCodes returns codes formatted as xxxxx-xxxxx-xxxxx-xxxxx
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		token, err := crypto.GenerateRandomToken(recoveryCodeBytes)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = token[:5] + "-" + token[5:10] + "-" + token[10:15] + "-" + token[15:]
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}